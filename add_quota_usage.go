@@ -4,13 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 )
 
 // AddUsageOption .
 type AddUsageOption struct {
-	ModifiedUsage int64
-	Irreversible  bool // does not reverse when the next update quota usage has an error
+	ModifiedUsage int64     // Deprecated: use UsageCost
+	UsageCost     UsageCost // computes the usage of every request, overrides ModifiedUsage
+	Irreversible  bool      // does not reverse when the next update quota usage has an error
 	Listener      UpdateQuotaUsageListener
+	Partial       bool // grants the remaining usage down to MinUsage of the request when the usage exceeds the limit
+}
+
+// PartialQuotaUsageResult is the result of add quota usage with partial option
+type PartialQuotaUsageResult struct {
+	Usage  int64 // granted usage
+	Result interface{}
 }
 
 type addQuotaUsage struct {
@@ -43,9 +52,9 @@ func (q *addQuotaUsage) Do(ctx context.Context, req *QuotaUsageRequest) (res int
 		return
 	}
 
-	usage := req.Usage
-	if q.option.ModifiedUsage > 0 {
-		usage = q.option.ModifiedUsage
+	usage, err := getUsage(ctx, req, q.option.UsageCost, q.option.ModifiedUsage)
+	if err != nil {
+		return
 	}
 
 	limit, err := q.getQuotaLimit.Do(ctx, quotaReq)
@@ -53,14 +62,23 @@ func (q *addQuotaUsage) Do(ctx context.Context, req *QuotaUsageRequest) (res int
 		return
 	}
 
-	totalUsage, err = q.cache.IncrBy(ctx, key, usage)
-	if err != nil {
+	if limitCache, ok := q.cache.(LimitCache); ok {
+		var accepted bool
+		totalUsage, accepted, err = limitCache.IncrByLimit(ctx, key, usage, limit)
+		if err != nil {
+			err = fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+			return
+		} else if !accepted {
+			err = newQuotaLimitExceededError(ctx, q.getQuotaLimit, quotaReq, key, limit, totalUsage)
+			return
+		}
+	} else if totalUsage, err = q.cache.IncrBy(ctx, key, usage); err != nil {
 		err = fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
 		return
 	}
 
 	if totalUsage > limit {
-		err = NewQuotaLimitExceededError(key, limit, totalUsage-usage)
+		err = newQuotaLimitExceededError(ctx, q.getQuotaLimit, quotaReq, key, limit, totalUsage-usage)
 		if er := q.reverseUsage(ctx, key, usage); er != nil {
 			err = er
 		}
@@ -106,7 +124,55 @@ func NewAddQuotaUsage(
 	}
 }
 
+// QuotaLimitExceededError is error for quota limit exceeded
+type QuotaLimitExceededError struct {
+	Key           string
+	Subject       string // the limit of the subject is exceeded when it is not empty
+	Class         string // the limit of the priority class is exceeded when it is not empty
+	Limit         int64
+	Allowance     int64 // the usage above the limit that is accepted by the overdraft
+	Usage         int64
+	NextReleaseAt time.Time // zero when the limit will not be increased by a release
+}
+
+func (e *QuotaLimitExceededError) Error() string {
+	if e.Subject != "" {
+		return fmt.Sprintf("%v: limit %d and usage %d for subject %s of key %s", ErrQuotaSubjectLimitExceeded, e.Limit, e.Usage, e.Subject, e.Key)
+	}
+	if e.Class != "" {
+		return fmt.Sprintf("%v: limit %d and usage %d for class %s of key %s", ErrQuotaClassLimitExceeded, e.Limit, e.Usage, e.Class, e.Key)
+	}
+
+	msg := fmt.Sprintf("%v: limit %d and usage %d for key %s", ErrQuotaLimitExceeded, e.Limit, e.Usage, e.Key)
+	if e.Allowance > 0 {
+		msg += fmt.Sprintf(", overdraft %d", e.Allowance)
+	}
+	if !e.NextReleaseAt.IsZero() {
+		msg += fmt.Sprintf(", next release at %s", e.NextReleaseAt.Format(time.RFC3339))
+	}
+	return msg
+}
+
+func (e *QuotaLimitExceededError) Is(target error) bool {
+	return (target == ErrQuotaSubjectLimitExceeded && e.Subject != "") || (target == ErrQuotaClassLimitExceeded && e.Class != "")
+}
+
+func (e *QuotaLimitExceededError) Unwrap() error {
+	return ErrQuotaLimitExceeded
+}
+
 // NewQuotaLimitExceededError is a error helper for quota limit exceeded
 func NewQuotaLimitExceededError(key string, limit, usage int64) error {
-	return fmt.Errorf("%w: limit %d and usage %d for key %s", ErrQuotaLimitExceeded, limit, usage, key)
+	return &QuotaLimitExceededError{Key: key, Limit: limit, Usage: usage}
+}
+
+// newQuotaLimitExceededError attaches the next release time when the limit is released in waves
+func newQuotaLimitExceededError(ctx context.Context, getQuotaLimit GetQuota, req *QuotaRequest, key string, limit, usage int64) *QuotaLimitExceededError {
+	err := &QuotaLimitExceededError{Key: key, Limit: limit, Usage: usage}
+	if release, ok := getQuotaLimit.(GetQuotaNextRelease); ok {
+		if nextAt, er := release.NextReleaseAt(ctx, req); er == nil {
+			err.NextReleaseAt = nextAt
+		}
+	}
+	return err
 }
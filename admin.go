@@ -0,0 +1,336 @@
+package andromeda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AdminOperation is a name of the admin operation
+type AdminOperation string
+
+const (
+	AdminSetUsage    AdminOperation = "set_usage"
+	AdminResetUsage  AdminOperation = "reset_usage"
+	AdminAdjustUsage AdminOperation = "adjust_usage"
+	AdminInvalidate  AdminOperation = "invalidate"
+	AdminFreeze      AdminOperation = "freeze"
+	AdminUnfreeze    AdminOperation = "unfreeze"
+	AdminSetState    AdminOperation = "set_state"
+)
+
+// AdminEvent is a model for the admin operation of a quota
+type AdminEvent struct {
+	Operation AdminOperation
+	Request   *QuotaRequest
+	Usage     int64 // updated usage
+	Delta     int64
+	Reason    string
+	Direction QuotaDirection
+	State     QuotaState
+}
+
+// AdminListener listen on success or error of the admin operation
+type AdminListener interface {
+	OnSuccess(ctx context.Context, event *AdminEvent)
+	OnError(ctx context.Context, event *AdminEvent, err error)
+}
+
+// Admin is a contract to correct a quota usage that is stored in the cache
+type Admin interface {
+	SetUsage(ctx context.Context, req *QuotaRequest, usage int64) error
+	ResetUsage(ctx context.Context, req *QuotaRequest) error
+	AdjustUsage(ctx context.Context, req *QuotaRequest, delta int64, reason string) (int64, error)
+	Invalidate(ctx context.Context, req *QuotaRequest) error
+	Freeze(ctx context.Context, req *QuotaRequest) error
+	Unfreeze(ctx context.Context, req *QuotaRequest) error
+	SetState(ctx context.Context, req *QuotaRequest, direction QuotaDirection, state QuotaState) error
+	GetState(ctx context.Context, req *QuotaRequest, direction QuotaDirection) (QuotaState, error)
+}
+
+// AdminConfig .
+type AdminConfig struct {
+	Cache                   Cache
+	GetQuotaUsage           GetQuota
+	GetQuotaUsageKey        GetQuotaKey
+	GetQuotaUsageExpiration GetQuotaExpiration
+	GetQuotaUsageConfig     GetQuotaUsageConfig
+	GetQuotaStateKey        GetQuotaKey
+	GetQuotaThresholdKey    GetQuotaKey // optional, clears the crossed thresholds above the changed usage so they fire again
+	Listener                AdminListener
+}
+
+// setQuotaUsageScript replaces the usage in a single script, so the live increments are not lost in between,
+// the usage does not expire when the expiration is not positive
+const setQuotaUsageScript = usageScriptPrelude + `
+if tonumber(p.expiration) > 0 then
+	redis.call('SET', key('usage'), p.usage, 'PX', p.expiration)
+else
+	redis.call('SET', key('usage'), p.usage)
+end
+clearThresholds(tonumber(p.usage))
+return {0, tonumber(p.usage)}
+`
+
+// adjustQuotaUsageScript adds the delta only when the usage stays positive, so a negative usage is never visible
+const adjustQuotaUsageScript = usageScriptPrelude + `
+local usage = tonumber(redis.call('GET', key('usage')) or '0') + tonumber(p.usage)
+if usage < 0 then
+	return {4, usage}
+end
+
+local total = redis.call('INCRBY', key('usage'), p.usage)
+clearThresholds(total)
+return {0, total}
+`
+
+type admin struct {
+	cache                   Cache
+	getQuotaUsage           GetQuota
+	getQuotaUsageKey        GetQuotaKey
+	getQuotaUsageExpiration GetQuotaExpiration
+	getQuotaStateKey        GetQuotaKey
+	getQuotaThresholdKey    GetQuotaKey
+	xSetNXQuotaUsage        XSetNXQuota
+	config                  GetQuotaUsageConfig
+	listener                AdminListener
+}
+
+func (a *admin) SetUsage(ctx context.Context, req *QuotaRequest, usage int64) error {
+	event := &AdminEvent{Operation: AdminSetUsage, Request: req, Usage: usage}
+
+	return a.notify(ctx, event, a.setUsage(ctx, req, usage))
+}
+
+func (a *admin) ResetUsage(ctx context.Context, req *QuotaRequest) error {
+	event := &AdminEvent{Operation: AdminResetUsage, Request: req}
+
+	return a.notify(ctx, event, a.setUsage(ctx, req, 0))
+}
+
+func (a *admin) AdjustUsage(ctx context.Context, req *QuotaRequest, delta int64, reason string) (int64, error) {
+	event := &AdminEvent{Operation: AdminAdjustUsage, Request: req, Delta: delta, Reason: reason}
+
+	// make sure the usage is loaded, otherwise the delta will be the whole usage
+	if err := a.xSetNXQuotaUsage.Do(ctx, req); err != nil {
+		return 0, a.notify(ctx, event, err)
+	}
+
+	key, err := a.getQuotaUsageKey.Do(ctx, req)
+	if err != nil {
+		return 0, a.notify(ctx, event, err)
+	}
+
+	script, err := a.newScript(ctx, req, key, delta)
+	if err != nil {
+		return 0, a.notify(ctx, event, err)
+	}
+
+	err = a.withLock(ctx, key, func() error {
+		code, values, err := script.run(ctx, a.cache, adjustQuotaUsageScript)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+		} else if code == usageScriptInvalidMinUsage {
+			return NewInvalidMinQuotaUsageError(key, values[0])
+		}
+
+		event.Usage = values[0]
+		return nil
+	})
+
+	return event.Usage, a.notify(ctx, event, err)
+}
+
+func (a *admin) Invalidate(ctx context.Context, req *QuotaRequest) error {
+	event := &AdminEvent{Operation: AdminInvalidate, Request: req}
+
+	key, err := a.getQuotaUsageKey.Do(ctx, req)
+	if err != nil {
+		return a.notify(ctx, event, err)
+	}
+
+	err = a.withLock(ctx, key, func() error {
+		usage, err := a.getQuotaUsage.Do(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		exp, err := a.getQuotaUsageExpiration.Do(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		event.Usage = usage
+		return a.runSetUsage(ctx, req, key, usage, exp)
+	})
+
+	return a.notify(ctx, event, err)
+}
+
+// Freeze pauses both directions of the quota usage
+func (a *admin) Freeze(ctx context.Context, req *QuotaRequest) error {
+	event := &AdminEvent{Operation: AdminFreeze, Request: req, State: QuotaStatePaused}
+
+	return a.notify(ctx, event, a.setStates(ctx, req, QuotaStatePaused))
+}
+
+// Unfreeze activates both directions of the quota usage
+func (a *admin) Unfreeze(ctx context.Context, req *QuotaRequest) error {
+	event := &AdminEvent{Operation: AdminUnfreeze, Request: req, State: QuotaStateActive}
+
+	return a.notify(ctx, event, a.setStates(ctx, req, QuotaStateActive))
+}
+
+func (a *admin) SetState(ctx context.Context, req *QuotaRequest, direction QuotaDirection, state QuotaState) error {
+	event := &AdminEvent{Operation: AdminSetState, Request: req, Direction: direction, State: state}
+
+	return a.notify(ctx, event, a.setStates(ctx, req, state, direction))
+}
+
+func (a *admin) GetState(ctx context.Context, req *QuotaRequest, direction QuotaDirection) (QuotaState, error) {
+	key, err := a.getQuotaStateKey.Do(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	return getQuotaState(ctx, a.cache, key, direction)
+}
+
+// setStates sets the state of the directions, every direction is set when it is empty
+func (a *admin) setStates(ctx context.Context, req *QuotaRequest, state QuotaState, directions ...QuotaDirection) error {
+	if len(directions) == 0 {
+		directions = []QuotaDirection{QuotaDirectionAdd, QuotaDirectionReduce}
+	}
+
+	key, err := a.getQuotaStateKey.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	for _, direction := range directions {
+		if err = setQuotaState(ctx, a.cache, key, direction, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *admin) setUsage(ctx context.Context, req *QuotaRequest, usage int64) error {
+	if usage < 0 {
+		return fmt.Errorf("%w: usage %d for quota %s", ErrInvalidMinQuotaUsage, usage, req.QuotaID)
+	}
+
+	key, err := a.getQuotaUsageKey.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	exp, err := a.getQuotaUsageExpiration.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	return a.withLock(ctx, key, func() error {
+		return a.runSetUsage(ctx, req, key, usage, exp)
+	})
+}
+
+func (a *admin) runSetUsage(ctx context.Context, req *QuotaRequest, key string, usage int64, exp time.Duration) error {
+	script, err := a.newScript(ctx, req, key, usage)
+	if err != nil {
+		return err
+	}
+
+	script.withArg("expiration", exp.Milliseconds())
+	if _, _, err := script.run(ctx, a.cache, setQuotaUsageScript); err != nil {
+		return fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+	}
+	return nil
+}
+
+// newScript creates the usage script of the key with the crossed thresholds of the request
+func (a *admin) newScript(ctx context.Context, req *QuotaRequest, key string, usage int64) (*usageScript, error) {
+	script := newUsageScript(key, usage)
+	if a.getQuotaThresholdKey != nil {
+		thresholdKey, err := a.getQuotaThresholdKey.Do(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		script.withKey("threshold", thresholdKey)
+	}
+	return script, nil
+}
+
+// withLock runs fn while holding the same lock that is used to load the quota usage
+func (a *admin) withLock(ctx context.Context, key string, fn func() error) (err error) {
+	var lockKey string
+	for i := 0; i < a.config.GetMaxRetry(); i++ {
+		lockKey, err = lockQuotaKey(ctx, a.cache, key, a.config.GetLockIn())
+		if !errors.Is(err, ErrLockedKey) {
+			break
+		}
+
+		if i+1 != a.config.GetMaxRetry() {
+			time.Sleep(a.config.GetRetryIn())
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if _, er := a.cache.Del(ctx, lockKey); er != nil && err == nil {
+			err = er
+		}
+	}()
+
+	return fn()
+}
+
+func (a *admin) notify(ctx context.Context, event *AdminEvent, err error) error {
+	if a.listener != nil {
+		if err == nil {
+			a.listener.OnSuccess(ctx, event)
+		} else {
+			a.listener.OnError(ctx, event, err)
+		}
+	}
+	return err
+}
+
+// NewAdmin .
+func NewAdmin(conf AdminConfig) Admin {
+	if conf.Cache == nil {
+		panic("Cache is required")
+	}
+	mustScriptCache(conf.Cache)
+	if conf.GetQuotaUsage == nil {
+		panic("GetQuotaUsage is required")
+	}
+	if conf.GetQuotaUsageKey == nil {
+		panic("GetQuotaUsageKey is required")
+	}
+	if conf.GetQuotaUsageExpiration == nil {
+		panic("GetQuotaUsageExpiration is required")
+	}
+	if conf.GetQuotaStateKey == nil {
+		panic("GetQuotaStateKey is required")
+	}
+
+	getUsageConf := conf.GetQuotaUsageConfig
+	xSetNXQuotaUsage := NewXSetNXQuota(conf.Cache, conf.GetQuotaUsageKey, conf.GetQuotaUsageExpiration, conf.GetQuotaUsage, getUsageConf.GetLockIn())
+	xSetNXQuotaUsage = NewRetryableXSetNXQuota(xSetNXQuotaUsage, getUsageConf.GetMaxRetry(), getUsageConf.GetRetryIn())
+
+	return &admin{
+		cache:                   conf.Cache,
+		getQuotaUsage:           conf.GetQuotaUsage,
+		getQuotaUsageKey:        conf.GetQuotaUsageKey,
+		getQuotaUsageExpiration: conf.GetQuotaUsageExpiration,
+		getQuotaStateKey:        conf.GetQuotaStateKey,
+		getQuotaThresholdKey:    conf.GetQuotaThresholdKey,
+		xSetNXQuotaUsage:        xSetNXQuotaUsage,
+		config:                  getUsageConf,
+		listener:                conf.Listener,
+	}
+}
@@ -0,0 +1,326 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAdmin(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	getQuotaUsageKey := &mockGetQuotaKey{keyFormat: "admin-usage-%s"}
+	getQuotaStateKey := &mockGetQuotaKey{keyFormat: "admin-state-%s"}
+	mockCtrl := gomock.NewController(t)
+	mockListener := mocks.NewMockAdminListener(mockCtrl)
+	admin := andromeda.NewAdmin(andromeda.AdminConfig{
+		Cache:                   redisCache,
+		GetQuotaUsage:           &mockGetQuota{value: 7},
+		GetQuotaUsageKey:        getQuotaUsageKey,
+		GetQuotaUsageExpiration: &mockGetQuotaExp{},
+		GetQuotaStateKey:        getQuotaStateKey,
+		GetQuotaThresholdKey:    &mockGetQuotaKey{keyFormat: "admin-usage-%s-crossed"},
+		Listener:                mockListener,
+	})
+	getCache := func(key string) string {
+		val, _ := miniRedis.Get(key)
+		return val
+	}
+
+	t.Run("SetUsage", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "1"}
+		mockListener.EXPECT().OnSuccess(ctx, &andromeda.AdminEvent{Operation: andromeda.AdminSetUsage, Request: req, Usage: 5})
+
+		err := admin.SetUsage(ctx, req, 5)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "5", getCache("admin-usage-1"))
+		assert.Equal(t, time.Second*30, miniRedis.TTL("admin-usage-1"))
+	})
+
+	t.Run("ErrorSetNegativeUsage", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "1"}
+		mockListener.EXPECT().OnError(ctx, gomock.Any(), gomock.Any())
+
+		err := admin.SetUsage(ctx, req, -1)
+
+		assert.True(t, errors.Is(err, andromeda.ErrInvalidMinQuotaUsage))
+	})
+
+	t.Run("ErrorSetUsageWhenLocked", func(t *testing.T) {
+		defer mockCtrl.Finish()
+		defer miniRedis.Del("admin-usage-2-lock")
+
+		req := &andromeda.QuotaRequest{QuotaID: "2"}
+		assert.Nil(t, miniRedis.Set("admin-usage-2-lock", "1"))
+		mockListener.EXPECT().OnError(ctx, gomock.Any(), gomock.Any())
+
+		err := admin.SetUsage(ctx, req, 5)
+
+		assert.True(t, errors.Is(err, andromeda.ErrLockedKey))
+		assert.False(t, miniRedis.Exists("admin-usage-2"))
+	})
+
+	t.Run("ResetUsage", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "3"}
+		assert.Nil(t, miniRedis.Set("admin-usage-3", "10"))
+		mockListener.EXPECT().OnSuccess(ctx, &andromeda.AdminEvent{Operation: andromeda.AdminResetUsage, Request: req})
+
+		err := admin.ResetUsage(ctx, req)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "0", getCache("admin-usage-3"))
+	})
+
+	t.Run("AdjustUsageLoadsUsageFirst", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "4"}
+		mockListener.EXPECT().OnSuccess(ctx, &andromeda.AdminEvent{
+			Operation: andromeda.AdminAdjustUsage,
+			Request:   req,
+			Usage:     10,
+			Delta:     3,
+			Reason:    "missing claims",
+		})
+
+		usage, err := admin.AdjustUsage(ctx, req, 3, "missing claims")
+
+		assert.Equal(t, int64(10), usage)
+		assert.Nil(t, err)
+		assert.False(t, miniRedis.Exists("admin-usage-4-lock"))
+	})
+
+	t.Run("ErrorAdjustUsageBelowZero", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "5"}
+		assert.Nil(t, miniRedis.Set("admin-usage-5", "2"))
+		mockListener.EXPECT().OnError(ctx, gomock.Any(), gomock.Any())
+
+		usage, err := admin.AdjustUsage(ctx, req, -3, "refund")
+
+		assert.Equal(t, int64(0), usage)
+		assert.True(t, errors.Is(err, andromeda.ErrInvalidMinQuotaUsage))
+		assert.Equal(t, "2", getCache("admin-usage-5"))
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "6"}
+		assert.Nil(t, miniRedis.Set("admin-usage-6", "100"))
+		mockListener.EXPECT().OnSuccess(ctx, &andromeda.AdminEvent{Operation: andromeda.AdminInvalidate, Request: req, Usage: 7})
+
+		err := admin.Invalidate(ctx, req)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "7", getCache("admin-usage-6"))
+	})
+
+	t.Run("ClearThresholdsAboveUsage", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "11"}
+		assert.Nil(t, miniRedis.Set("admin-usage-11", "9"))
+		miniRedis.HSet("admin-usage-11-crossed", "50", "5")
+		miniRedis.HSet("admin-usage-11-crossed", "80", "8")
+		mockListener.EXPECT().OnSuccess(ctx, gomock.Any()).Times(2)
+
+		assert.Nil(t, admin.SetUsage(ctx, req, 6))
+		levels, err := miniRedis.HKeys("admin-usage-11-crossed")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"50"}, levels)
+
+		_, err = admin.AdjustUsage(ctx, req, -2, "refund")
+		assert.Nil(t, err)
+		assert.False(t, miniRedis.Exists("admin-usage-11-crossed"))
+	})
+
+	t.Run("FreezeAndUnfreeze", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "7"}
+		usageReq := &andromeda.QuotaUsageRequest{QuotaID: "7", Usage: 1}
+		addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: getQuotaUsageKey,
+			GetQuotaStateKey: getQuotaStateKey,
+		})
+		reduceQuotaUsage := andromeda.ReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaUsageKey: getQuotaUsageKey,
+			GetQuotaStateKey: getQuotaStateKey,
+		})
+		mockListener.EXPECT().OnSuccess(ctx, &andromeda.AdminEvent{Operation: andromeda.AdminFreeze, Request: req, State: andromeda.QuotaStatePaused})
+		mockListener.EXPECT().OnSuccess(ctx, &andromeda.AdminEvent{Operation: andromeda.AdminUnfreeze, Request: req, State: andromeda.QuotaStateActive})
+
+		err := admin.Freeze(ctx, req)
+
+		assert.Nil(t, err)
+
+		_, err = addQuotaUsage.Do(ctx, usageReq)
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaPaused))
+
+		_, err = reduceQuotaUsage.Do(ctx, usageReq)
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaPaused))
+
+		err = admin.Unfreeze(ctx, req)
+
+		assert.Nil(t, err)
+
+		_, err = addQuotaUsage.Do(ctx, usageReq)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1", getCache("admin-usage-7"))
+	})
+
+	t.Run("PausedAddAllowsReduce", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "8"}
+		usageReq := &andromeda.QuotaUsageRequest{QuotaID: "8", Usage: 1}
+		addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: getQuotaUsageKey,
+			GetQuotaStateKey: getQuotaStateKey,
+		})
+		reduceQuotaUsage := andromeda.ReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaUsageKey: getQuotaUsageKey,
+			GetQuotaStateKey: getQuotaStateKey,
+		})
+		assert.Nil(t, miniRedis.Set("admin-usage-8", "5"))
+		mockListener.EXPECT().OnSuccess(ctx, gomock.Any()).Times(2)
+
+		err := admin.SetState(ctx, req, andromeda.QuotaDirectionAdd, andromeda.QuotaStatePaused)
+
+		assert.Nil(t, err)
+
+		err = admin.SetState(ctx, req, andromeda.QuotaDirectionReduce, andromeda.QuotaStateActive)
+
+		assert.Nil(t, err)
+
+		addState, err := admin.GetState(ctx, req, andromeda.QuotaDirectionAdd)
+
+		assert.Equal(t, andromeda.QuotaStatePaused, addState)
+		assert.Nil(t, err)
+
+		reduceState, err := admin.GetState(ctx, req, andromeda.QuotaDirectionReduce)
+
+		assert.Equal(t, andromeda.QuotaStateActive, reduceState)
+		assert.Nil(t, err)
+
+		_, err = addQuotaUsage.Do(ctx, usageReq)
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaPaused))
+
+		_, err = reduceQuotaUsage.Do(ctx, usageReq)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "4", getCache("admin-usage-8"))
+	})
+
+	t.Run("ClosedQuota", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "9"}
+		addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: getQuotaUsageKey,
+			GetQuotaStateKey: getQuotaStateKey,
+		})
+		mockListener.EXPECT().OnSuccess(ctx, gomock.Any())
+
+		err := admin.SetState(ctx, req, andromeda.QuotaDirectionAdd, andromeda.QuotaStateClosed)
+
+		assert.Nil(t, err)
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "9", Usage: 1})
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaClosed))
+	})
+
+	t.Run("ErrorInvalidState", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		mockListener.EXPECT().OnError(ctx, gomock.Any(), gomock.Any())
+
+		err := admin.SetState(ctx, &andromeda.QuotaRequest{QuotaID: "10"}, andromeda.QuotaDirectionAdd, "unknown")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestNewAdmin(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockCache := mocks.NewMockCache(mockCtrl)
+	mockGetQuota := mocks.NewMockGetQuota(mockCtrl)
+	mockGetQuotaKey := mocks.NewMockGetQuotaKey(mockCtrl)
+	mockGetQuotaExp := mocks.NewMockGetQuotaExpiration(mockCtrl)
+
+	tests := []struct {
+		name string
+		conf andromeda.AdminConfig
+	}{
+		{
+			name: "PanicRequireCache",
+			conf: andromeda.AdminConfig{},
+		},
+		{
+			name: "PanicRequireGetQuotaUsage",
+			conf: andromeda.AdminConfig{Cache: mockCache},
+		},
+		{
+			name: "PanicRequireGetQuotaUsageKey",
+			conf: andromeda.AdminConfig{Cache: mockCache, GetQuotaUsage: mockGetQuota},
+		},
+		{
+			name: "PanicRequireGetQuotaUsageExpiration",
+			conf: andromeda.AdminConfig{Cache: mockCache, GetQuotaUsage: mockGetQuota, GetQuotaUsageKey: mockGetQuotaKey},
+		},
+		{
+			name: "PanicRequireGetQuotaStateKey",
+			conf: andromeda.AdminConfig{
+				Cache:                   mockCache,
+				GetQuotaUsage:           mockGetQuota,
+				GetQuotaUsageKey:        mockGetQuotaKey,
+				GetQuotaUsageExpiration: mockGetQuotaExp,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("The code did not panic")
+				}
+			}()
+
+			andromeda.NewAdmin(test.conf)
+		})
+	}
+}
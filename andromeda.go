@@ -11,11 +11,106 @@ type QuotaRequest struct {
 	Data    interface{}
 }
 
+// QuotaSchedule is a model for the open and close time of a quota, zero time means no bound
+type QuotaSchedule struct {
+	StartAt time.Time
+	EndAt   time.Time
+}
+
+// QuotaStatus is a model for the limit and usage of a quota that is stored in the cache
+type QuotaStatus struct {
+	Key       string
+	Limit     int64
+	Usage     int64
+	Remaining int64
+	Overdraft int64               // usage above the limit that is accepted by the overdraft
+	Scale     int                 // decimal places of the minor units, zero for the integer quota
+	Classes   []*QuotaClassStatus // usage of every priority class, empty when the quota has no classes
+}
+
+// QuotaClass is a model for a priority class of a quota, e.g. the premium members get 10 percent of the stock
+type QuotaClass struct {
+	Name   string
+	Share  int64 // guaranteed percent of the limit that is held back from the other classes
+	Borrow bool  // uses the capacity that is not guaranteed to the other classes above the share
+}
+
+// QuotaClassStatus is a model for the usage and the remaining capacity of a priority class
+type QuotaClassStatus struct {
+	Class     string
+	Reserved  int64 // guaranteed share of the limit
+	Usage     int64
+	Remaining int64
+}
+
+// DecimalLimit formats the limit in the unit of the quota
+func (s *QuotaStatus) DecimalLimit() string {
+	return FormatDecimal(s.Limit, s.Scale)
+}
+
+// DecimalUsage formats the usage in the unit of the quota
+func (s *QuotaStatus) DecimalUsage() string {
+	return FormatDecimal(s.Usage, s.Scale)
+}
+
+// DecimalRemaining formats the remaining in the unit of the quota
+func (s *QuotaStatus) DecimalRemaining() string {
+	return FormatDecimal(s.Remaining, s.Scale)
+}
+
+// QuotaUsageCheck is a model for the would-be result of the quota usage, the key is empty when the quota is not found
+type QuotaUsageCheck struct {
+	Key        string
+	Usage      int64 // would-be granted usage, zero when it is rejected
+	TotalUsage int64 // would-be total usage
+	Limit      int64 // zero for reduce quota usage
+	Remaining  int64 // remaining quota for add quota usage
+	Overdraft  int64 // would-be usage above the limit that is accepted by the overdraft
+	Allowance  int64 // the usage above the limit that is accepted by the overdraft
+}
+
+// QuotaThresholdEvent is a model for the threshold of a quota that is crossed by the usage
+type QuotaThresholdEvent struct {
+	Request   *QuotaUsageRequest
+	Key       string
+	Threshold int64 // percent of the limit
+	Limit     int64
+	Usage     int64 // total usage that crosses the threshold
+}
+
+// QuotaOffer is a model for the freed usage that is held for a subject of the waitlist until it expires
+type QuotaOffer struct {
+	QuotaID   string
+	Key       string
+	Subject   string
+	Usage     int64
+	ExpiresAt time.Time
+}
+
+// QuotaTicket is a model for the ticket of the waiting room, the token is given to the holder
+type QuotaTicket struct {
+	QuotaID  string
+	Number   int64
+	Token    string
+	IssuedAt time.Time
+	AdmitAt  time.Time // scheduled by the admission rate when it is issued, the expiration starts from it
+}
+
+// QuotaTicketStatus is a model for the position of the ticket in the waiting room
+type QuotaTicketStatus struct {
+	Number        int64
+	Admitted      bool
+	Position      int64 // number of the tickets ahead that are not admitted yet, zero when it is admitted
+	EstimatedWait time.Duration
+	AdmitAt       time.Time
+}
+
 // QuotaUsageRequest is a model for quota usage request
 type QuotaUsageRequest struct {
-	QuotaID string
-	Usage   int64
-	Data    interface{}
+	QuotaID  string
+	Usage    int64
+	MinUsage int64 // minimum granted usage of add quota usage with partial option, default is 1
+	Data     interface{}
 }
 
 // UpdateQuotaUsage is a contract to update quota usage
@@ -24,17 +119,82 @@ type UpdateQuotaUsage interface {
 	Do(ctx context.Context, req *QuotaUsageRequest) (interface{}, error)
 }
 
+// UsageCost is a contract to compute the usage of a request, e.g. from the items in the data
+type UsageCost interface {
+	Do(ctx context.Context, req *QuotaUsageRequest) (int64, error)
+}
+
+// CheckQuotaUsage is a contract to check the quota usage without updating it,
+// the error is the would-be error of the quota usage
+type CheckQuotaUsage interface {
+	Check(ctx context.Context, req *QuotaUsageRequest) (*QuotaUsageCheck, error)
+}
+
 // UpdateQuotaUsageListener listen on success or error when updating quota usage
 type UpdateQuotaUsageListener interface {
 	OnSuccess(ctx context.Context, req *QuotaUsageRequest, updatedUsage int64)
 	OnError(ctx context.Context, req *QuotaUsageRequest, err error)
 }
 
+// QuotaOverdraftListener listen on the usage above the limit that is accepted by the overdraft,
+// it is called when the listener of the add usage option implements it
+type QuotaOverdraftListener interface {
+	OnOverdraft(ctx context.Context, req *QuotaUsageRequest, overdraftUsage int64)
+}
+
+// QuotaFallbackListener listen on the unavailable cache that the usage is limited in memory instead,
+// it is called when the listener of the add usage option implements it
+type QuotaFallbackListener interface {
+	OnFallback(ctx context.Context, req *QuotaUsageRequest, err error)
+}
+
+// QuotaWaitlistListener listen on the freed usage that is offered to a subject of the waitlist
+type QuotaWaitlistListener interface {
+	OnOffered(ctx context.Context, offer *QuotaOffer)
+}
+
+// QuotaWaitlistErrorListener listen on the error of offering the freed usage after the usage is reduced,
+// it is called when the listener of the waitlist implements it
+type QuotaWaitlistErrorListener interface {
+	OnOfferError(ctx context.Context, req *QuotaUsageRequest, err error)
+}
+
+// QuotaThresholdListener listen on the threshold that is crossed by adding quota usage,
+// it is called once per crossing across every instance
+type QuotaThresholdListener interface {
+	OnThresholdCrossed(ctx context.Context, event *QuotaThresholdEvent)
+}
+
+// GetQuotaThresholds is a contract to get the thresholds of a quota in percent of the limit, e.g. 80 and 95
+type GetQuotaThresholds interface {
+	Do(ctx context.Context, req *QuotaRequest) ([]int64, error)
+}
+
 // GetQuota is a contract to get quota limit or usage
 type GetQuota interface {
 	Do(ctx context.Context, req *QuotaRequest) (int64, error)
 }
 
+// SetQuota is a contract to set quota limit or usage
+type SetQuota interface {
+	Do(ctx context.Context, req *QuotaRequest, value int64) error
+}
+
+// GetDecimalQuota is a contract to get decimal quota limit or usage, e.g. a budget in currency
+type GetDecimalQuota interface {
+	Do(ctx context.Context, req *QuotaRequest) (string, error)
+}
+
+// GetDecimalUsage is a contract to get the decimal usage of a request
+type GetDecimalUsage interface {
+	Do(ctx context.Context, req *QuotaUsageRequest) (string, error)
+}
+
+// GetQuotaStatus is a contract to get the status of a quota
+type GetQuotaStatus interface {
+	Do(ctx context.Context, req *QuotaRequest) (*QuotaStatus, error)
+}
+
 // GetQuotaKey is a contract to get quota key for the cache
 type GetQuotaKey interface {
 	Do(ctx context.Context, req *QuotaRequest) (string, error)
@@ -45,6 +205,36 @@ type GetQuotaExpiration interface {
 	Do(ctx context.Context, req *QuotaRequest) (time.Duration, error)
 }
 
+// GetQuotaSchedule is a contract to get the open and close time of a quota
+type GetQuotaSchedule interface {
+	Do(ctx context.Context, req *QuotaRequest) (*QuotaSchedule, error)
+}
+
+// GetQuotaRelease is a contract to get the release schedule of a quota limit, the whole limit is released when it is nil
+type GetQuotaRelease interface {
+	Do(ctx context.Context, req *QuotaRequest) (*QuotaRelease, error)
+}
+
+// GetQuotaSubject is a contract to get the subject of a quota request, e.g. the user that claims the quota
+type GetQuotaSubject interface {
+	Do(ctx context.Context, req *QuotaRequest) (string, error)
+}
+
+// GetQuotaClass is a contract to get the priority class of a quota request, e.g. the membership from the request data
+type GetQuotaClass interface {
+	Do(ctx context.Context, req *QuotaRequest) (string, error)
+}
+
+// GetQuotaClasses is a contract to get the priority classes of a quota
+type GetQuotaClasses interface {
+	Do(ctx context.Context, req *QuotaRequest) ([]QuotaClass, error)
+}
+
+// GetQuotaTicket is a contract to get the ticket token of the waiting room from a quota request
+type GetQuotaTicket interface {
+	Do(ctx context.Context, req *QuotaRequest) (string, error)
+}
+
 // XSetNXQuota is a contract to check exists or set if not exists for quota
 type XSetNXQuota interface {
 	Do(ctx context.Context, req *QuotaRequest) error
@@ -52,14 +242,40 @@ type XSetNXQuota interface {
 
 // AddQuotaUsageConfig .
 type AddQuotaUsageConfig struct {
-	Next                    UpdateQuotaUsage
-	Cache                   Cache
-	GetQuotaLimit           GetQuota
-	GetQuotaUsage           GetQuota
-	GetQuotaUsageKey        GetQuotaKey
-	GetQuotaUsageExpiration GetQuotaExpiration
-	GetQuotaUsageConfig     GetQuotaUsageConfig
-	Option                  AddUsageOption
+	Next                      UpdateQuotaUsage
+	Cache                     Cache
+	GetQuotaLimit             GetQuota
+	GetQuotaUsage             GetQuota
+	GetQuotaUsageKey          GetQuotaKey
+	GetQuotaUsageExpiration   GetQuotaExpiration
+	GetQuotaUsageConfig       GetQuotaUsageConfig
+	GetQuotaLimitKey          GetQuotaKey            // optional, keeps the limit in the cache and checks it inside the increment
+	GetQuotaLimitExpiration   GetQuotaExpiration     // required when GetQuotaLimitKey is set
+	GetQuotaStateKey          GetQuotaKey            // optional, rejects the usage when the quota is paused or closed
+	GetQuotaSchedule          GetQuotaSchedule       // optional, rejects the usage outside of the schedule by the cache server time
+	GetQuotaSubject           GetQuotaSubject        // required when GetQuotaSubjectLimit, GetQuotaClaimKey, GetQuotaOwnerKey or GetQuotaWaitlistKey is set
+	GetQuotaSubjectLimit      GetQuota               // optional, limits the usage of every subject within the quota
+	GetQuotaSubjectExpiration GetQuotaExpiration     // required when GetQuotaSubjectLimit is set, zero is no expiry
+	GetQuotaClaimKey          GetQuotaKey            // optional, keeps the subjects that claimed the quota and rejects the second claim
+	GetQuotaClaimExpiration   GetQuotaExpiration     // required when GetQuotaClaimKey is set, zero is no expiry
+	GetQuotaOwnerKey          GetQuotaKey            // optional, records the usage of every subject to validate the refunds
+	GetQuotaOwnerExpiration   GetQuotaExpiration     // required when GetQuotaOwnerKey is set, refreshed by every usage, zero is no expiry
+	GetQuotaThresholds        GetQuotaThresholds     // optional, fires the listener once when the usage crosses every threshold
+	GetQuotaThresholdKey      GetQuotaKey            // required when GetQuotaThresholds is set, keeps the crossed thresholds
+	ThresholdListener         QuotaThresholdListener // required when GetQuotaThresholds is set
+	GetQuotaOverdraft         GetQuota               // optional, accepts the usage above the limit up to the overdraft, e.g. NewPercentQuota
+	GetQuotaOverdraftKey      GetQuotaKey            // required when GetQuotaOverdraft is set, counts the overdraft usage
+	GetQuotaClasses           GetQuotaClasses        // optional, enforces the share of every priority class
+	GetQuotaClass             GetQuotaClass          // required when GetQuotaClasses is set
+	GetQuotaClassKey          GetQuotaKey            // required when GetQuotaClasses is set, keeps the usage of every class
+	GetQuotaWaitlistKey       GetQuotaKey            // optional, consumes the usage that is held for the subject by the waitlist, e.g. by QuotaWaitlist.AddQuotaUsage
+	Option                    AddUsageOption
+}
+
+func (c AddQuotaUsageConfig) isAtomic() bool {
+	return c.GetQuotaLimitKey != nil || c.GetQuotaStateKey != nil || c.GetQuotaSchedule != nil ||
+		c.GetQuotaSubjectLimit != nil || c.GetQuotaClaimKey != nil || c.GetQuotaOwnerKey != nil || c.GetQuotaThresholds != nil || c.GetQuotaOverdraft != nil || c.GetQuotaClasses != nil ||
+		c.GetQuotaWaitlistKey != nil || c.Option.Partial
 }
 
 // ReduceQuotaUsageConfig .
@@ -70,9 +286,29 @@ type ReduceQuotaUsageConfig struct {
 	GetQuotaUsageKey        GetQuotaKey
 	GetQuotaUsageExpiration GetQuotaExpiration
 	GetQuotaUsageConfig     GetQuotaUsageConfig
+	GetQuotaStateKey        GetQuotaKey     // optional, rejects the usage when the quota is paused or closed
+	GetQuotaSubject         GetQuotaSubject // optional, reduces the usage of the subject too
+	GetQuotaClaimKey        GetQuotaKey     // optional, removes the subject from the claims, requires GetQuotaSubject
+	GetQuotaOwnerKey        GetQuotaKey     // optional, rejects the refund that exceeds the usage of the subject, requires GetQuotaSubject
+	GetQuotaThresholdKey    GetQuotaKey     // optional, clears the crossed thresholds above the usage so they fire again
+	GetQuotaOverdraftKey    GetQuotaKey     // optional, takes the refund off the overdraft usage first
+	GetQuotaClass           GetQuotaClass   // optional, reduces the usage of the priority class too, requires GetQuotaClassKey
+	GetQuotaClassKey        GetQuotaKey
 	Option                  ReduceUsageOption
 }
 
+func (c ReduceQuotaUsageConfig) isAtomic() bool {
+	return c.GetQuotaStateKey != nil || c.GetQuotaSubject != nil || c.GetQuotaClaimKey != nil || c.GetQuotaOwnerKey != nil ||
+		c.GetQuotaThresholdKey != nil || c.GetQuotaOverdraftKey != nil || c.GetQuotaClass != nil
+}
+
+// SetQuotaLimitConfig .
+type SetQuotaLimitConfig struct {
+	Cache                   Cache
+	GetQuotaLimitKey        GetQuotaKey
+	GetQuotaLimitExpiration GetQuotaExpiration
+}
+
 // GetQuotaUsageConfig .
 type GetQuotaUsageConfig struct {
 	LockIn   time.Duration
@@ -105,29 +341,92 @@ func (q GetQuotaUsageConfig) GetRetryIn() time.Duration {
 
 // AddQuotaUsage .
 func AddQuotaUsage(conf AddQuotaUsageConfig) UpdateQuotaUsage {
-	if conf.Cache == nil {
+	conf.validate()
+
+	if conf.Next == nil {
+		conf.Next = NopUpdateQuotaUsage()
+	}
+
+	addQuotaUsage := NewAddQuotaUsage(conf.Cache, conf.GetQuotaUsageKey, conf.GetQuotaLimit, conf.Next, conf.Option)
+	if conf.isAtomic() {
+		addQuotaUsage = NewAtomicAddQuotaUsage(conf)
+	}
+
+	return conf.withWarmUp(addQuotaUsage)
+}
+
+// CheckAddQuotaUsage checks the add quota usage with every rule of the config without updating the usage,
+// the limit and the usage are still warmed up
+func CheckAddQuotaUsage(conf AddQuotaUsageConfig) CheckQuotaUsage {
+	conf.validate()
+	mustScriptCache(conf.Cache)
+
+	return newCheckQuotaUsage(conf.withWarmUp, newAtomicAddQuotaUsage(conf))
+}
+
+func (c AddQuotaUsageConfig) validate() {
+	if c.Cache == nil {
 		panic("Cache is required")
 	}
-	if conf.GetQuotaLimit == nil {
+	if c.GetQuotaLimit == nil {
 		panic("GetQuotaLimit is required")
 	}
-	if conf.GetQuotaUsageKey == nil {
+	if c.GetQuotaUsageKey == nil {
 		panic("GetQuotaUsageKey is required")
 	}
-
-	if conf.Next == nil {
-		conf.Next = NopUpdateQuotaUsage()
+	if c.isAtomic() {
+		mustScriptCache(c.Cache)
 	}
+	if (c.GetQuotaSubjectLimit != nil || c.GetQuotaClaimKey != nil || c.GetQuotaOwnerKey != nil || c.GetQuotaWaitlistKey != nil) && c.GetQuotaSubject == nil {
+		panic("GetQuotaSubject is required")
+	}
+	if c.GetQuotaSubjectLimit != nil && c.GetQuotaSubjectExpiration == nil {
+		panic("GetQuotaSubjectExpiration is required")
+	}
+	if c.GetQuotaClaimKey != nil && c.GetQuotaClaimExpiration == nil {
+		panic("GetQuotaClaimExpiration is required")
+	}
+	if c.GetQuotaOwnerKey != nil && c.GetQuotaOwnerExpiration == nil {
+		panic("GetQuotaOwnerExpiration is required")
+	}
+	if c.GetQuotaThresholds != nil && c.GetQuotaThresholdKey == nil {
+		panic("GetQuotaThresholdKey is required")
+	}
+	if c.GetQuotaThresholds != nil && c.ThresholdListener == nil {
+		panic("ThresholdListener is required")
+	}
+	if c.GetQuotaOverdraft != nil && c.GetQuotaOverdraftKey == nil {
+		panic("GetQuotaOverdraftKey is required")
+	}
+	if c.GetQuotaClasses != nil && c.GetQuotaClass == nil {
+		panic("GetQuotaClass is required")
+	}
+	if c.GetQuotaClasses != nil && c.GetQuotaClassKey == nil {
+		panic("GetQuotaClassKey is required")
+	}
+	if c.GetQuotaLimitKey != nil && c.GetQuotaLimitExpiration == nil {
+		panic("GetQuotaLimitExpiration is required")
+	}
+	if _, ok := c.GetQuotaLimit.(GetQuotaNextRelease); ok && c.GetQuotaLimitKey != nil {
+		panic("GetQuotaLimitKey must not be set with a released limit")
+	}
+	if c.GetQuotaUsage != nil && c.GetQuotaUsageExpiration == nil {
+		panic("GetQuotaUsageExpiration is required")
+	}
+}
 
-	addQuotaUsage := NewAddQuotaUsage(conf.Cache, conf.GetQuotaUsageKey, conf.GetQuotaLimit, conf.Next, conf.Option)
-
-	if conf.GetQuotaUsage != nil {
-		if conf.GetQuotaUsageExpiration == nil {
-			panic("GetQuotaUsageExpiration is required")
-		}
+// withWarmUp loads the usage and the limit before the add quota usage
+func (c AddQuotaUsageConfig) withWarmUp(addQuotaUsage UpdateQuotaUsage) UpdateQuotaUsage {
+	if c.GetQuotaLimitKey != nil {
+		getLimitConf := c.GetQuotaUsageConfig
+		xSetNXQuotaLimit := NewXSetNXQuota(c.Cache, c.GetQuotaLimitKey, c.GetQuotaLimitExpiration, c.GetQuotaLimit, getLimitConf.GetLockIn())
+		xSetNXQuotaLimit = NewRetryableXSetNXQuota(xSetNXQuotaLimit, getLimitConf.GetMaxRetry(), getLimitConf.GetRetryIn())
+		addQuotaUsage = NewUpdateQuotaUsageMiddleware(NewXSetNXQuotaUsage(xSetNXQuotaLimit), addQuotaUsage)
+	}
 
-		getUsageConf := conf.GetQuotaUsageConfig
-		xSetNXQuotaUsage := NewXSetNXQuota(conf.Cache, conf.GetQuotaUsageKey, conf.GetQuotaUsageExpiration, conf.GetQuotaUsage, getUsageConf.GetLockIn())
+	if c.GetQuotaUsage != nil {
+		getUsageConf := c.GetQuotaUsageConfig
+		xSetNXQuotaUsage := NewXSetNXQuota(c.Cache, c.GetQuotaUsageKey, c.GetQuotaUsageExpiration, c.GetQuotaUsage, getUsageConf.GetLockIn())
 		xSetNXQuotaUsage = NewRetryableXSetNXQuota(xSetNXQuotaUsage, getUsageConf.GetMaxRetry(), getUsageConf.GetRetryIn())
 		addQuotaUsage = NewUpdateQuotaUsageMiddleware(NewXSetNXQuotaUsage(xSetNXQuotaUsage), addQuotaUsage)
 	}
@@ -137,29 +436,73 @@ func AddQuotaUsage(conf AddQuotaUsageConfig) UpdateQuotaUsage {
 
 // ReduceQuotaUsage .
 func ReduceQuotaUsage(conf ReduceQuotaUsageConfig) UpdateQuotaUsage {
-	if conf.Cache == nil {
-		panic("Cache is required")
-	}
-	if conf.GetQuotaUsageKey == nil {
-		panic("GetQuotaUsageKey is required")
-	}
+	conf.validate()
 
 	if conf.Next == nil {
 		conf.Next = NopUpdateQuotaUsage()
 	}
 
 	reduceQuotaUsage := NewReduceQuotaUsage(conf.Cache, conf.GetQuotaUsageKey, conf.Next, conf.Option)
+	if conf.isAtomic() {
+		reduceQuotaUsage = NewAtomicReduceQuotaUsage(conf)
+	}
 
-	if conf.GetQuotaUsage != nil {
-		if conf.GetQuotaUsageExpiration == nil {
-			panic("GetQuotaUsageExpiration is required")
-		}
+	return conf.withWarmUp(reduceQuotaUsage)
+}
 
-		getUsageConf := conf.GetQuotaUsageConfig
-		xSetNXQuotaUsage := NewXSetNXQuota(conf.Cache, conf.GetQuotaUsageKey, conf.GetQuotaUsageExpiration, conf.GetQuotaUsage, getUsageConf.GetLockIn())
+// CheckReduceQuotaUsage checks the reduce quota usage with every rule of the config without updating the usage,
+// the usage is still warmed up
+func CheckReduceQuotaUsage(conf ReduceQuotaUsageConfig) CheckQuotaUsage {
+	conf.validate()
+	mustScriptCache(conf.Cache)
+
+	return newCheckQuotaUsage(conf.withWarmUp, newAtomicReduceQuotaUsage(conf))
+}
+
+func (c ReduceQuotaUsageConfig) validate() {
+	if c.Cache == nil {
+		panic("Cache is required")
+	}
+	if c.GetQuotaUsageKey == nil {
+		panic("GetQuotaUsageKey is required")
+	}
+	if c.isAtomic() {
+		mustScriptCache(c.Cache)
+	}
+	if (c.GetQuotaClaimKey != nil || c.GetQuotaOwnerKey != nil) && c.GetQuotaSubject == nil {
+		panic("GetQuotaSubject is required")
+	}
+	if c.GetQuotaClass != nil && c.GetQuotaClassKey == nil {
+		panic("GetQuotaClassKey is required")
+	}
+	if c.GetQuotaUsage != nil && c.GetQuotaUsageExpiration == nil {
+		panic("GetQuotaUsageExpiration is required")
+	}
+}
+
+// withWarmUp loads the usage before the reduce quota usage
+func (c ReduceQuotaUsageConfig) withWarmUp(reduceQuotaUsage UpdateQuotaUsage) UpdateQuotaUsage {
+	if c.GetQuotaUsage != nil {
+		getUsageConf := c.GetQuotaUsageConfig
+		xSetNXQuotaUsage := NewXSetNXQuota(c.Cache, c.GetQuotaUsageKey, c.GetQuotaUsageExpiration, c.GetQuotaUsage, getUsageConf.GetLockIn())
 		xSetNXQuotaUsage = NewRetryableXSetNXQuota(xSetNXQuotaUsage, getUsageConf.GetMaxRetry(), getUsageConf.GetRetryIn())
 		reduceQuotaUsage = NewUpdateQuotaUsageMiddleware(NewXSetNXQuotaUsage(xSetNXQuotaUsage), reduceQuotaUsage)
 	}
 
 	return reduceQuotaUsage
 }
+
+// SetQuotaLimit pushes the changed limit to the cache that is used by AddQuotaUsageConfig.GetQuotaLimitKey
+func SetQuotaLimit(conf SetQuotaLimitConfig) SetQuota {
+	if conf.Cache == nil {
+		panic("Cache is required")
+	}
+	if conf.GetQuotaLimitKey == nil {
+		panic("GetQuotaLimitKey is required")
+	}
+	if conf.GetQuotaLimitExpiration == nil {
+		panic("GetQuotaLimitExpiration is required")
+	}
+
+	return NewSetCachedQuota(conf.Cache, conf.GetQuotaLimitKey, conf.GetQuotaLimitExpiration)
+}
@@ -55,7 +55,7 @@ func TestGetQuotaUsageConfig(t *testing.T) {
 
 func TestAndromedaAddQuotaUsage(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
-	mockCache := mocks.NewMockCache(mockCtrl)
+	mockCache := mocks.NewMockScriptCache(mockCtrl)
 	mockGetQuotaLimit := mocks.NewMockGetQuota(mockCtrl)
 	mockGetQuotaUsageKey := mocks.NewMockGetQuotaKey(mockCtrl)
 
@@ -143,6 +143,132 @@ func TestAndromedaAddQuotaUsage(t *testing.T) {
 		assert.True(t, ok)
 	})
 
+	t.Run("PanicRequireGetQuotaLimitExpiration", func(t *testing.T) {
+		mockGetQuotaLimitKey := mocks.NewMockGetQuotaKey(mockCtrl)
+
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("The code did not panic")
+			}
+
+			mockCtrl.Finish()
+		}()
+
+		conf := andromeda.AddQuotaUsageConfig{
+			Cache:            mockCache,
+			GetQuotaLimit:    mockGetQuotaLimit,
+			GetQuotaUsageKey: mockGetQuotaUsageKey,
+			GetQuotaLimitKey: mockGetQuotaLimitKey,
+		}
+
+		addQuotaUsage := andromeda.AddQuotaUsage(conf)
+
+		_, ok := addQuotaUsage.(andromeda.UpdateQuotaUsage)
+
+		assert.True(t, ok)
+	})
+
+	t.Run("PanicRequireGetQuotaSubject", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("The code did not panic")
+			}
+		}()
+
+		andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:                mockCache,
+			GetQuotaLimit:        mockGetQuotaLimit,
+			GetQuotaUsageKey:     mockGetQuotaUsageKey,
+			GetQuotaSubjectLimit: &mockGetQuota{value: 2},
+		})
+	})
+
+	t.Run("PanicRequireGetQuotaClaimExpiration", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("The code did not panic")
+			}
+		}()
+
+		andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            mockCache,
+			GetQuotaLimit:    mockGetQuotaLimit,
+			GetQuotaUsageKey: mockGetQuotaUsageKey,
+			GetQuotaSubject:  &mockGetQuotaSubject{},
+			GetQuotaClaimKey: &mockGetQuotaKey{keyFormat: "claim-%s"},
+		})
+	})
+
+	t.Run("PanicRequireThresholdListener", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("The code did not panic")
+			}
+		}()
+
+		andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:                mockCache,
+			GetQuotaLimit:        mockGetQuotaLimit,
+			GetQuotaUsageKey:     mockGetQuotaUsageKey,
+			GetQuotaThresholds:   &mockGetQuotaThresholds{values: []int64{80}},
+			GetQuotaThresholdKey: &mockGetQuotaKey{keyFormat: "threshold-%s"},
+		})
+	})
+
+	t.Run("PanicRequireGetQuotaOverdraftKey", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("The code did not panic")
+			}
+		}()
+
+		andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:             mockCache,
+			GetQuotaLimit:     mockGetQuotaLimit,
+			GetQuotaUsageKey:  mockGetQuotaUsageKey,
+			GetQuotaOverdraft: &mockGetQuota{value: 5},
+		})
+	})
+
+	t.Run("PanicRequireScriptCache", func(t *testing.T) {
+		assert.PanicsWithValue(t, "Cache must implement ScriptCache", func() {
+			andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+				Cache:            mocks.NewMockCache(mockCtrl),
+				GetQuotaLimit:    mockGetQuotaLimit,
+				GetQuotaUsageKey: mockGetQuotaUsageKey,
+				GetQuotaStateKey: &mockGetQuotaKey{keyFormat: "state-%s"},
+			})
+		})
+		assert.NotPanics(t, func() {
+			andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+				Cache:            mocks.NewMockCache(mockCtrl),
+				GetQuotaLimit:    mockGetQuotaLimit,
+				GetQuotaUsageKey: mockGetQuotaUsageKey,
+			})
+		})
+	})
+
+	t.Run("ConfigWithGetQuotaLimitKey", func(t *testing.T) {
+		mockGetQuotaLimitKey := mocks.NewMockGetQuotaKey(mockCtrl)
+		mockGetQuotaLimitExp := mocks.NewMockGetQuotaExpiration(mockCtrl)
+
+		defer mockCtrl.Finish()
+
+		conf := andromeda.AddQuotaUsageConfig{
+			Cache:                   mockCache,
+			GetQuotaLimit:           mockGetQuotaLimit,
+			GetQuotaUsageKey:        mockGetQuotaUsageKey,
+			GetQuotaLimitKey:        mockGetQuotaLimitKey,
+			GetQuotaLimitExpiration: mockGetQuotaLimitExp,
+		}
+
+		addQuotaUsage := andromeda.AddQuotaUsage(conf)
+
+		_, ok := addQuotaUsage.(andromeda.UpdateQuotaUsage)
+
+		assert.True(t, ok)
+	})
+
 	t.Run("ConfigWithoutNextUpdateQuotaUsage", func(t *testing.T) {
 		defer mockCtrl.Finish()
 
@@ -228,7 +354,7 @@ func TestAndromedaAddQuotaUsage(t *testing.T) {
 
 func TestAndromedaReduceQuotaUsage(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
-	mockCache := mocks.NewMockCache(mockCtrl)
+	mockCache := mocks.NewMockScriptCache(mockCtrl)
 	mockGetQuotaUsageKey := mocks.NewMockGetQuotaKey(mockCtrl)
 
 	t.Run("PanicRequireCache", func(t *testing.T) {
@@ -372,6 +498,53 @@ func TestAndromedaReduceQuotaUsage(t *testing.T) {
 	})
 }
 
+func TestAndromedaSetQuotaLimit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockCache := mocks.NewMockScriptCache(mockCtrl)
+	mockGetQuotaLimitKey := mocks.NewMockGetQuotaKey(mockCtrl)
+	mockGetQuotaLimitExp := mocks.NewMockGetQuotaExpiration(mockCtrl)
+
+	tests := []struct {
+		name string
+		conf andromeda.SetQuotaLimitConfig
+	}{
+		{
+			name: "PanicRequireCache",
+			conf: andromeda.SetQuotaLimitConfig{},
+		},
+		{
+			name: "PanicRequireGetQuotaLimitKey",
+			conf: andromeda.SetQuotaLimitConfig{Cache: mockCache},
+		},
+		{
+			name: "PanicRequireGetQuotaLimitExpiration",
+			conf: andromeda.SetQuotaLimitConfig{Cache: mockCache, GetQuotaLimitKey: mockGetQuotaLimitKey},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("The code did not panic")
+				}
+			}()
+
+			andromeda.SetQuotaLimit(test.conf)
+		})
+	}
+
+	t.Run("Config", func(t *testing.T) {
+		setQuotaLimit := andromeda.SetQuotaLimit(andromeda.SetQuotaLimitConfig{
+			Cache:                   mockCache,
+			GetQuotaLimitKey:        mockGetQuotaLimitKey,
+			GetQuotaLimitExpiration: mockGetQuotaLimitExp,
+		})
+
+		assert.NotNil(t, setQuotaLimit)
+	})
+}
+
 func BenchmarkAddQuotaUsage(b *testing.B) {
 	ctx := context.TODO()
 	miniRedis, err := miniredis.Run()
@@ -456,3 +629,55 @@ type mockGetQuotaExp struct{}
 func (q *mockGetQuotaExp) Do(_ context.Context, req *andromeda.QuotaRequest) (time.Duration, error) {
 	return time.Second * 30, nil
 }
+
+// mockGetQuotaNoExp returns the expiration that means no expiry
+type mockGetQuotaNoExp struct{}
+
+func (q *mockGetQuotaNoExp) Do(_ context.Context, req *andromeda.QuotaRequest) (time.Duration, error) {
+	return 0, nil
+}
+
+type mockGetQuotaSchedule struct {
+	schedule andromeda.QuotaSchedule
+}
+
+func (q *mockGetQuotaSchedule) Do(_ context.Context, _ *andromeda.QuotaRequest) (*andromeda.QuotaSchedule, error) {
+	return &q.schedule, nil
+}
+
+type mockGetQuotaSubject struct{}
+
+func (q *mockGetQuotaSubject) Do(_ context.Context, req *andromeda.QuotaRequest) (string, error) {
+	subject, _ := req.Data.(string)
+	return subject, nil
+}
+
+type mockGetQuotaThresholds struct {
+	values []int64
+}
+
+func (q *mockGetQuotaThresholds) Do(_ context.Context, _ *andromeda.QuotaRequest) ([]int64, error) {
+	return q.values, nil
+}
+
+type mockGetQuotaClass struct{}
+
+func (q *mockGetQuotaClass) Do(_ context.Context, req *andromeda.QuotaRequest) (string, error) {
+	class, _ := req.Data.(string)
+	return class, nil
+}
+
+type mockGetQuotaClasses struct {
+	classes []andromeda.QuotaClass
+}
+
+func (q *mockGetQuotaClasses) Do(_ context.Context, _ *andromeda.QuotaRequest) ([]andromeda.QuotaClass, error) {
+	return q.classes, nil
+}
+
+type mockGetQuotaTicket struct{}
+
+func (q *mockGetQuotaTicket) Do(_ context.Context, req *andromeda.QuotaRequest) (string, error) {
+	token, _ := req.Data.(string)
+	return token, nil
+}
@@ -0,0 +1,418 @@
+package andromeda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type atomicAddQuotaUsage struct {
+	cache                     Cache
+	getQuotaUsageKey          GetQuotaKey
+	getQuotaLimit             GetQuota
+	getQuotaLimitKey          GetQuotaKey
+	getQuotaStateKey          GetQuotaKey
+	getQuotaSchedule          GetQuotaSchedule
+	getQuotaSubject           GetQuotaSubject
+	getQuotaSubjectLimit      GetQuota
+	getQuotaSubjectExpiration GetQuotaExpiration
+	getQuotaClaimKey          GetQuotaKey
+	getQuotaClaimExpiration   GetQuotaExpiration
+	getQuotaOwnerKey          GetQuotaKey
+	getQuotaOwnerExpiration   GetQuotaExpiration
+	getQuotaThresholds        GetQuotaThresholds
+	getQuotaThresholdKey      GetQuotaKey
+	thresholdListener         QuotaThresholdListener
+	getQuotaOverdraft         GetQuota
+	getQuotaOverdraftKey      GetQuotaKey
+	getQuotaClasses           GetQuotaClasses
+	getQuotaClass             GetQuotaClass
+	getQuotaClassKey          GetQuotaKey
+	getQuotaWaitlistKey       GetQuotaKey
+	next                      UpdateQuotaUsage
+	option                    AddUsageOption
+}
+
+func (q *atomicAddQuotaUsage) Do(ctx context.Context, req *QuotaUsageRequest) (res interface{}, err error) {
+	var totalUsage int64
+	var isNextErr bool
+
+	defer func() {
+		if q.option.Listener != nil && !isNextErr {
+			if err == nil {
+				q.option.Listener.OnSuccess(ctx, req, totalUsage)
+			} else {
+				q.option.Listener.OnError(ctx, req, err)
+			}
+		}
+	}()
+
+	call, err := q.prepare(ctx, req)
+	if err != nil {
+		return
+	} else if call == nil {
+		return q.next.Do(ctx, req)
+	}
+
+	_, values, err := q.run(ctx, call)
+	if err != nil {
+		return
+	}
+
+	totalUsage = values[0]
+
+	if q.option.Partial {
+		// the next update quota usage and the listener get the granted usage
+		req = &QuotaUsageRequest{QuotaID: req.QuotaID, Usage: values[2], MinUsage: req.MinUsage, Data: req.Data}
+		call.script.withArg("grantedUsage", values[2])
+	}
+	call.script.withArg("overdraftUsage", values[3])
+
+	res, _err := q.next.Do(ctx, req)
+	if _err != nil {
+		isNextErr = true
+
+		if !q.option.Irreversible {
+			if er := q.reverseUsage(ctx, call.script); er != nil {
+				err, _err = er, er
+				isNextErr = false
+			}
+		}
+	}
+
+	// the usage is kept when the next succeeds or it is irreversible, the reverse clears the marks of the thresholds instead
+	if _err == nil || q.option.Irreversible {
+		q.notifyOverdraft(ctx, req, values[3])
+		q.notifyThresholds(ctx, req, call.key, values[1], values[0], values[4:])
+	}
+
+	if q.option.Partial && _err == nil {
+		res = &PartialQuotaUsageResult{Usage: req.Usage, Result: res}
+	}
+
+	return res, _err
+}
+
+// Check runs the add script without updating the usage
+func (q *atomicAddQuotaUsage) Check(ctx context.Context, req *QuotaUsageRequest) (*QuotaUsageCheck, error) {
+	call, err := q.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	} else if call == nil {
+		return &QuotaUsageCheck{Usage: req.Usage}, nil
+	}
+
+	call.script.withArg("dryRun", 1).withReadOnly()
+	code, values, err := q.run(ctx, call)
+	if values == nil {
+		return nil, err
+	}
+
+	check := &QuotaUsageCheck{Key: call.key}
+	switch code {
+	case usageScriptOK:
+		check.Usage, check.TotalUsage, check.Limit, check.Overdraft, check.Allowance = values[2], values[0], values[1], values[3], values[4]
+	case usageScriptLimitExceeded:
+		check.TotalUsage, check.Limit, check.Allowance = values[0], values[1], values[2]
+	default:
+		return check, err
+	}
+
+	check.Remaining = check.Limit - check.TotalUsage
+	return check, err
+}
+
+// addUsageCall is the add script of a request
+type addUsageCall struct {
+	req      *QuotaRequest
+	key      string
+	subject  string
+	class    string
+	schedule *QuotaSchedule
+	script   *usageScript
+}
+
+// prepare resolves the keys and the rules of the request into the add script, the call is nil when the quota is not found
+func (q *atomicAddQuotaUsage) prepare(ctx context.Context, req *QuotaUsageRequest) (*addUsageCall, error) {
+	quotaReq := &QuotaRequest{QuotaID: req.QuotaID, Data: req.Data}
+	key, err := q.getQuotaUsageKey.Do(ctx, quotaReq)
+	if errors.Is(err, ErrQuotaNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	usage, err := getUsage(ctx, req, q.option.UsageCost, q.option.ModifiedUsage)
+	if err != nil {
+		return nil, err
+	}
+
+	script := newUsageScript(key, usage)
+	call := &addUsageCall{req: quotaReq, key: key, script: script}
+
+	if q.getQuotaLimitKey != nil {
+		limitKey, er := q.getQuotaLimitKey.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+		script.withKey("limit", limitKey)
+	} else {
+		limit, er := q.getQuotaLimit.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+		script.withArg("limit", limit)
+	}
+
+	if q.getQuotaStateKey != nil {
+		stateKey, er := q.getQuotaStateKey.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+		script.withKey("state", stateKey)
+	}
+
+	if q.getQuotaSchedule != nil {
+		if call.schedule, err = q.getQuotaSchedule.Do(ctx, quotaReq); err != nil {
+			return nil, err
+		}
+		if call.schedule == nil {
+			call.schedule = &QuotaSchedule{}
+		}
+		if !call.schedule.StartAt.IsZero() {
+			script.withArg("startAt", unixMilli(call.schedule.StartAt))
+		}
+		if !call.schedule.EndAt.IsZero() {
+			script.withArg("endAt", unixMilli(call.schedule.EndAt))
+		}
+	}
+
+	if q.getQuotaSubject != nil {
+		if call.subject, err = getQuotaSubject(ctx, q.getQuotaSubject, quotaReq); err != nil {
+			return nil, err
+		}
+		script.withArg("subject", call.subject)
+	}
+
+	if q.getQuotaWaitlistKey != nil {
+		waitlistKey, er := q.getQuotaWaitlistKey.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+		withQuotaHoldKeys(script, waitlistKey)
+	}
+
+	if q.getQuotaSubjectLimit != nil {
+		subjectLimit, er := q.getQuotaSubjectLimit.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+
+		subjectExp, er := q.getQuotaSubjectExpiration.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+
+		script.withKey("subject", quotaSubjectKey(key, call.subject)).
+			withArg("subjectLimit", subjectLimit).
+			withArg("subjectExpiration", subjectExp.Milliseconds())
+	}
+
+	if q.getQuotaClaimKey != nil {
+		claimKey, er := q.getQuotaClaimKey.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+
+		claimExp, er := q.getQuotaClaimExpiration.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+
+		script.withKey("claim", claimKey).withArg("claimExpiration", claimExp.Milliseconds())
+	}
+
+	if q.getQuotaOwnerKey != nil {
+		ownerKey, er := q.getQuotaOwnerKey.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+
+		ownerExp, er := q.getQuotaOwnerExpiration.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+
+		script.withKey("owner", ownerKey).withArg("ownerExpiration", ownerExp.Milliseconds())
+	}
+
+	if q.getQuotaOverdraft != nil {
+		overdraft, er := q.getQuotaOverdraft.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+
+		overdraftKey, er := q.getQuotaOverdraftKey.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+
+		script.withKey("overdraft", overdraftKey).withArg("overdraft", overdraft)
+	}
+
+	if q.getQuotaClasses != nil {
+		classes, er := q.getQuotaClasses.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+
+		class, er := getQuotaClass(ctx, q.getQuotaClass, quotaReq, classes)
+		if er != nil {
+			return nil, er
+		}
+
+		classKey, er := q.getQuotaClassKey.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+
+		call.class = class.Name
+		script.withKey("class", classKey).withArg("className", class.Name).withArg("classes", len(classes))
+		if class.Borrow {
+			script.withArg("classBorrow", 1)
+		}
+		for i, c := range classes {
+			script.withArg(fmt.Sprintf("class%d", i+1), c.Name).withArg(fmt.Sprintf("classShare%d", i+1), c.Share)
+		}
+	}
+
+	if q.getQuotaThresholds != nil {
+		thresholds, er := q.getQuotaThresholds.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+
+		thresholdKey, er := q.getQuotaThresholdKey.Do(ctx, quotaReq)
+		if er != nil {
+			return nil, er
+		}
+
+		script.withKey("threshold", thresholdKey).withArg("thresholds", len(thresholds))
+		for i, threshold := range thresholds {
+			script.withArg(fmt.Sprintf("threshold%d", i+1), threshold)
+		}
+	}
+
+	if q.option.Partial {
+		minUsage := req.MinUsage
+		if minUsage <= 0 {
+			minUsage = 1
+		}
+		if minUsage > usage {
+			return nil, fmt.Errorf("%w: min usage %d above usage %d for key %s", ErrInvalidMinQuotaUsage, minUsage, usage, key)
+		}
+		script.withArg("minUsage", minUsage)
+	}
+
+	return call, nil
+}
+
+// run executes the add script and returns the error of the result code
+func (q *atomicAddQuotaUsage) run(ctx context.Context, call *addUsageCall) (int64, []int64, error) {
+	code, values, err := call.script.run(ctx, q.cache, addQuotaUsageScript)
+	if err != nil {
+		return code, values, fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+	}
+
+	switch code {
+	case usageScriptPaused:
+		return code, values, NewQuotaStateError(call.key, QuotaDirectionAdd, QuotaStatePaused)
+	case usageScriptClosed:
+		return code, values, NewQuotaStateError(call.key, QuotaDirectionAdd, QuotaStateClosed)
+	case usageScriptNotOpen:
+		return code, values, NewQuotaScheduleError(ErrQuotaNotOpen, call.key, call.schedule, fromUnixMilli(values[0]))
+	case usageScriptEnded:
+		return code, values, NewQuotaScheduleError(ErrQuotaClosed, call.key, call.schedule, fromUnixMilli(values[0]))
+	case usageScriptNotFound:
+		return code, values, fmt.Errorf("%w: limit of key %s", ErrQuotaNotFound, call.key)
+	case usageScriptAlreadyClaimed:
+		return code, values, NewAlreadyClaimedError(call.key, call.subject)
+	case usageScriptSubjectLimitExceeded:
+		return code, values, NewQuotaSubjectLimitExceededError(call.key, call.subject, values[1], values[0])
+	case usageScriptClassLimitExceeded:
+		return code, values, NewQuotaClassLimitExceededError(call.key, call.class, values[1], values[0])
+	case usageScriptLimitExceeded:
+		limitErr := newQuotaLimitExceededError(ctx, q.getQuotaLimit, call.req, call.key, values[1], values[0])
+		limitErr.Allowance = values[2]
+		return code, values, limitErr
+	}
+
+	return code, values, nil
+}
+
+// notifyOverdraft flags the usage above the limit when the listener implements QuotaOverdraftListener
+func (q *atomicAddQuotaUsage) notifyOverdraft(ctx context.Context, req *QuotaUsageRequest, overdraftUsage int64) {
+	if listener, ok := q.option.Listener.(QuotaOverdraftListener); ok && overdraftUsage > 0 {
+		listener.OnOverdraft(ctx, req, overdraftUsage)
+	}
+}
+
+// notifyThresholds fires the thresholds that are crossed by the script, the cache marks every crossing
+// so only one instance gets it
+func (q *atomicAddQuotaUsage) notifyThresholds(ctx context.Context, req *QuotaUsageRequest, key string, limit, usage int64, crossed []int64) {
+	for _, threshold := range crossed {
+		q.thresholdListener.OnThresholdCrossed(ctx, &QuotaThresholdEvent{
+			Request:   req,
+			Key:       key,
+			Threshold: threshold,
+			Limit:     limit,
+			Usage:     usage,
+		})
+	}
+}
+
+func (q *atomicAddQuotaUsage) reverseUsage(ctx context.Context, script *usageScript) error {
+	if _, _, err := script.run(ctx, q.cache, reverseAddQuotaUsageScript); err != nil {
+		return fmt.Errorf("%w: %v", ErrReduceQuotaUsage, err)
+	}
+	return nil
+}
+
+// NewAtomicAddQuotaUsage checks the state, the schedule and the limit and increments the usage in a single script,
+// the limit is read from the cache when GetQuotaLimitKey is set and the usage is accepted up to the overdraft above it when GetQuotaOverdraft is set
+func NewAtomicAddQuotaUsage(conf AddQuotaUsageConfig) UpdateQuotaUsage {
+	return newAtomicAddQuotaUsage(conf)
+}
+
+func newAtomicAddQuotaUsage(conf AddQuotaUsageConfig) *atomicAddQuotaUsage {
+	if conf.Next == nil {
+		conf.Next = NopUpdateQuotaUsage()
+	}
+
+	return &atomicAddQuotaUsage{
+		cache:                     conf.Cache,
+		getQuotaUsageKey:          conf.GetQuotaUsageKey,
+		getQuotaLimit:             conf.GetQuotaLimit,
+		getQuotaLimitKey:          conf.GetQuotaLimitKey,
+		getQuotaStateKey:          conf.GetQuotaStateKey,
+		getQuotaSchedule:          conf.GetQuotaSchedule,
+		getQuotaSubject:           conf.GetQuotaSubject,
+		getQuotaSubjectLimit:      conf.GetQuotaSubjectLimit,
+		getQuotaSubjectExpiration: conf.GetQuotaSubjectExpiration,
+		getQuotaClaimKey:          conf.GetQuotaClaimKey,
+		getQuotaClaimExpiration:   conf.GetQuotaClaimExpiration,
+		getQuotaOwnerKey:          conf.GetQuotaOwnerKey,
+		getQuotaOwnerExpiration:   conf.GetQuotaOwnerExpiration,
+		getQuotaThresholds:        conf.GetQuotaThresholds,
+		getQuotaThresholdKey:      conf.GetQuotaThresholdKey,
+		thresholdListener:         conf.ThresholdListener,
+		getQuotaOverdraft:         conf.GetQuotaOverdraft,
+		getQuotaOverdraftKey:      conf.GetQuotaOverdraftKey,
+		getQuotaClasses:           conf.GetQuotaClasses,
+		getQuotaClass:             conf.GetQuotaClass,
+		getQuotaClassKey:          conf.GetQuotaClassKey,
+		getQuotaWaitlistKey:       conf.GetQuotaWaitlistKey,
+		next:                      conf.Next,
+		option:                    conf.Option,
+	}
+}
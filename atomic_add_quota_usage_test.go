@@ -0,0 +1,241 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAtomicAddQuotaUsage(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	mockCtrl := gomock.NewController(t)
+	mockNext := mocks.NewMockUpdateQuotaUsage(mockCtrl)
+	getCache := func(key string) string {
+		val, _ := miniRedis.Get(key)
+		return val
+	}
+
+	t.Run("SucceedWithLimit", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		addQuotaUsage := andromeda.NewAtomicAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-usage-1-%s"},
+			Next:             mockNext,
+		})
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 10}
+		mockNext.EXPECT().Do(ctx, req).Return("result", nil)
+
+		res, err := addQuotaUsage.Do(ctx, req)
+
+		assert.Equal(t, "result", res)
+		assert.Nil(t, err)
+		assert.Equal(t, "10", getCache("atomic-usage-1-123"))
+	})
+
+	t.Run("ErrorQuotaLimitExceeded", func(t *testing.T) {
+		addQuotaUsage := andromeda.NewAtomicAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-usage-2-%s"},
+		})
+		assert.Nil(t, miniRedis.Set("atomic-usage-2-123", "5"))
+
+		res, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 6})
+
+		assert.Nil(t, res)
+		assert.EqualError(t, err, andromeda.NewQuotaLimitExceededError("atomic-usage-2-123", 10, 5).Error())
+		assert.Equal(t, "5", getCache("atomic-usage-2-123"))
+	})
+
+	t.Run("SucceedWithCachedLimit", func(t *testing.T) {
+		mockGetQuotaLimit := mocks.NewMockGetQuota(mockCtrl)
+		addQuotaUsage := andromeda.NewAtomicAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    mockGetQuotaLimit,
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-usage-3-%s"},
+			GetQuotaLimitKey: &mockGetQuotaKey{keyFormat: "atomic-limit-3-%s"},
+		})
+		assert.Nil(t, miniRedis.Set("atomic-limit-3-123", "3"))
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 3})
+
+		assert.Nil(t, err)
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1})
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+		assert.Equal(t, "3", getCache("atomic-usage-3-123"))
+	})
+
+	t.Run("ErrorCachedLimitNotFound", func(t *testing.T) {
+		addQuotaUsage := andromeda.NewAtomicAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-usage-4-%s"},
+			GetQuotaLimitKey: &mockGetQuotaKey{keyFormat: "atomic-limit-4-%s"},
+		})
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1})
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaNotFound))
+		assert.False(t, miniRedis.Exists("atomic-usage-4-123"))
+	})
+
+	t.Run("ReverseUsageWhenNextHasError", func(t *testing.T) {
+		mockListener := mocks.NewMockUpdateQuotaUsageListener(mockCtrl)
+		defer mockCtrl.Finish()
+
+		addQuotaUsage := andromeda.NewAtomicAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-usage-5-%s"},
+			Next:             mockNext,
+			Option:           andromeda.AddUsageOption{Listener: mockListener},
+		})
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 2}
+		mockErr := errors.New("unexpected")
+		mockNext.EXPECT().Do(ctx, req).Return(nil, mockErr)
+
+		res, err := addQuotaUsage.Do(ctx, req)
+
+		assert.Nil(t, res)
+		assert.EqualError(t, err, mockErr.Error())
+		assert.Equal(t, "0", getCache("atomic-usage-5-123"))
+	})
+
+	t.Run("ListenOnSuccess", func(t *testing.T) {
+		mockListener := mocks.NewMockUpdateQuotaUsageListener(mockCtrl)
+		defer mockCtrl.Finish()
+
+		addQuotaUsage := andromeda.NewAtomicAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-usage-6-%s"},
+			Option:           andromeda.AddUsageOption{Listener: mockListener, ModifiedUsage: 4},
+		})
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1}
+		mockListener.EXPECT().OnSuccess(ctx, req, int64(4))
+
+		_, err := addQuotaUsage.Do(ctx, req)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("WarmUpCachedLimit", func(t *testing.T) {
+		addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:                   redisCache,
+			GetQuotaLimit:           &mockGetQuota{value: 10},
+			GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "atomic-usage-7-%s"},
+			GetQuotaLimitKey:        &mockGetQuotaKey{keyFormat: "atomic-limit-7-%s"},
+			GetQuotaLimitExpiration: &mockGetQuotaExp{},
+		})
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "10", getCache("atomic-limit-7-123"))
+		assert.Equal(t, time.Second*30, miniRedis.TTL("atomic-limit-7-123"))
+
+		setQuotaLimit := andromeda.SetQuotaLimit(andromeda.SetQuotaLimitConfig{
+			Cache:                   redisCache,
+			GetQuotaLimitKey:        &mockGetQuotaKey{keyFormat: "atomic-limit-7-%s"},
+			GetQuotaLimitExpiration: &mockGetQuotaExp{},
+		})
+
+		err = setQuotaLimit.Do(ctx, &andromeda.QuotaRequest{QuotaID: "123"}, 1)
+
+		assert.Nil(t, err)
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1})
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+	})
+
+	t.Run("PartialUsage", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		mockListener := mocks.NewMockUpdateQuotaUsageListener(mockCtrl)
+		addQuotaUsage := andromeda.NewAtomicAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-usage-8-%s"},
+			Next:             mockNext,
+			Option:           andromeda.AddUsageOption{Partial: true, Listener: mockListener},
+		})
+		assert.Nil(t, miniRedis.Set("atomic-usage-8-123", "7"))
+		grantedReq := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 3, MinUsage: 2}
+		mockNext.EXPECT().Do(ctx, grantedReq).Return("result", nil)
+		mockListener.EXPECT().OnSuccess(ctx, grantedReq, int64(10))
+
+		res, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 5, MinUsage: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, &andromeda.PartialQuotaUsageResult{Usage: 3, Result: "result"}, res)
+		assert.Equal(t, "10", getCache("atomic-usage-8-123"))
+	})
+
+	t.Run("ErrorPartialUsageUnderMinUsage", func(t *testing.T) {
+		addQuotaUsage := andromeda.NewAtomicAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-usage-9-%s"},
+			Option:           andromeda.AddUsageOption{Partial: true},
+		})
+		assert.Nil(t, miniRedis.Set("atomic-usage-9-123", "9"))
+
+		res, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 5, MinUsage: 2})
+
+		assert.Nil(t, res)
+		assert.EqualError(t, err, andromeda.NewQuotaLimitExceededError("atomic-usage-9-123", 10, 9).Error())
+		assert.Equal(t, "9", getCache("atomic-usage-9-123"))
+	})
+
+	t.Run("ErrorMinUsageAboveUsage", func(t *testing.T) {
+		addQuotaUsage := andromeda.NewAtomicAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-usage-9-%s"},
+			Option:           andromeda.AddUsageOption{Partial: true},
+		})
+
+		res, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "456", Usage: 1, MinUsage: 5})
+
+		assert.Nil(t, res)
+		assert.True(t, errors.Is(err, andromeda.ErrInvalidMinQuotaUsage))
+		assert.False(t, miniRedis.Exists("atomic-usage-9-456"))
+	})
+
+	t.Run("ReversePartialUsageWhenNextHasError", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		addQuotaUsage := andromeda.NewAtomicAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-usage-10-%s"},
+			Next:             mockNext,
+			Option:           andromeda.AddUsageOption{Partial: true},
+		})
+		assert.Nil(t, miniRedis.Set("atomic-usage-10-123", "8"))
+		mockErr := errors.New("unexpected")
+		mockNext.EXPECT().Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 2}).Return(nil, mockErr)
+
+		res, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 5})
+
+		assert.Nil(t, res)
+		assert.EqualError(t, err, mockErr.Error())
+		assert.Equal(t, "8", getCache("atomic-usage-10-123"))
+	})
+}
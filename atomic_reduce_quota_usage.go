@@ -0,0 +1,239 @@
+package andromeda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type atomicReduceQuotaUsage struct {
+	cache                Cache
+	getQuotaUsageKey     GetQuotaKey
+	getQuotaStateKey     GetQuotaKey
+	getQuotaSubject      GetQuotaSubject
+	getQuotaClaimKey     GetQuotaKey
+	getQuotaOwnerKey     GetQuotaKey
+	getQuotaThresholdKey GetQuotaKey
+	getQuotaOverdraftKey GetQuotaKey
+	getQuotaClass        GetQuotaClass
+	getQuotaClassKey     GetQuotaKey
+	next                 UpdateQuotaUsage
+	option               ReduceUsageOption
+}
+
+func (q *atomicReduceQuotaUsage) Do(ctx context.Context, req *QuotaUsageRequest) (res interface{}, err error) {
+	var totalUsage int64
+	var isNextErr bool
+
+	defer func() {
+		if q.option.Listener != nil && !isNextErr {
+			if err == nil {
+				q.option.Listener.OnSuccess(ctx, req, totalUsage)
+			} else {
+				q.option.Listener.OnError(ctx, req, err)
+			}
+		}
+	}()
+
+	call, err := q.prepare(ctx, req)
+	if err != nil {
+		return
+	} else if call == nil {
+		return q.next.Do(ctx, req)
+	}
+
+	_, values, err := q.run(ctx, call)
+	if err != nil {
+		return
+	}
+
+	totalUsage = values[0]
+
+	res, _err := q.next.Do(ctx, req)
+	if _err != nil {
+		isNextErr = true
+
+		if !q.option.Irreversible {
+			call.script.withArg("subjectUsage", values[1]).withArg("claimed", values[2]).withArg("classUsage", values[3]).
+				withArg("ownerTTL", values[4]).withArg("overdraftUsage", values[5])
+			// restores the thresholds that are cleared by the reduce so they do not fire again
+			cleared := values[6:]
+			call.script.withArg("cleared", len(cleared)/2)
+			for i := 0; i+1 < len(cleared); i += 2 {
+				call.script.withArg(fmt.Sprintf("clearedThreshold%d", i/2+1), cleared[i]).
+					withArg(fmt.Sprintf("clearedLevel%d", i/2+1), cleared[i+1])
+			}
+			if er := q.reverseUsage(ctx, call.script); er != nil {
+				err, _err = er, er
+				isNextErr = false
+			}
+		}
+	}
+
+	return res, _err
+}
+
+// Check runs the reduce script without updating the usage
+func (q *atomicReduceQuotaUsage) Check(ctx context.Context, req *QuotaUsageRequest) (*QuotaUsageCheck, error) {
+	call, err := q.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	} else if call == nil {
+		return &QuotaUsageCheck{Usage: req.Usage}, nil
+	}
+
+	call.script.withArg("dryRun", 1).withReadOnly()
+	code, values, err := q.run(ctx, call)
+	if values == nil {
+		return nil, err
+	}
+
+	check := &QuotaUsageCheck{Key: call.key}
+	if code == usageScriptOK {
+		check.Usage, check.TotalUsage = call.usage, values[0]
+	}
+	return check, err
+}
+
+// reduceUsageCall is the reduce script of a request
+type reduceUsageCall struct {
+	key     string
+	usage   int64
+	subject string
+	script  *usageScript
+}
+
+// prepare resolves the keys and the rules of the request into the reduce script, the call is nil when the quota is not found
+func (q *atomicReduceQuotaUsage) prepare(ctx context.Context, req *QuotaUsageRequest) (*reduceUsageCall, error) {
+	quotaReq := &QuotaRequest{QuotaID: req.QuotaID, Data: req.Data}
+	key, err := q.getQuotaUsageKey.Do(ctx, quotaReq)
+	if errors.Is(err, ErrQuotaNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	usage, err := getUsage(ctx, req, q.option.UsageCost, q.option.ModifiedUsage)
+	if err != nil {
+		return nil, err
+	}
+
+	script := newUsageScript(key, usage)
+	call := &reduceUsageCall{key: key, usage: usage, script: script}
+
+	if q.getQuotaStateKey != nil {
+		stateKey, err := q.getQuotaStateKey.Do(ctx, quotaReq)
+		if err != nil {
+			return nil, err
+		}
+		script.withKey("state", stateKey)
+	}
+
+	if q.getQuotaSubject != nil {
+		if call.subject, err = getQuotaSubject(ctx, q.getQuotaSubject, quotaReq); err != nil {
+			return nil, err
+		}
+		script.withKey("subject", quotaSubjectKey(key, call.subject)).withArg("subject", call.subject)
+	}
+
+	if q.getQuotaClaimKey != nil {
+		claimKey, err := q.getQuotaClaimKey.Do(ctx, quotaReq)
+		if err != nil {
+			return nil, err
+		}
+		script.withKey("claim", claimKey)
+	}
+
+	if q.getQuotaOwnerKey != nil {
+		ownerKey, err := q.getQuotaOwnerKey.Do(ctx, quotaReq)
+		if err != nil {
+			return nil, err
+		}
+		script.withKey("owner", ownerKey)
+	}
+
+	if q.getQuotaClass != nil {
+		class, err := q.getQuotaClass.Do(ctx, quotaReq)
+		if err != nil {
+			return nil, err
+		}
+
+		classKey, err := q.getQuotaClassKey.Do(ctx, quotaReq)
+		if err != nil {
+			return nil, err
+		}
+		script.withKey("class", classKey).withArg("className", class)
+	}
+
+	if q.getQuotaThresholdKey != nil {
+		thresholdKey, err := q.getQuotaThresholdKey.Do(ctx, quotaReq)
+		if err != nil {
+			return nil, err
+		}
+		script.withKey("threshold", thresholdKey)
+	}
+
+	if q.getQuotaOverdraftKey != nil {
+		overdraftKey, err := q.getQuotaOverdraftKey.Do(ctx, quotaReq)
+		if err != nil {
+			return nil, err
+		}
+		script.withKey("overdraft", overdraftKey)
+	}
+
+	return call, nil
+}
+
+// run executes the reduce script and returns the error of the result code
+func (q *atomicReduceQuotaUsage) run(ctx context.Context, call *reduceUsageCall) (int64, []int64, error) {
+	code, values, err := call.script.run(ctx, q.cache, reduceQuotaUsageScript)
+	if err != nil {
+		return code, values, fmt.Errorf("%w: %v", ErrReduceQuotaUsage, err)
+	}
+
+	switch code {
+	case usageScriptPaused:
+		return code, values, NewQuotaStateError(call.key, QuotaDirectionReduce, QuotaStatePaused)
+	case usageScriptClosed:
+		return code, values, NewQuotaStateError(call.key, QuotaDirectionReduce, QuotaStateClosed)
+	case usageScriptRefundExceedsUsage:
+		return code, values, NewRefundExceedsUsageError(call.key, call.subject, call.usage, values[0])
+	case usageScriptInvalidMinUsage:
+		return code, values, NewInvalidMinQuotaUsageError(call.key, values[0])
+	}
+
+	return code, values, nil
+}
+
+func (q *atomicReduceQuotaUsage) reverseUsage(ctx context.Context, script *usageScript) error {
+	if _, _, er := script.run(ctx, q.cache, reverseReduceQuotaUsageScript); er != nil {
+		return fmt.Errorf("%w: %v", ErrAddQuotaUsage, er)
+	}
+	return nil
+}
+
+// NewAtomicReduceQuotaUsage checks the state and decrements the usage in a single script
+func NewAtomicReduceQuotaUsage(conf ReduceQuotaUsageConfig) UpdateQuotaUsage {
+	return newAtomicReduceQuotaUsage(conf)
+}
+
+func newAtomicReduceQuotaUsage(conf ReduceQuotaUsageConfig) *atomicReduceQuotaUsage {
+	if conf.Next == nil {
+		conf.Next = NopUpdateQuotaUsage()
+	}
+
+	return &atomicReduceQuotaUsage{
+		cache:                conf.Cache,
+		getQuotaUsageKey:     conf.GetQuotaUsageKey,
+		getQuotaStateKey:     conf.GetQuotaStateKey,
+		getQuotaSubject:      conf.GetQuotaSubject,
+		getQuotaClaimKey:     conf.GetQuotaClaimKey,
+		getQuotaOwnerKey:     conf.GetQuotaOwnerKey,
+		getQuotaThresholdKey: conf.GetQuotaThresholdKey,
+		getQuotaOverdraftKey: conf.GetQuotaOverdraftKey,
+		getQuotaClass:        conf.GetQuotaClass,
+		getQuotaClassKey:     conf.GetQuotaClassKey,
+		next:                 conf.Next,
+		option:               conf.Option,
+	}
+}
@@ -0,0 +1,84 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAtomicReduceQuotaUsage(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	mockCtrl := gomock.NewController(t)
+	mockNext := mocks.NewMockUpdateQuotaUsage(mockCtrl)
+	getCache := func(key string) string {
+		val, _ := miniRedis.Get(key)
+		return val
+	}
+
+	t.Run("SucceedReduceQuotaUsage", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		reduceQuotaUsage := andromeda.NewAtomicReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-reduce-1-%s"},
+			GetQuotaStateKey: &mockGetQuotaKey{keyFormat: "atomic-state-1-%s"},
+			Next:             mockNext,
+		})
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 3}
+		assert.Nil(t, miniRedis.Set("atomic-reduce-1-123", "5"))
+		mockNext.EXPECT().Do(ctx, req).Return("result", nil)
+
+		res, err := reduceQuotaUsage.Do(ctx, req)
+
+		assert.Equal(t, "result", res)
+		assert.Nil(t, err)
+		assert.Equal(t, "2", getCache("atomic-reduce-1-123"))
+	})
+
+	t.Run("ErrorInvalidMinQuotaUsage", func(t *testing.T) {
+		reduceQuotaUsage := andromeda.NewAtomicReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-reduce-2-%s"},
+			GetQuotaStateKey: &mockGetQuotaKey{keyFormat: "atomic-state-2-%s"},
+		})
+		assert.Nil(t, miniRedis.Set("atomic-reduce-2-123", "1"))
+
+		res, err := reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 3})
+
+		assert.Nil(t, res)
+		assert.EqualError(t, err, andromeda.NewInvalidMinQuotaUsageError("atomic-reduce-2-123", -2).Error())
+		assert.Equal(t, "1", getCache("atomic-reduce-2-123"))
+	})
+
+	t.Run("ReverseUsageWhenNextHasError", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		reduceQuotaUsage := andromeda.NewAtomicReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "atomic-reduce-3-%s"},
+			GetQuotaStateKey: &mockGetQuotaKey{keyFormat: "atomic-state-3-%s"},
+			Next:             mockNext,
+		})
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 3}
+		mockErr := errors.New("unexpected")
+		assert.Nil(t, miniRedis.Set("atomic-reduce-3-123", "5"))
+		mockNext.EXPECT().Do(ctx, req).Return(nil, mockErr)
+
+		res, err := reduceQuotaUsage.Do(ctx, req)
+
+		assert.Nil(t, res)
+		assert.EqualError(t, err, mockErr.Error())
+		assert.Equal(t, "5", getCache("atomic-reduce-3-123"))
+	})
+}
@@ -0,0 +1,196 @@
+package andromeda
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchCacheConfig .
+type BatchCacheConfig struct {
+	Cache    Cache
+	Window   time.Duration // how long the first increment of a key waits for the others, default is 1 millisecond
+	MaxBatch int           // the batch is sent before the window ends when it is full, default is 100
+	Timeout  time.Duration // timeout of sending a batch, it does not depend on the context of any increment, default is 1 second
+}
+
+// batchIncrByScript adds the increments of the batch in their arrival order,
+// the increment that would exceed its limit is rejected and the later increments are still checked
+const batchIncrByScript = usageScriptPrelude + `
+local total = tonumber(redis.call('GET', key('usage')) or '0')
+local added = 0
+local res = {0}
+for i = 1, tonumber(p.count) do
+	local value = tonumber(p['value' .. i])
+	local limit = tonumber(p['limit' .. i])
+	if limit and total + value > limit then
+		res[#res + 1] = 0
+	else
+		total = total + value
+		added = added + value
+		res[#res + 1] = 1
+	end
+	res[#res + 1] = total
+end
+
+if added ~= 0 then
+	redis.call('INCRBY', key('usage'), added)
+end
+return res
+`
+
+// batchIncr is an increment that waits for the result of its batch
+type batchIncr struct {
+	value    int64
+	limit    interface{} // not a number when there is no limit
+	done     chan struct{}
+	total    int64
+	accepted bool
+	err      error
+}
+
+// incrBatch is the increments of a key within the window
+type incrBatch struct {
+	incrs []*batchIncr
+}
+
+type batchCache struct {
+	Cache
+	window   time.Duration
+	maxBatch int
+	timeout  time.Duration
+	mu       sync.Mutex
+	batches  map[string]*incrBatch
+}
+
+func (c *batchCache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	total, _, err := c.incrBy(ctx, key, value, "-")
+	return total, err
+}
+
+func (c *batchCache) IncrByLimit(ctx context.Context, key string, value, limit int64) (int64, bool, error) {
+	return c.incrBy(ctx, key, value, limit)
+}
+
+func (c *batchCache) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return evalScript(ctx, c.Cache, script, keys, args...)
+}
+
+func (c *batchCache) EvalReadOnly(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return evalReadOnlyScript(ctx, c.Cache, script, keys, args...)
+}
+
+// incrBy adds the increment to the batch of the key and waits for the result of the batch until the context is done,
+// the increment is taken out of the batch when it is not sent yet, otherwise it waits for the result that the cache applies
+func (c *batchCache) incrBy(ctx context.Context, key string, value int64, limit interface{}) (int64, bool, error) {
+	incr := &batchIncr{value: value, limit: limit, done: make(chan struct{})}
+
+	c.mu.Lock()
+	batch, ok := c.batches[key]
+	if !ok {
+		batch = new(incrBatch)
+		c.batches[key] = batch
+		time.AfterFunc(c.window, func() { c.flush(key, batch) })
+	}
+	batch.incrs = append(batch.incrs, incr)
+	full := len(batch.incrs) >= c.maxBatch
+	c.mu.Unlock()
+
+	if full {
+		go c.flush(key, batch)
+	}
+
+	select {
+	case <-incr.done:
+		return incr.total, incr.accepted, incr.err
+	case <-ctx.Done():
+		if c.cancel(key, batch, incr) {
+			return 0, false, ctx.Err()
+		}
+	}
+
+	// the batch is in flight, its own timeout bounds the wait
+	<-incr.done
+	return incr.total, incr.accepted, incr.err
+}
+
+// cancel takes the increment out of the batch that is not sent yet, it is false when the batch is sent
+func (c *batchCache) cancel(key string, batch *incrBatch, incr *batchIncr) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.batches[key] != batch {
+		return false
+	}
+	for i, other := range batch.incrs {
+		if other == incr {
+			batch.incrs = append(batch.incrs[:i], batch.incrs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// flush sends the batch once, either when the window ends or when the batch is full
+func (c *batchCache) flush(key string, batch *incrBatch) {
+	c.mu.Lock()
+	if c.batches[key] != batch {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.batches, key)
+	c.mu.Unlock()
+
+	if len(batch.incrs) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	script := new(usageScript).withKey("usage", key).withArg("count", len(batch.incrs))
+	for i, incr := range batch.incrs {
+		script.withArg(fmt.Sprintf("value%d", i+1), incr.value).withArg(fmt.Sprintf("limit%d", i+1), incr.limit)
+	}
+
+	_, values, err := script.run(ctx, c.Cache, batchIncrByScript)
+	if err == nil && len(values) != len(batch.incrs)*2 {
+		err = fmt.Errorf("unexpected batch result %v", values)
+	}
+
+	for i, incr := range batch.incrs {
+		if err != nil {
+			incr.err = err
+		} else {
+			incr.accepted, incr.total = values[i*2] == 1, values[i*2+1]
+		}
+		close(incr.done)
+	}
+}
+
+// NewBatchCache merges the concurrent increments of the same key within the window into a single script,
+// every increment still gets its own total in the arrival order
+func NewBatchCache(conf BatchCacheConfig) Cache {
+	if conf.Cache == nil {
+		panic("Cache is required")
+	}
+	mustScriptCache(conf.Cache)
+	if conf.Window <= 0 {
+		conf.Window = time.Millisecond
+	}
+	if conf.MaxBatch <= 0 {
+		conf.MaxBatch = 100
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = time.Second
+	}
+
+	return &batchCache{
+		Cache:    conf.Cache,
+		window:   conf.Window,
+		maxBatch: conf.MaxBatch,
+		timeout:  conf.Timeout,
+		batches:  make(map[string]*incrBatch),
+	}
+}
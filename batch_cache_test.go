@@ -0,0 +1,202 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countEvalCache struct {
+	andromeda.ScriptCache
+	evals int32
+}
+
+func (c *countEvalCache) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	atomic.AddInt32(&c.evals, 1)
+	return c.ScriptCache.Eval(ctx, script, keys, args...)
+}
+
+// slowEvalCache delays the eval of the batch
+type slowEvalCache struct {
+	andromeda.ScriptCache
+	delay time.Duration
+}
+
+func (c *slowEvalCache) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	time.Sleep(c.delay)
+	return c.ScriptCache.Eval(ctx, script, keys, args...)
+}
+
+func TestBatchCache(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := &countEvalCache{ScriptCache: cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))}
+
+	t.Run("MergeConcurrentIncrements", func(t *testing.T) {
+		batchCache := andromeda.NewBatchCache(andromeda.BatchCacheConfig{Cache: redisCache, Window: time.Millisecond * 50})
+		atomic.StoreInt32(&redisCache.evals, 0)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var totals []int
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				total, err := batchCache.IncrBy(ctx, "batch-voucher-123", 1)
+				assert.Nil(t, err)
+
+				mu.Lock()
+				totals = append(totals, int(total))
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		sort.Ints(totals)
+		assert.Equal(t, []int{1, 2, 3, 4, 5}, totals)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&redisCache.evals))
+
+		usage, err := miniRedis.Get("batch-voucher-123")
+		assert.Nil(t, err)
+		assert.Equal(t, "5", usage)
+	})
+
+	t.Run("DecideInArrivalOrder", func(t *testing.T) {
+		batchCache := andromeda.NewBatchCache(andromeda.BatchCacheConfig{Cache: redisCache, Window: time.Hour, MaxBatch: 3})
+		limitCache := batchCache.(andromeda.LimitCache)
+		assert.Nil(t, miniRedis.Set("batch-voucher-456", "6"))
+
+		type result struct {
+			total    int64
+			accepted bool
+		}
+		results := make([]result, 3)
+		values := []int64{5, 3, 2}
+
+		var wg sync.WaitGroup
+		for i, value := range values {
+			wg.Add(1)
+			go func(i int, value int64) {
+				defer wg.Done()
+				total, accepted, err := limitCache.IncrByLimit(ctx, "batch-voucher-456", value, 10)
+				assert.Nil(t, err)
+				results[i] = result{total: total, accepted: accepted}
+			}(i, value)
+			time.Sleep(time.Millisecond * 10)
+		}
+		wg.Wait()
+
+		assert.Equal(t, []result{{total: 6}, {total: 9, accepted: true}, {total: 9}}, results)
+
+		usage, err := miniRedis.Get("batch-voucher-456")
+		assert.Nil(t, err)
+		assert.Equal(t, "9", usage)
+	})
+
+	t.Run("AddQuotaUsage", func(t *testing.T) {
+		batchCache := andromeda.NewBatchCache(andromeda.BatchCacheConfig{Cache: redisCache, Window: time.Millisecond * 50})
+		addQuotaUsage := andromeda.NewAddQuotaUsage(
+			batchCache,
+			&mockGetQuotaKey{keyFormat: "batch-voucher-%s"},
+			&mockGetQuota{value: 2},
+			andromeda.NopUpdateQuotaUsage(),
+			andromeda.AddUsageOption{},
+		)
+
+		var wg sync.WaitGroup
+		var exceeded int32
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "789", Usage: 1})
+				if errors.Is(err, andromeda.ErrQuotaLimitExceeded) {
+					atomic.AddInt32(&exceeded, 1)
+				} else {
+					assert.Nil(t, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), exceeded)
+
+		usage, err := miniRedis.Get("batch-voucher-789")
+		assert.Nil(t, err)
+		assert.Equal(t, "2", usage)
+	})
+
+	t.Run("SendWithoutCanceledIncrement", func(t *testing.T) {
+		batchCache := andromeda.NewBatchCache(andromeda.BatchCacheConfig{Cache: redisCache, Window: time.Millisecond * 50})
+		canceledCtx, cancel := context.WithCancel(ctx)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := batchCache.IncrBy(canceledCtx, "batch-voucher-654", 1)
+			assert.True(t, errors.Is(err, context.Canceled))
+		}()
+
+		time.Sleep(time.Millisecond * 10)
+		cancel()
+		wg.Wait()
+
+		total, err := batchCache.IncrBy(ctx, "batch-voucher-654", 2)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), total)
+	})
+
+	t.Run("WaitForBatchInFlight", func(t *testing.T) {
+		slowCache := &slowEvalCache{ScriptCache: redisCache, delay: time.Millisecond * 50}
+		batchCache := andromeda.NewBatchCache(andromeda.BatchCacheConfig{Cache: slowCache})
+		timeoutCtx, cancel := context.WithTimeout(ctx, time.Millisecond*20)
+		defer cancel()
+
+		total, err := batchCache.IncrBy(timeoutCtx, "batch-voucher-321", 1)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), total)
+
+		usage, err := miniRedis.Get("batch-voucher-321")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", usage)
+	})
+
+	t.Run("SkipRejectedBatch", func(t *testing.T) {
+		batchCache := andromeda.NewBatchCache(andromeda.BatchCacheConfig{Cache: redisCache})
+
+		total, accepted, err := batchCache.(andromeda.LimitCache).IncrByLimit(ctx, "batch-voucher-999", 5, 1)
+		assert.Nil(t, err)
+		assert.False(t, accepted)
+		assert.Equal(t, int64(0), total)
+		assert.False(t, miniRedis.Exists("batch-voucher-999"))
+	})
+
+	t.Run("FanOutError", func(t *testing.T) {
+		batchCache := andromeda.NewBatchCache(andromeda.BatchCacheConfig{Cache: redisCache, Window: time.Millisecond * 50})
+		miniRedis.Close()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := batchCache.IncrBy(ctx, "batch-voucher-123", 1)
+				assert.NotNil(t, err)
+			}()
+		}
+		wg.Wait()
+	})
+}
@@ -3,14 +3,26 @@ package andromeda
 import (
 	"context"
 	"errors"
+	"io"
+	"net"
 	"time"
 )
 
 var (
 	// ErrCacheNotFound for error cache not found
 	ErrCacheNotFound = errors.New("cache not found")
+	// ErrCacheUnavailable for error connecting to the cache
+	ErrCacheUnavailable = errors.New("cache unavailable")
+	// ErrScriptNotSupported for error running a script by the cache that does not implement ScriptCache
+	ErrScriptNotSupported = errors.New("script not supported by the cache")
 )
 
+// isCacheUnavailable checks the error of the connection to the cache, e.g. the refused dial, the timeout or the closed connection
+func isCacheUnavailable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
 type Cache interface {
 	IncrBy(ctx context.Context, key string, value int64) (int64, error)
 	DecrBy(ctx context.Context, key string, decrement int64) (int64, error)
@@ -20,3 +32,46 @@ type Cache interface {
 	Exists(ctx context.Context, keys ...string) (int64, error)
 	Del(ctx context.Context, keys ...string) (int64, error)
 }
+
+// ScriptCache is implemented by the cache that runs the Lua scripts, e.g. the caches of the cache package,
+// it is required by the atomic options of the quota usage and by the features that are built on the scripts
+type ScriptCache interface {
+	Cache
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// mustScriptCache panics when the cache does not run the scripts
+func mustScriptCache(cache Cache) {
+	if _, ok := cache.(ScriptCache); !ok {
+		panic("Cache must implement ScriptCache")
+	}
+}
+
+// evalScript runs the script by the cache, it returns ErrScriptNotSupported when the cache does not implement ScriptCache
+func evalScript(ctx context.Context, cache Cache, script string, keys []string, args ...interface{}) (interface{}, error) {
+	scriptCache, ok := cache.(ScriptCache)
+	if !ok {
+		return nil, ErrScriptNotSupported
+	}
+	return scriptCache.Eval(ctx, script, keys, args...)
+}
+
+// ReadOnlyScriptCache is implemented by the cache that runs the scripts that do not change their keys apart from the others,
+// e.g. NewL1Cache does not invalidate the keys of the read-only scripts
+type ReadOnlyScriptCache interface {
+	EvalReadOnly(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// evalReadOnlyScript runs the script that does not change its keys, it is run by Eval when the cache does not implement ReadOnlyScriptCache
+func evalReadOnlyScript(ctx context.Context, cache Cache, script string, keys []string, args ...interface{}) (interface{}, error) {
+	if readOnlyCache, ok := cache.(ReadOnlyScriptCache); ok {
+		return readOnlyCache.EvalReadOnly(ctx, script, keys, args...)
+	}
+	return evalScript(ctx, cache, script, keys, args...)
+}
+
+// LimitCache is implemented by the cache that checks the limit inside the increment, e.g. NewBatchCache,
+// the value is not added when the total would exceed the limit and the current total is returned
+type LimitCache interface {
+	IncrByLimit(ctx context.Context, key string, value, limit int64) (total int64, accepted bool, err error)
+}
@@ -43,7 +43,15 @@ func (c *cacheRedis) Del(ctx context.Context, keys ...string) (int64, error) {
 	return c.client.Del(ctx, keys...).Result()
 }
 
+func (c *cacheRedis) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	val, err := getScript(script).Run(ctx, c.client, keys, args...).Result()
+	if err == redis.Nil {
+		err = andromeda.ErrCacheNotFound
+	}
+	return val, err
+}
+
 // NewCacheRedis cache using redis
-func NewCacheRedis(client *redis.Client) andromeda.Cache {
+func NewCacheRedis(client *redis.Client) andromeda.ScriptCache {
 	return &cacheRedis{client: client}
 }
@@ -63,7 +63,15 @@ func (c *cacheRedisCluster) Del(ctx context.Context, keys ...string) (int64, err
 	return n, nil
 }
 
+func (c *cacheRedisCluster) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	val, err := getScript(script).Run(ctx, c.client, keys, args...).Result()
+	if err == redis.Nil {
+		err = andromeda.ErrCacheNotFound
+	}
+	return val, err
+}
+
 // NewCacheRedisCluster cache using redis cluster
-func NewCacheRedisCluster(client *redis.ClusterClient) andromeda.Cache {
+func NewCacheRedisCluster(client *redis.ClusterClient) andromeda.ScriptCache {
 	return &cacheRedisCluster{client: client}
 }
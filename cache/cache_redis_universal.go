@@ -43,7 +43,15 @@ func (c *cacheRedisUniversal) Del(ctx context.Context, keys ...string) (int64, e
 	return c.client.Del(ctx, keys...).Result()
 }
 
+func (c *cacheRedisUniversal) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	val, err := getScript(script).Run(ctx, c.client, keys, args...).Result()
+	if err == redis.Nil {
+		err = andromeda.ErrCacheNotFound
+	}
+	return val, err
+}
+
 // NewCacheRedisUniversal cache using redis
-func NewCacheRedisUniversal(client redis.UniversalClient) andromeda.Cache {
+func NewCacheRedisUniversal(client redis.UniversalClient) andromeda.ScriptCache {
 	return &cacheRedisUniversal{client: client}
 }
@@ -112,4 +112,50 @@ func TestCacheRedisUniversal(t *testing.T) {
 		assert.Equal(t, int64(0), exists)
 		assert.Nil(t, err)
 	})
+
+	t.Run("Eval", func(t *testing.T) {
+		key := "123-7"
+		script := `return redis.call('INCRBY', KEYS[1], ARGV[1])`
+
+		res, err := redisCache.Eval(ctx, script, []string{key}, 5)
+
+		assert.Equal(t, int64(5), res)
+		assert.Nil(t, err)
+
+		res, err = redisCache.Eval(ctx, script, []string{key}, 5)
+
+		assert.Equal(t, int64(10), res)
+		assert.Nil(t, err)
+	})
+
+	t.Run("EvalErrCacheNotFound", func(t *testing.T) {
+		key := "123-8"
+
+		res, err := redisCache.Eval(ctx, `return redis.call('GET', KEYS[1])`, []string{key})
+
+		assert.Nil(t, res)
+		assert.Equal(t, andromeda.ErrCacheNotFound, err)
+	})
+
+	t.Run("MinorUnitsWithinScriptPrecision", func(t *testing.T) {
+		key := "123-9"
+		script := `local total = tonumber(redis.call('GET', KEYS[1]) or '0') + tonumber(ARGV[1])
+if total > tonumber(ARGV[2]) then return 0 end
+return redis.call('INCRBY', KEYS[1], ARGV[1])`
+
+		total, err := redisCache.IncrBy(ctx, key, andromeda.MaxDecimalUnits-1)
+
+		assert.Equal(t, int64(andromeda.MaxDecimalUnits-1), total)
+		assert.Nil(t, err)
+
+		res, err := redisCache.Eval(ctx, script, []string{key}, 1, andromeda.MaxDecimalUnits)
+
+		assert.Equal(t, int64(andromeda.MaxDecimalUnits), res)
+		assert.Nil(t, err)
+
+		res, err = redisCache.Eval(ctx, script, []string{key}, 1, andromeda.MaxDecimalUnits)
+
+		assert.Equal(t, int64(0), res)
+		assert.Nil(t, err)
+	})
 }
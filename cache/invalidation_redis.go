@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/ramadani/andromeda"
+	"strings"
+)
+
+type pubSubInvalidation struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+func (i *pubSubInvalidation) Publish(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if err := i.client.Publish(ctx, i.channel, key).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *pubSubInvalidation) Subscribe(ctx context.Context, invalidate func(key string)) error {
+	return subscribe(ctx, i.client.Subscribe(ctx, i.channel), func(msg *redis.Message) {
+		invalidate(msg.Payload)
+	})
+}
+
+type keyspaceInvalidation struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// Publish does nothing, the cache server notifies the changed keys by itself
+func (i *keyspaceInvalidation) Publish(_ context.Context, _ ...string) error {
+	return nil
+}
+
+func (i *keyspaceInvalidation) Subscribe(ctx context.Context, invalidate func(key string)) error {
+	return subscribe(ctx, i.client.PSubscribe(ctx, i.prefix+"*"), func(msg *redis.Message) {
+		invalidate(strings.TrimPrefix(msg.Channel, i.prefix))
+	})
+}
+
+func subscribe(ctx context.Context, pubSub *redis.PubSub, receive func(msg *redis.Message)) error {
+	defer pubSub.Close()
+
+	if _, err := pubSub.Receive(ctx); err != nil {
+		return err
+	}
+
+	messages := pubSub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			receive(msg)
+		}
+	}
+}
+
+// NewPubSubInvalidation invalidates the L1 cache of every process by publishing the changed keys to the channel
+func NewPubSubInvalidation(client redis.UniversalClient, channel string) andromeda.CacheInvalidation {
+	return &pubSubInvalidation{client: client, channel: channel}
+}
+
+// NewKeyspaceInvalidation invalidates the L1 cache of every process by the keyspace notifications of the database,
+// the server must notify them, e.g. notify-keyspace-events K$, and the keys that are changed by any client are invalidated
+func NewKeyspaceInvalidation(client redis.UniversalClient, db int) andromeda.CacheInvalidation {
+	return &keyspaceInvalidation{client: client, prefix: fmt.Sprintf("__keyspace@%d__:", db)}
+}
@@ -0,0 +1,51 @@
+package cache_test
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestInvalidationRedis(t *testing.T) {
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	client := redis.NewClient(&redis.Options{Addr: miniRedis.Addr()})
+
+	t.Run("PubSub", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		defer cancel()
+
+		invalidation := cache.NewPubSubInvalidation(client, "quota-usage-changed")
+		keys := make(chan string, 1)
+		go func() { _ = invalidation.Subscribe(ctx, func(key string) { keys <- key }) }()
+
+		assert.Eventually(t, func() bool {
+			return len(miniRedis.PubSubChannels("quota-usage-changed")) == 1
+		}, time.Second, time.Millisecond*10)
+
+		assert.Nil(t, invalidation.Publish(ctx, "123-1"))
+		assert.Equal(t, "123-1", <-keys)
+	})
+
+	t.Run("Keyspace", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		defer cancel()
+
+		invalidation := cache.NewKeyspaceInvalidation(client, 0)
+		keys := make(chan string, 1)
+		go func() { _ = invalidation.Subscribe(ctx, func(key string) { keys <- key }) }()
+
+		assert.Eventually(t, func() bool {
+			return miniRedis.PubSubNumPat() == 1
+		}, time.Second, time.Millisecond*10)
+
+		assert.Nil(t, invalidation.Publish(ctx, "123-2"))
+		miniRedis.Publish("__keyspace@0__:123-2", "incrby")
+		assert.Equal(t, "123-2", <-keys)
+	})
+}
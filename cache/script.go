@@ -0,0 +1,18 @@
+package cache
+
+import (
+	"github.com/go-redis/redis/v8"
+	"sync"
+)
+
+var scripts sync.Map
+
+// getScript returns the loaded script, so the script is run by EVALSHA instead of sending its source every time
+func getScript(src string) *redis.Script {
+	if script, ok := scripts.Load(src); ok {
+		return script.(*redis.Script)
+	}
+
+	script, _ := scripts.LoadOrStore(src, redis.NewScript(src))
+	return script.(*redis.Script)
+}
@@ -0,0 +1,27 @@
+package andromeda
+
+import "context"
+
+// checkUpdateQuotaUsage ends the warm up chain with the check, the result is the check of the quota usage
+type checkUpdateQuotaUsage struct {
+	checkQuotaUsage CheckQuotaUsage
+}
+
+func (q *checkUpdateQuotaUsage) Do(ctx context.Context, req *QuotaUsageRequest) (interface{}, error) {
+	return q.checkQuotaUsage.Check(ctx, req)
+}
+
+type checkQuotaUsage struct {
+	updateQuotaUsage UpdateQuotaUsage
+}
+
+func (q *checkQuotaUsage) Check(ctx context.Context, req *QuotaUsageRequest) (*QuotaUsageCheck, error) {
+	res, err := q.updateQuotaUsage.Do(ctx, req)
+	check, _ := res.(*QuotaUsageCheck)
+
+	return check, err
+}
+
+func newCheckQuotaUsage(withWarmUp func(UpdateQuotaUsage) UpdateQuotaUsage, checker CheckQuotaUsage) CheckQuotaUsage {
+	return &checkQuotaUsage{updateQuotaUsage: withWarmUp(&checkUpdateQuotaUsage{checkQuotaUsage: checker})}
+}
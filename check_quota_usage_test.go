@@ -0,0 +1,98 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckAddQuotaUsage(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	checkAddQuotaUsage := andromeda.CheckAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+		Cache:                     redisCache,
+		GetQuotaLimit:             &mockGetQuota{value: 10},
+		GetQuotaUsage:             &mockGetQuota{value: 4},
+		GetQuotaUsageKey:          &mockGetQuotaKey{keyFormat: "check-usage-%s"},
+		GetQuotaUsageExpiration:   &mockGetQuotaExp{},
+		GetQuotaStateKey:          &mockGetQuotaKey{keyFormat: "check-state-%s"},
+		GetQuotaSubject:           &mockGetQuotaSubject{},
+		GetQuotaSubjectLimit:      &mockGetQuota{value: 2},
+		GetQuotaSubjectExpiration: &mockGetQuotaExp{},
+	})
+	getCache := func(key string) string {
+		val, _ := miniRedis.Get(key)
+		return val
+	}
+
+	t.Run("WouldSucceed", func(t *testing.T) {
+		check, err := checkAddQuotaUsage.Check(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, &andromeda.QuotaUsageCheck{Key: "check-usage-123", Usage: 2, TotalUsage: 6, Limit: 10, Remaining: 4}, check)
+		assert.Equal(t, "4", getCache("check-usage-123"))
+		assert.False(t, miniRedis.Exists("check-usage-123-subject-user-1"))
+	})
+
+	t.Run("WouldExceedSubjectLimit", func(t *testing.T) {
+		check, err := checkAddQuotaUsage.Check(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 3})
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaSubjectLimitExceeded))
+		assert.Equal(t, "check-usage-123", check.Key)
+		assert.Equal(t, int64(0), check.Usage)
+	})
+
+	t.Run("WouldExceedLimit", func(t *testing.T) {
+		miniRedis.Set("check-usage-123", "9")
+
+		check, err := checkAddQuotaUsage.Check(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 2})
+
+		assert.EqualError(t, err, andromeda.NewQuotaLimitExceededError("check-usage-123", 10, 9).Error())
+		assert.Equal(t, &andromeda.QuotaUsageCheck{Key: "check-usage-123", TotalUsage: 9, Limit: 10, Remaining: 1}, check)
+		assert.Equal(t, "9", getCache("check-usage-123"))
+	})
+
+	t.Run("WouldBePaused", func(t *testing.T) {
+		miniRedis.HSet("check-state-123", "add", "paused")
+
+		_, err := checkAddQuotaUsage.Check(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 1})
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaPaused))
+	})
+}
+
+func TestCheckReduceQuotaUsage(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	checkReduceQuotaUsage := andromeda.CheckReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+		Cache:            redisCache,
+		GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "check-reduce-%s"},
+	})
+	assert.Nil(t, miniRedis.Set("check-reduce-123", "3"))
+
+	t.Run("WouldSucceed", func(t *testing.T) {
+		check, err := checkReduceQuotaUsage.Check(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, &andromeda.QuotaUsageCheck{Key: "check-reduce-123", Usage: 2, TotalUsage: 1}, check)
+		val, _ := miniRedis.Get("check-reduce-123")
+		assert.Equal(t, "3", val)
+	})
+
+	t.Run("WouldBeInvalidMinQuotaUsage", func(t *testing.T) {
+		_, err := checkReduceQuotaUsage.Check(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 4})
+
+		assert.EqualError(t, err, andromeda.NewInvalidMinQuotaUsageError("check-reduce-123", -1).Error())
+	})
+}
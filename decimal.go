@@ -0,0 +1,103 @@
+package andromeda
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxDecimalUnits is the max minor units of a decimal, the scripts compare the usage as Lua numbers
+// that keep the integers exactly up to 2^53-1
+const MaxDecimalUnits = 1<<53 - 1
+
+// ParseDecimal parses the decimal value into the minor units of the scale without rounding, e.g. "12.5" with scale 2 is 1250
+func ParseDecimal(value string, scale int) (int64, error) {
+	if !validDecimalScale(scale) {
+		return 0, fmt.Errorf("%w: scale %d", ErrInvalidDecimal, scale)
+	}
+
+	sign, digits := int64(1), value
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = -1, digits[1:]
+	}
+
+	whole, fraction := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		whole, fraction = digits[:i], digits[i+1:]
+	}
+	if whole == "" || len(fraction) > scale || strings.ContainsAny(whole+fraction, "+-") {
+		return 0, fmt.Errorf("%w: %q with scale %d", ErrInvalidDecimal, value, scale)
+	}
+
+	units, err := strconv.ParseInt(whole+fraction+strings.Repeat("0", scale-len(fraction)), 10, 64)
+	if err != nil || units > MaxDecimalUnits {
+		return 0, fmt.Errorf("%w: %q with scale %d", ErrInvalidDecimal, value, scale)
+	}
+	return sign * units, nil
+}
+
+// validDecimalScale keeps a whole unit of the scale within the max minor units
+func validDecimalScale(scale int) bool {
+	return scale >= 0 && scale <= 15
+}
+
+// FormatDecimal formats the minor units of the scale into the decimal value, e.g. 1250 with scale 2 is "12.50"
+func FormatDecimal(units int64, scale int) string {
+	if scale <= 0 {
+		return strconv.FormatInt(units, 10)
+	}
+
+	sign, digits := "", strconv.FormatInt(units, 10)
+	if units < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+type decimalQuota struct {
+	getDecimalQuota GetDecimalQuota
+	scale           int
+}
+
+func (q *decimalQuota) Do(ctx context.Context, req *QuotaRequest) (int64, error) {
+	value, err := q.getDecimalQuota.Do(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseDecimal(value, q.scale)
+}
+
+// NewDecimalQuota gets the decimal limit or usage in the minor units of the scale
+func NewDecimalQuota(getDecimalQuota GetDecimalQuota, scale int) GetQuota {
+	if !validDecimalScale(scale) {
+		panic("Scale must be between 0 and 15")
+	}
+	return &decimalQuota{getDecimalQuota: getDecimalQuota, scale: scale}
+}
+
+type decimalUsageCost struct {
+	getDecimalUsage GetDecimalUsage
+	scale           int
+}
+
+func (q *decimalUsageCost) Do(ctx context.Context, req *QuotaUsageRequest) (int64, error) {
+	value, err := q.getDecimalUsage.Do(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseDecimal(value, q.scale)
+}
+
+// NewDecimalUsageCost computes the decimal usage of the request in the minor units of the scale
+func NewDecimalUsageCost(getDecimalUsage GetDecimalUsage, scale int) UsageCost {
+	if !validDecimalScale(scale) {
+		panic("Scale must be between 0 and 15")
+	}
+	return &decimalUsageCost{getDecimalUsage: getDecimalUsage, scale: scale}
+}
@@ -0,0 +1,119 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		value string
+		scale int
+		units int64
+		err   error
+	}{
+		{value: "12.5", scale: 2, units: 1250},
+		{value: "0.07", scale: 2, units: 7},
+		{value: "-3.10", scale: 2, units: -310},
+		{value: "100", scale: 0, units: 100},
+		{value: "0.1", scale: 1, units: 1},
+		{value: "1.005", scale: 2, err: andromeda.ErrInvalidDecimal},
+		{value: ".5", scale: 2, err: andromeda.ErrInvalidDecimal},
+		{value: "1e3", scale: 2, err: andromeda.ErrInvalidDecimal},
+		{value: "92233720368547758.08", scale: 2, err: andromeda.ErrInvalidDecimal},
+		{value: "90071992547409.91", scale: 2, units: andromeda.MaxDecimalUnits},
+		{value: "90071992547409.92", scale: 2, err: andromeda.ErrInvalidDecimal},
+		{value: "1", scale: 16, err: andromeda.ErrInvalidDecimal},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			units, err := andromeda.ParseDecimal(test.value, test.scale)
+
+			assert.Equal(t, test.units, units)
+			assert.True(t, errors.Is(err, test.err))
+		})
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	assert.Equal(t, "12.50", andromeda.FormatDecimal(1250, 2))
+	assert.Equal(t, "0.07", andromeda.FormatDecimal(7, 2))
+	assert.Equal(t, "-3.10", andromeda.FormatDecimal(-310, 2))
+	assert.Equal(t, "100", andromeda.FormatDecimal(100, 0))
+}
+
+func TestDecimalScale(t *testing.T) {
+	assert.Panics(t, func() { andromeda.NewDecimalQuota(nil, 16) })
+	assert.Panics(t, func() { andromeda.NewDecimalUsageCost(nil, -1) })
+}
+
+func TestDecimalQuota(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	mockCtrl := gomock.NewController(t)
+	mockGetDecimalQuota := mocks.NewMockGetDecimalQuota(mockCtrl)
+	mockGetDecimalUsage := mocks.NewMockGetDecimalUsage(mockCtrl)
+	getQuotaLimit := andromeda.NewDecimalQuota(mockGetDecimalQuota, 2)
+	addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+		Cache:            redisCache,
+		GetQuotaLimit:    getQuotaLimit,
+		GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "decimal-usage-%s"},
+		Option:           andromeda.AddUsageOption{UsageCost: andromeda.NewDecimalUsageCost(mockGetDecimalUsage, 2)},
+	})
+	getQuotaStatus := andromeda.NewGetQuotaStatus(andromeda.GetQuotaStatusConfig{
+		Cache:            redisCache,
+		GetQuotaLimit:    getQuotaLimit,
+		GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "decimal-usage-%s"},
+		Scale:            2,
+	})
+	req := &andromeda.QuotaRequest{QuotaID: "123"}
+
+	t.Run("AddWithoutDrift", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		mockGetDecimalQuota.EXPECT().Do(ctx, req).Return("0.30", nil).Times(3)
+		mockGetDecimalUsage.EXPECT().Do(ctx, gomock.Any()).Return("0.1", nil).Times(3)
+
+		for i := 0; i < 3; i++ {
+			_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123"})
+
+			assert.Nil(t, err)
+		}
+	})
+
+	t.Run("ErrorQuotaLimitExceeded", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		mockGetDecimalQuota.EXPECT().Do(ctx, req).Return("0.30", nil)
+		mockGetDecimalUsage.EXPECT().Do(ctx, gomock.Any()).Return("0.01", nil)
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123"})
+
+		assert.EqualError(t, err, andromeda.NewQuotaLimitExceededError("decimal-usage-123", 30, 30).Error())
+	})
+
+	t.Run("StatusInTheSameUnit", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		mockGetDecimalQuota.EXPECT().Do(ctx, req).Return("1.00", nil)
+
+		status, err := getQuotaStatus.Do(ctx, req)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1.00", status.DecimalLimit())
+		assert.Equal(t, "0.30", status.DecimalUsage())
+		assert.Equal(t, "0.70", status.DecimalRemaining())
+	})
+}
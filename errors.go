@@ -11,10 +11,46 @@ var (
 	ErrReduceQuotaUsage = errors.New("error reducing quota usage")
 	// ErrQuotaLimitExceeded is error for quota exceeded
 	ErrQuotaLimitExceeded = errors.New("quota limit exceeded")
+	// ErrQuotaSubjectLimitExceeded is error for quota of a subject exceeded
+	ErrQuotaSubjectLimitExceeded = errors.New("quota subject limit exceeded")
+	// ErrQuotaClassLimitExceeded is error for quota of a priority class exceeded
+	ErrQuotaClassLimitExceeded = errors.New("quota class limit exceeded")
+	// ErrAlreadyClaimed is error for subject that already claimed the quota
+	ErrAlreadyClaimed = errors.New("already claimed")
+	// ErrRefundExceedsUsage is error for refund that exceeds the usage of the subject
+	ErrRefundExceedsUsage = errors.New("refund exceeds usage")
 	// ErrInvalidMinQuotaUsage is error for invalid minimum quota usage
 	ErrInvalidMinQuotaUsage = errors.New("invalid minimum quota usage")
+	// ErrInvalidUsageCost is error for zero, negative or overflowed usage cost
+	ErrInvalidUsageCost = errors.New("invalid usage cost")
+	// ErrInvalidDecimal is error for invalid decimal value
+	ErrInvalidDecimal = errors.New("invalid decimal")
+	// ErrInvalidTicket is error for forged ticket or ticket of the other quota or subject
+	ErrInvalidTicket = errors.New("invalid ticket")
+	// ErrTicketExpired is error for ticket that is not used in time after it is admitted
+	ErrTicketExpired = errors.New("ticket expired")
+	// ErrTicketNotAdmitted is error for ticket that waits to be admitted
+	ErrTicketNotAdmitted = errors.New("ticket not admitted")
+	// ErrTicketUsed is error for ticket that is already used
+	ErrTicketUsed = errors.New("ticket used")
+	// ErrLeaseNotFound is error for lease that is expired or released
+	ErrLeaseNotFound = errors.New("lease not found")
+	// ErrLeaseClosed is error for leased quota usage that is closed
+	ErrLeaseClosed = errors.New("lease closed")
 	// ErrLockedKey is error for locked key
 	ErrLockedKey = errors.New("locked key")
 	// ErrMaxRetryExceeded is error for max retry exceeded
 	ErrMaxRetryExceeded = errors.New("max retry exceeded")
+	// ErrQuotaPaused is error for paused quota
+	ErrQuotaPaused = errors.New("quota paused")
+	// ErrQuotaClosed is error for closed quota
+	ErrQuotaClosed = errors.New("quota closed")
+	// ErrQuotaNotOpen is error for quota that is not opened yet
+	ErrQuotaNotOpen = errors.New("quota not open")
+	// ErrInvalidQuotaClass is error for the class of a request that is not in the classes of the quota or the shares above 100 percent
+	ErrInvalidQuotaClass = errors.New("invalid quota class")
+	// ErrInvalidQuotaSubject is error for empty subject of a quota
+	ErrInvalidQuotaSubject = errors.New("invalid quota subject")
+	// ErrInvalidQuotaKey is error for invalid quota key
+	ErrInvalidQuotaKey = errors.New("invalid quota key")
 )
@@ -5,7 +5,9 @@ go 1.16
 replace github.com/ramadani/andromeda => ../../
 
 require (
-	github.com/jmoiron/sqlx v1.3.4 // indirect
+	github.com/go-redis/redis/v8 v8.8.3
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/jmoiron/sqlx v1.3.4
 	github.com/labstack/echo/v4 v4.3.0 // indirect
 	github.com/ramadani/andromeda v0.1.0
 	github.com/satori/go.uuid v1.2.0 // indirect
@@ -77,11 +77,15 @@ func main() {
 	redisClient := redis.NewClient(&redis.Options{Addr: conf.Redis.Address})
 	cacheRedis := cache.NewCacheRedis(redisClient)
 
-	keyUsageFormat := "voucher-quota-usage-%s"
 	getVoucherQuotaLimit := internal.NewGetVoucherQuotaLimit(voucherRepo)
 	getVoucherQuotaUsage := internal.NewGetVoucherQuotaUsage(voucherRepo)
-	getVoucherQuotaUsageKey := internal.NewGetVoucherQuotaUsageKey(keyUsageFormat)
+	getVoucherQuotaUsageKey := andromeda.NewKeyBuilder(andromeda.KeyBuilderConfig{
+		Namespace: "andromeda",
+		Template:  "voucher-{{.QuotaID}}",
+		Kind:      "usage",
+	})
 	getVoucherQuotaUsageExpiration := internal.NewGetVoucherQuotaUsageExpiration()
+	getVoucherQuotaLimitKey := getVoucherQuotaUsageKey.Kind("limit")
 	getCachedVoucherQuotaUsage := andromeda.NewGetCachedQuota(cacheRedis, getVoucherQuotaUsageKey)
 	getVoucherQuotaUsageConf := andromeda.GetQuotaUsageConfig{
 		LockIn:   conf.QuotaUsageConfig.LockIn,
@@ -97,6 +101,8 @@ func main() {
 		GetQuotaUsageKey:        getVoucherQuotaUsageKey,
 		GetQuotaUsageExpiration: getVoucherQuotaUsageExpiration,
 		GetQuotaUsageConfig:     getVoucherQuotaUsageConf,
+		GetQuotaLimitKey:        getVoucherQuotaLimitKey,
+		GetQuotaLimitExpiration: getVoucherQuotaUsageExpiration,
 		Option: andromeda.AddUsageOption{
 			Listener: updateVoucherQuotaUsageListener,
 		},
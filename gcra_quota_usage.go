@@ -0,0 +1,280 @@
+package andromeda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// GCRAQuotaUsageConfig .
+type GCRAQuotaUsageConfig struct {
+	Next             UpdateQuotaUsage
+	Cache            Cache         // optional, the usage is limited in memory when it is not set or it is unavailable
+	GetQuotaLimit    GetQuota      // emissions per period
+	GetQuotaUsageKey GetQuotaKey   // keeps the theoretical arrival time
+	GetQuotaBurst    GetQuota      // optional, emissions that are allowed at once, default is 1
+	Period           time.Duration // the emission interval is the period divided by the limit
+	Option           AddUsageOption
+}
+
+// gcraQuotaUsageScript keeps the theoretical arrival time in microseconds of the cache server,
+// the usage is allowed when it does not arrive earlier than the burst
+const gcraQuotaUsageScript = usageScriptPrelude + `
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(p.interval)
+local tat = math.max(tonumber(redis.call('GET', key('usage')) or now), now)
+local newTat = tat + interval * tonumber(p.usage)
+local allowAt = newTat - interval * tonumber(p.burst)
+if now < allowAt then
+	return {1, allowAt - now}
+end
+
+redis.call('SET', key('usage'), string.format('%.0f', newTat), 'PX', math.ceil((newTat - now) / 1000))
+return {0, newTat - now}
+`
+
+// reverseGCRAQuotaUsageScript gives the usage back without moving the theoretical arrival time before now
+const reverseGCRAQuotaUsageScript = usageScriptPrelude + `
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call('GET', key('usage')) or now) - tonumber(p.interval) * tonumber(p.usage)
+if tat <= now then
+	redis.call('DEL', key('usage'))
+else
+	redis.call('SET', key('usage'), string.format('%.0f', tat), 'PX', math.ceil((tat - now) / 1000))
+end
+return {0, 0}
+`
+
+type gcraQuotaUsage struct {
+	cache            Cache
+	getQuotaLimit    GetQuota
+	getQuotaUsageKey GetQuotaKey
+	getQuotaBurst    GetQuota
+	period           time.Duration
+	memory           *gcraMemory
+	next             UpdateQuotaUsage
+	option           AddUsageOption
+}
+
+func (q *gcraQuotaUsage) Do(ctx context.Context, req *QuotaUsageRequest) (res interface{}, err error) {
+	var totalUsage int64
+	var isNextErr bool
+
+	defer func() {
+		if q.option.Listener != nil && !isNextErr {
+			if err == nil {
+				q.option.Listener.OnSuccess(ctx, req, totalUsage)
+			} else {
+				q.option.Listener.OnError(ctx, req, err)
+			}
+		}
+	}()
+
+	quotaReq := &QuotaRequest{QuotaID: req.QuotaID, Data: req.Data}
+	key, err := q.getQuotaUsageKey.Do(ctx, quotaReq)
+	if errors.Is(err, ErrQuotaNotFound) {
+		return q.next.Do(ctx, req)
+	} else if err != nil {
+		return
+	}
+
+	usage, err := getUsage(ctx, req, q.option.UsageCost, q.option.ModifiedUsage)
+	if err != nil {
+		return
+	}
+
+	limit, err := q.getQuotaLimit.Do(ctx, quotaReq)
+	if err != nil {
+		return
+	}
+
+	burst := int64(1)
+	if q.getQuotaBurst != nil {
+		if burst, err = q.getQuotaBurst.Do(ctx, quotaReq); err != nil {
+			return
+		}
+	}
+
+	if limit <= 0 || usage > burst {
+		err = fmt.Errorf("%w: usage %d above burst %d for key %s", ErrQuotaLimitExceeded, usage, burst, key)
+		return
+	}
+
+	interval := q.period.Microseconds() / limit
+	if interval < 1 {
+		interval = 1
+	}
+	script := newUsageScript(key, usage).withArg("interval", interval).withArg("burst", burst)
+	code, values, err := q.allow(ctx, req, script, key, usage, interval, burst)
+	if err != nil {
+		return
+	} else if code == usageScriptLimitExceeded {
+		err = NewQuotaRateLimitError(key, limit, q.period, time.Duration(values[0])*time.Microsecond)
+		return
+	}
+
+	// the usage in the bucket that is not leaked yet
+	totalUsage = (values[0] + interval - 1) / interval
+
+	res, _err := q.next.Do(ctx, req)
+	if _err != nil {
+		isNextErr = true
+
+		if !q.option.Irreversible {
+			if er := q.reverseUsage(ctx, req, script, key, usage, interval); er != nil {
+				err, _err = er, er
+				isNextErr = false
+			}
+		}
+	}
+
+	return res, _err
+}
+
+// allow runs the script, the usage is limited in memory when the cache is not set or it is unavailable,
+// the other errors of the cache are returned
+func (q *gcraQuotaUsage) allow(ctx context.Context, req *QuotaUsageRequest, script *usageScript, key string, usage, interval, burst int64) (int64, []int64, error) {
+	if q.cache != nil {
+		code, values, err := script.run(ctx, q.cache, gcraQuotaUsageScript)
+		if err == nil {
+			return code, values, nil
+		} else if !isCacheUnavailable(err) {
+			return code, values, fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+		}
+		q.fallback(ctx, req, err)
+	}
+
+	code, values := q.memory.do(key, usage, interval, burst)
+	return code, values, nil
+}
+
+func (q *gcraQuotaUsage) reverseUsage(ctx context.Context, req *QuotaUsageRequest, script *usageScript, key string, usage, interval int64) error {
+	if q.cache != nil {
+		_, _, err := script.run(ctx, q.cache, reverseGCRAQuotaUsageScript)
+		if err == nil {
+			return nil
+		} else if !isCacheUnavailable(err) {
+			return fmt.Errorf("%w: %v", ErrReduceQuotaUsage, err)
+		}
+		q.fallback(ctx, req, err)
+	}
+
+	q.memory.reverse(key, usage, interval)
+	return nil
+}
+
+// fallback reports the unavailable cache to the listener before the usage is limited in memory
+func (q *gcraQuotaUsage) fallback(ctx context.Context, req *QuotaUsageRequest, err error) {
+	if listener, ok := q.option.Listener.(QuotaFallbackListener); ok {
+		listener.OnFallback(ctx, req, fmt.Errorf("%w: limited in memory: %v", ErrCacheUnavailable, err))
+	}
+}
+
+// gcraMemory is the in-memory fallback of the theoretical arrival time by key
+type gcraMemory struct {
+	mu        sync.Mutex
+	tats      map[string]int64
+	now       func() time.Time
+	sweptAt   int64
+	sweepEach int64
+}
+
+func (m *gcraMemory) do(key string, usage, interval, burst int64) (int64, []int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now().UnixNano() / int64(time.Microsecond)
+	m.sweep(now)
+
+	tat := m.tats[key]
+	if tat < now {
+		tat = now
+	}
+
+	newTat := tat + interval*usage
+	if allowAt := newTat - interval*burst; now < allowAt {
+		return usageScriptLimitExceeded, []int64{allowAt - now}
+	}
+
+	m.tats[key] = newTat
+	return usageScriptOK, []int64{newTat - now}
+}
+
+func (m *gcraMemory) reverse(key string, usage, interval int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if tat, ok := m.tats[key]; ok {
+		m.tats[key] = tat - interval*usage
+	}
+}
+
+// sweep removes the arrival times that are already passed
+func (m *gcraMemory) sweep(now int64) {
+	if now-m.sweptAt < m.sweepEach {
+		return
+	}
+
+	for key, tat := range m.tats {
+		if tat <= now {
+			delete(m.tats, key)
+		}
+	}
+	m.sweptAt = now
+}
+
+// QuotaRateLimitError is error for the usage that arrives earlier than the emission interval allows
+type QuotaRateLimitError struct {
+	Key        string
+	Limit      int64
+	Period     time.Duration
+	RetryAfter time.Duration
+}
+
+func (e *QuotaRateLimitError) Error() string {
+	return fmt.Sprintf("%v: limit %d per %s for key %s, retry after %s", ErrQuotaLimitExceeded, e.Limit, e.Period, e.Key, e.RetryAfter)
+}
+
+func (e *QuotaRateLimitError) Unwrap() error {
+	return ErrQuotaLimitExceeded
+}
+
+// NewQuotaRateLimitError is a error helper for the usage that arrives earlier than the emission interval allows
+func NewQuotaRateLimitError(key string, limit int64, period, retryAfter time.Duration) error {
+	return &QuotaRateLimitError{Key: key, Limit: limit, Period: period, RetryAfter: retryAfter}
+}
+
+// NewGCRAQuotaUsage limits the rate of the usage by the generic cell rate algorithm in a single script,
+// the usage is smoothed to the limit per period instead of counted
+func NewGCRAQuotaUsage(conf GCRAQuotaUsageConfig) UpdateQuotaUsage {
+	if conf.GetQuotaLimit == nil {
+		panic("GetQuotaLimit is required")
+	}
+	if conf.GetQuotaUsageKey == nil {
+		panic("GetQuotaUsageKey is required")
+	}
+	if conf.Period <= 0 {
+		panic("Period is required")
+	}
+	if conf.Cache != nil {
+		mustScriptCache(conf.Cache)
+	}
+	if conf.Next == nil {
+		conf.Next = NopUpdateQuotaUsage()
+	}
+
+	return &gcraQuotaUsage{
+		cache:            conf.Cache,
+		getQuotaLimit:    conf.GetQuotaLimit,
+		getQuotaUsageKey: conf.GetQuotaUsageKey,
+		getQuotaBurst:    conf.GetQuotaBurst,
+		period:           conf.Period,
+		memory:           &gcraMemory{tats: make(map[string]int64), now: time.Now, sweepEach: conf.Period.Microseconds()},
+		next:             conf.Next,
+		option:           conf.Option,
+	}
+}
@@ -0,0 +1,133 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+type mockFallbackListener struct {
+	*mocks.MockUpdateQuotaUsageListener
+	*mocks.MockQuotaFallbackListener
+}
+
+func TestGCRAQuotaUsage(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	now := time.Now().Truncate(time.Second)
+	miniRedis.SetTime(now)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	mockNext := mocks.NewMockUpdateQuotaUsage(mockCtrl)
+	conf := andromeda.GCRAQuotaUsageConfig{
+		Next:             mockNext,
+		Cache:            redisCache,
+		GetQuotaLimit:    &mockGetQuota{value: 2},
+		GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "partner-rate-%s"},
+		GetQuotaBurst:    &mockGetQuota{value: 2},
+		Period:           time.Second,
+	}
+	gcraQuotaUsage := andromeda.NewGCRAQuotaUsage(conf)
+	req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1}
+
+	t.Run("AllowBurst", func(t *testing.T) {
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil).Times(2)
+
+		for i := 0; i < 2; i++ {
+			_, err := gcraQuotaUsage.Do(ctx, req)
+			assert.Nil(t, err)
+		}
+	})
+
+	t.Run("ErrorRetryAfter", func(t *testing.T) {
+		_, err := gcraQuotaUsage.Do(ctx, req)
+
+		var rateLimitErr *andromeda.QuotaRateLimitError
+		assert.True(t, errors.As(err, &rateLimitErr))
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+		assert.Equal(t, time.Millisecond*500, rateLimitErr.RetryAfter)
+		assert.EqualError(t, err, "quota limit exceeded: limit 2 per 1s for key partner-rate-123, retry after 500ms")
+	})
+
+	t.Run("AllowAfterEmissionInterval", func(t *testing.T) {
+		miniRedis.SetTime(now.Add(time.Millisecond * 500))
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil)
+
+		_, err := gcraQuotaUsage.Do(ctx, req)
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReverseWhenNextHasError", func(t *testing.T) {
+		miniRedis.SetTime(now.Add(time.Millisecond * 1000))
+		mockNext.EXPECT().Do(ctx, req).Return(nil, errors.New("error"))
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil)
+
+		_, err := gcraQuotaUsage.Do(ctx, req)
+		assert.EqualError(t, err, "error")
+
+		_, err = gcraQuotaUsage.Do(ctx, req)
+		assert.Nil(t, err)
+	})
+
+	t.Run("ErrorUsageAboveBurst", func(t *testing.T) {
+		_, err := gcraQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 3})
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+	})
+
+	t.Run("ErrorCacheWithoutFallback", func(t *testing.T) {
+		mockCache := mocks.NewMockScriptCache(mockCtrl)
+		mockCache.EXPECT().Eval(ctx, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("ERR script"))
+
+		errConf := conf
+		errConf.Cache = mockCache
+
+		_, err := andromeda.NewGCRAQuotaUsage(errConf).Do(ctx, req)
+		assert.True(t, errors.Is(err, andromeda.ErrAddQuotaUsage))
+	})
+
+	t.Run("FallbackInMemory", func(t *testing.T) {
+		mockCache := mocks.NewMockScriptCache(mockCtrl)
+		mockListener := &mockFallbackListener{
+			MockUpdateQuotaUsageListener: mocks.NewMockUpdateQuotaUsageListener(mockCtrl),
+			MockQuotaFallbackListener:    mocks.NewMockQuotaFallbackListener(mockCtrl),
+		}
+		mockCache.EXPECT().Eval(ctx, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, io.EOF).Times(2)
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil)
+		mockListener.MockQuotaFallbackListener.EXPECT().OnFallback(ctx, req, gomock.Any()).Do(func(_ context.Context, _ *andromeda.QuotaUsageRequest, err error) {
+			assert.True(t, errors.Is(err, andromeda.ErrCacheUnavailable))
+		}).Times(2)
+		mockListener.MockUpdateQuotaUsageListener.EXPECT().OnSuccess(ctx, req, int64(1))
+		mockListener.MockUpdateQuotaUsageListener.EXPECT().OnError(ctx, req, gomock.Any()).Do(func(_ context.Context, _ *andromeda.QuotaUsageRequest, err error) {
+			assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+		})
+
+		conf.Cache = mockCache
+		conf.Period = time.Minute
+		conf.GetQuotaBurst = nil
+		conf.Option = andromeda.AddUsageOption{Listener: mockListener}
+		memoryQuotaUsage := andromeda.NewGCRAQuotaUsage(conf)
+
+		_, err := memoryQuotaUsage.Do(ctx, req)
+		assert.Nil(t, err)
+
+		_, err = memoryQuotaUsage.Do(ctx, req)
+		var rateLimitErr *andromeda.QuotaRateLimitError
+		assert.True(t, errors.As(err, &rateLimitErr))
+		assert.True(t, rateLimitErr.RetryAfter > time.Second*29)
+	})
+}
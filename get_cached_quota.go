@@ -24,11 +24,7 @@ func (q *getCachedQuota) Do(ctx context.Context, req *QuotaRequest) (int64, erro
 		return 0, err
 	}
 
-	res, err := strconv.Atoi(val)
-	if err != nil {
-		return 0, err
-	}
-	return int64(res), nil
+	return strconv.ParseInt(val, 10, 64)
 }
 
 // NewGetCachedQuota .
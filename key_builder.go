@@ -0,0 +1,80 @@
+package andromeda
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// KeyBuilderConfig .
+type KeyBuilderConfig struct {
+	Namespace string // global prefix for every key, example: andromeda
+	Template  string // template of the quota entity, example: voucher-{{.QuotaID}} or voucher-{{.QuotaID}}-{{.Data.UserID}}
+	Kind      string // kind of the key, example: usage, limit, reservation or ledger
+}
+
+// KeyBuilder is a GetQuotaKey that renders the key from a template.
+// the rendered entity is wrapped in a redis cluster hash tag, so every kind of key for the same quota
+// (and every key derived from it, such as the lock key) is stored in the same slot
+type KeyBuilder interface {
+	GetQuotaKey
+	Kind(kind string) KeyBuilder
+}
+
+type keyBuilder struct {
+	namespace string
+	kind      string
+	tmpl      *template.Template
+}
+
+func (b *keyBuilder) Do(_ context.Context, req *QuotaRequest) (string, error) {
+	var buf bytes.Buffer
+	if err := b.tmpl.Execute(&buf, req); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidQuotaKey, err)
+	}
+
+	entity := buf.String()
+	if entity == "" || strings.ContainsAny(entity, "{}") {
+		return "", fmt.Errorf("%w: entity %q for quota %s", ErrInvalidQuotaKey, entity, req.QuotaID)
+	}
+
+	parts := make([]string, 0, 3)
+	if b.namespace != "" {
+		parts = append(parts, b.namespace)
+	}
+	parts = append(parts, fmt.Sprintf("{%s}", entity))
+	if b.kind != "" {
+		parts = append(parts, b.kind)
+	}
+
+	return strings.Join(parts, ":"), nil
+}
+
+func (b *keyBuilder) Kind(kind string) KeyBuilder {
+	return &keyBuilder{
+		namespace: b.namespace,
+		kind:      kind,
+		tmpl:      b.tmpl,
+	}
+}
+
+// NewKeyBuilder builds key with format namespace:{entity}:kind
+func NewKeyBuilder(conf KeyBuilderConfig) KeyBuilder {
+	text := conf.Template
+	if text == "" {
+		text = "{{.QuotaID}}"
+	}
+
+	tmpl, err := template.New("quota-key").Option("missingkey=error").Parse(text)
+	if err != nil {
+		panic(fmt.Sprintf("invalid key template: %v", err))
+	}
+
+	return &keyBuilder{
+		namespace: conf.Namespace,
+		kind:      conf.Kind,
+		tmpl:      tmpl,
+	}
+}
@@ -0,0 +1,99 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ramadani/andromeda"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestKeyBuilder(t *testing.T) {
+	ctx := context.TODO()
+
+	type claim struct {
+		UserID string
+	}
+
+	tests := []struct {
+		name string
+		conf andromeda.KeyBuilderConfig
+		kind string
+		req  *andromeda.QuotaRequest
+		key  string
+	}{
+		{
+			name: "DefaultTemplate",
+			conf: andromeda.KeyBuilderConfig{},
+			req:  &andromeda.QuotaRequest{QuotaID: "123"},
+			key:  "{123}",
+		},
+		{
+			name: "WithNamespaceAndKind",
+			conf: andromeda.KeyBuilderConfig{Namespace: "andromeda", Template: "voucher-{{.QuotaID}}", Kind: "usage"},
+			req:  &andromeda.QuotaRequest{QuotaID: "123"},
+			key:  "andromeda:{voucher-123}:usage",
+		},
+		{
+			name: "WithDerivedKind",
+			conf: andromeda.KeyBuilderConfig{Namespace: "andromeda", Template: "voucher-{{.QuotaID}}", Kind: "usage"},
+			kind: "ledger",
+			req:  &andromeda.QuotaRequest{QuotaID: "123"},
+			key:  "andromeda:{voucher-123}:ledger",
+		},
+		{
+			name: "WithStructData",
+			conf: andromeda.KeyBuilderConfig{Template: "voucher-{{.QuotaID}}-{{.Data.UserID}}", Kind: "usage"},
+			req:  &andromeda.QuotaRequest{QuotaID: "123", Data: claim{UserID: "u1"}},
+			key:  "{voucher-123-u1}:usage",
+		},
+		{
+			name: "WithMapData",
+			conf: andromeda.KeyBuilderConfig{Template: "voucher-{{.QuotaID}}-{{.Data.user}}", Kind: "usage"},
+			req:  &andromeda.QuotaRequest{QuotaID: "123", Data: map[string]string{"user": "u1"}},
+			key:  "{voucher-123-u1}:usage",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keyBuilder := andromeda.NewKeyBuilder(test.conf)
+			if test.kind != "" {
+				keyBuilder = keyBuilder.Kind(test.kind)
+			}
+
+			key, err := keyBuilder.Do(ctx, test.req)
+
+			assert.Equal(t, test.key, key)
+			assert.Nil(t, err)
+		})
+	}
+
+	t.Run("ErrorMissingDataField", func(t *testing.T) {
+		keyBuilder := andromeda.NewKeyBuilder(andromeda.KeyBuilderConfig{Template: "{{.Data.user}}"})
+
+		key, err := keyBuilder.Do(ctx, &andromeda.QuotaRequest{QuotaID: "123", Data: map[string]string{}})
+
+		assert.Equal(t, "", key)
+		assert.True(t, errors.Is(err, andromeda.ErrInvalidQuotaKey))
+	})
+
+	t.Run("ErrorEntityContainsHashTag", func(t *testing.T) {
+		keyBuilder := andromeda.NewKeyBuilder(andromeda.KeyBuilderConfig{})
+
+		key, err := keyBuilder.Do(ctx, &andromeda.QuotaRequest{QuotaID: "{123}"})
+
+		assert.Equal(t, "", key)
+		assert.True(t, errors.Is(err, andromeda.ErrInvalidQuotaKey))
+	})
+
+	t.Run("PanicInvalidTemplate", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("The code did not panic")
+			}
+		}()
+
+		andromeda.NewKeyBuilder(andromeda.KeyBuilderConfig{Template: "{{.QuotaID"})
+	})
+}
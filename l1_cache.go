@@ -0,0 +1,257 @@
+package andromeda
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// L1CacheConfig .
+type L1CacheConfig struct {
+	Cache        Cache
+	TTL          time.Duration             // how stale a read may be when an invalidation is missed, default is 100 milliseconds
+	MaxKeys      int                       // the least recently read key is evicted when it is full, default is 10000
+	Invalidation CacheInvalidation         // optional, invalidates the keys that are changed by the other processes
+	Timeout      time.Duration             // timeout of publishing the changed keys, default is 1 second
+	Listener     CacheInvalidationListener // optional, gets the error of publishing the changed keys
+}
+
+// CacheInvalidation is a contract to broadcast the keys whose usage changes to the L1 cache of every process,
+// e.g. by the pub/sub or the keyspace notifications of the cache server
+type CacheInvalidation interface {
+	Publish(ctx context.Context, keys ...string) error
+	// Subscribe calls the invalidate with every changed key until the context is done
+	Subscribe(ctx context.Context, invalidate func(key string)) error
+}
+
+// CacheInvalidationListener listen on the error of publishing the changed keys to the other processes
+type CacheInvalidationListener interface {
+	OnPublishError(ctx context.Context, keys []string, err error)
+}
+
+// L1Cache is a contract of the in-process cache in front of the cache for the status reads, e.g. NewGetCachedQuota
+type L1Cache interface {
+	Cache
+	// Listen invalidates the keys by the invalidation until the context is done, e.g. go l1Cache.Listen(ctx)
+	Listen(ctx context.Context) error
+}
+
+type l1Entry struct {
+	key       string
+	value     string
+	expiresAt time.Time
+}
+
+// l1Read is the reads of a key that are in flight, the version is increased by every invalidation of the key
+// so the read that overlaps an invalidation is not kept
+type l1Read struct {
+	readers int
+	version uint64
+}
+
+type l1Cache struct {
+	Cache
+	ttl          time.Duration
+	maxKeys      int
+	invalidation CacheInvalidation
+	timeout      time.Duration
+	listener     CacheInvalidationListener
+	now          func() time.Time
+	mu           sync.Mutex
+	entries      map[string]*list.Element
+	recent       *list.List
+	reads        map[string]*l1Read
+	changes      map[string]struct{} // the changed keys that are not published yet
+	publishing   bool
+}
+
+func (c *l1Cache) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*l1Entry)
+		if c.now().Before(entry.expiresAt) {
+			c.recent.MoveToFront(elem)
+			c.mu.Unlock()
+			return entry.value, nil
+		}
+		c.remove(elem)
+	}
+	read, ok := c.reads[key]
+	if !ok {
+		read = new(l1Read)
+		c.reads[key] = read
+	}
+	read.readers++
+	version := read.version
+	c.mu.Unlock()
+
+	val, err := c.Cache.Get(ctx, key)
+
+	c.mu.Lock()
+	if read.readers--; read.readers == 0 {
+		delete(c.reads, key)
+	}
+	if err == nil && version == read.version {
+		c.store(key, val)
+	}
+	c.mu.Unlock()
+
+	return val, err
+}
+
+func (c *l1Cache) store(key, val string) {
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	for c.recent.Len() >= c.maxKeys {
+		c.remove(c.recent.Back())
+	}
+	c.entries[key] = c.recent.PushFront(&l1Entry{key: key, value: val, expiresAt: c.now().Add(c.ttl)})
+}
+
+func (c *l1Cache) remove(elem *list.Element) {
+	c.recent.Remove(elem)
+	delete(c.entries, elem.Value.(*l1Entry).key)
+}
+
+// invalidate removes the keys from this process
+func (c *l1Cache) invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := c.entries[key]; ok {
+			c.remove(elem)
+		}
+		if read, ok := c.reads[key]; ok {
+			read.version++
+		}
+	}
+}
+
+// changed removes the keys from this process and queues them to be published to the others,
+// the keys of the failed call are not published
+func (c *l1Cache) changed(_ context.Context, err error, keys ...string) {
+	c.invalidate(keys...)
+	if err != nil || c.invalidation == nil || len(keys) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		c.changes[key] = struct{}{}
+	}
+	if !c.publishing {
+		c.publishing = true
+		go c.publish()
+	}
+}
+
+// publish publishes the queued keys in batches until the queue is empty, the keys that are changed
+// while a batch is published go into the next batch
+func (c *l1Cache) publish() {
+	for {
+		c.mu.Lock()
+		if len(c.changes) == 0 {
+			c.publishing = false
+			c.mu.Unlock()
+			return
+		}
+		keys := make([]string, 0, len(c.changes))
+		for key := range c.changes {
+			keys = append(keys, key)
+		}
+		c.changes = make(map[string]struct{})
+		c.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		if err := c.invalidation.Publish(ctx, keys...); err != nil && c.listener != nil {
+			c.listener.OnPublishError(ctx, keys, err)
+		}
+		cancel()
+	}
+}
+
+func (c *l1Cache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	total, err := c.Cache.IncrBy(ctx, key, value)
+	c.changed(ctx, err, key)
+	return total, err
+}
+
+func (c *l1Cache) DecrBy(ctx context.Context, key string, decrement int64) (int64, error) {
+	total, err := c.Cache.DecrBy(ctx, key, decrement)
+	c.changed(ctx, err, key)
+	return total, err
+}
+
+func (c *l1Cache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) (string, error) {
+	res, err := c.Cache.Set(ctx, key, value, expiration)
+	c.changed(ctx, err, key)
+	return res, err
+}
+
+func (c *l1Cache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	ok, err := c.Cache.SetNX(ctx, key, value, expiration)
+	c.changed(ctx, err, key)
+	return ok, err
+}
+
+func (c *l1Cache) Del(ctx context.Context, keys ...string) (int64, error) {
+	deleted, err := c.Cache.Del(ctx, keys...)
+	c.changed(ctx, err, keys...)
+	return deleted, err
+}
+
+func (c *l1Cache) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	res, err := evalScript(ctx, c.Cache, script, keys, args...)
+	c.changed(ctx, err, keys...)
+	return res, err
+}
+
+// EvalReadOnly runs the script that does not change its keys, so they are not invalidated
+func (c *l1Cache) EvalReadOnly(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return evalReadOnlyScript(ctx, c.Cache, script, keys, args...)
+}
+
+func (c *l1Cache) Listen(ctx context.Context) error {
+	if c.invalidation == nil {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	return c.invalidation.Subscribe(ctx, func(key string) { c.invalidate(key) })
+}
+
+// NewL1Cache caches the reads of the cache in the process for a short TTL, the keys are invalidated
+// when they are changed through it or by the invalidation
+func NewL1Cache(conf L1CacheConfig) L1Cache {
+	if conf.Cache == nil {
+		panic("Cache is required")
+	}
+	if conf.TTL <= 0 {
+		conf.TTL = time.Millisecond * 100
+	}
+	if conf.MaxKeys <= 0 {
+		conf.MaxKeys = 10000
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = time.Second
+	}
+
+	return &l1Cache{
+		Cache:        conf.Cache,
+		ttl:          conf.TTL,
+		maxKeys:      conf.MaxKeys,
+		invalidation: conf.Invalidation,
+		timeout:      conf.Timeout,
+		listener:     conf.Listener,
+		now:          time.Now,
+		entries:      make(map[string]*list.Element),
+		recent:       list.New(),
+		reads:        make(map[string]*l1Read),
+		changes:      make(map[string]struct{}),
+	}
+}
@@ -0,0 +1,254 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// blockGetCache blocks the read of the key until it is released
+type blockGetCache struct {
+	andromeda.Cache
+	key     string
+	reading chan struct{}
+	release chan struct{}
+}
+
+func (c *blockGetCache) Get(ctx context.Context, key string) (string, error) {
+	val, err := c.Cache.Get(ctx, key)
+	if key == c.key {
+		c.reading <- struct{}{}
+		<-c.release
+	}
+	return val, err
+}
+
+func newBlockedL1Cache(redisCache andromeda.Cache, key string) (andromeda.L1Cache, *blockGetCache) {
+	blockCache := &blockGetCache{Cache: redisCache, key: key, reading: make(chan struct{}, 2), release: make(chan struct{})}
+	return andromeda.NewL1Cache(andromeda.L1CacheConfig{Cache: blockCache, TTL: time.Minute}), blockCache
+}
+
+// readDuringIncr reads the blocked key while the incremented key is changed
+func readDuringIncr(t *testing.T, ctx context.Context, l1Cache andromeda.L1Cache, blockCache *blockGetCache, incremented string) {
+	read := make(chan string)
+	go func() {
+		val, _ := l1Cache.Get(ctx, blockCache.key)
+		read <- val
+	}()
+
+	<-blockCache.reading
+	_, err := l1Cache.IncrBy(ctx, incremented, 1)
+	assert.Nil(t, err)
+	close(blockCache.release)
+	assert.Equal(t, "1", <-read)
+}
+
+type failedInvalidation struct {
+	andromeda.CacheInvalidation
+}
+
+func (i *failedInvalidation) Publish(_ context.Context, _ ...string) error {
+	return errors.New("error")
+}
+
+// recordInvalidation records the published keys
+type recordInvalidation struct {
+	andromeda.CacheInvalidation
+	keys chan []string
+}
+
+func (i *recordInvalidation) Publish(_ context.Context, keys ...string) error {
+	i.keys <- keys
+	return nil
+}
+
+type publishErrorListener struct {
+	keys chan []string
+}
+
+func (l *publishErrorListener) OnPublishError(_ context.Context, keys []string, _ error) {
+	l.keys <- keys
+}
+
+func TestL1Cache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	client := redis.NewClient(&redis.Options{Addr: miniRedis.Addr()})
+	redisCache := cache.NewCacheRedis(client)
+	conf := andromeda.L1CacheConfig{
+		Cache:        redisCache,
+		TTL:          time.Minute,
+		Invalidation: cache.NewPubSubInvalidation(client, "quota-usage-changed"),
+	}
+	l1Cache := andromeda.NewL1Cache(conf)
+	otherL1Cache := andromeda.NewL1Cache(conf)
+	go func() { _ = otherL1Cache.Listen(ctx) }()
+
+	getCachedQuota := andromeda.NewGetCachedQuota(l1Cache, &mockGetQuotaKey{keyFormat: "l1-voucher-%s"})
+	quotaReq := &andromeda.QuotaRequest{QuotaID: "123"}
+
+	t.Run("ReadThrough", func(t *testing.T) {
+		assert.Nil(t, miniRedis.Set("l1-voucher-123", "5"))
+
+		usage, err := getCachedQuota.Do(ctx, quotaReq)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(5), usage)
+
+		assert.Nil(t, miniRedis.Set("l1-voucher-123", "6"))
+
+		usage, err = getCachedQuota.Do(ctx, quotaReq)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(5), usage)
+	})
+
+	t.Run("InvalidateOnWrite", func(t *testing.T) {
+		_, err := l1Cache.IncrBy(ctx, "l1-voucher-123", 1)
+		assert.Nil(t, err)
+
+		usage, err := getCachedQuota.Do(ctx, quotaReq)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(7), usage)
+	})
+
+	t.Run("InvalidateOtherProcess", func(t *testing.T) {
+		val, err := otherL1Cache.Get(ctx, "l1-voucher-123")
+		assert.Nil(t, err)
+		assert.Equal(t, "7", val)
+
+		assert.Eventually(t, func() bool {
+			_, err := l1Cache.IncrBy(ctx, "l1-voucher-123", 1)
+			assert.Nil(t, err)
+
+			val, err := otherL1Cache.Get(ctx, "l1-voucher-123")
+			return err == nil && val != "7"
+		}, time.Second, time.Millisecond*20)
+	})
+
+	t.Run("ExpireAfterTTL", func(t *testing.T) {
+		l1Cache := andromeda.NewL1Cache(andromeda.L1CacheConfig{Cache: redisCache, TTL: time.Millisecond * 50})
+		assert.Nil(t, miniRedis.Set("l1-voucher-456", "1"))
+
+		val, err := l1Cache.Get(ctx, "l1-voucher-456")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", val)
+
+		assert.Nil(t, miniRedis.Set("l1-voucher-456", "2"))
+		time.Sleep(time.Millisecond * 60)
+
+		val, err = l1Cache.Get(ctx, "l1-voucher-456")
+		assert.Nil(t, err)
+		assert.Equal(t, "2", val)
+	})
+
+	t.Run("EvictLeastRecentlyRead", func(t *testing.T) {
+		l1Cache := andromeda.NewL1Cache(andromeda.L1CacheConfig{Cache: redisCache, TTL: time.Minute, MaxKeys: 2})
+		for _, key := range []string{"l1-voucher-a", "l1-voucher-b", "l1-voucher-c"} {
+			assert.Nil(t, miniRedis.Set(key, "1"))
+		}
+
+		for _, key := range []string{"l1-voucher-a", "l1-voucher-b", "l1-voucher-a", "l1-voucher-c"} {
+			_, err := l1Cache.Get(ctx, key)
+			assert.Nil(t, err)
+		}
+
+		for _, key := range []string{"l1-voucher-a", "l1-voucher-b", "l1-voucher-c"} {
+			assert.Nil(t, miniRedis.Set(key, "2"))
+		}
+
+		for _, key := range []string{"l1-voucher-a", "l1-voucher-c"} {
+			val, err := l1Cache.Get(ctx, key)
+			assert.Nil(t, err)
+			assert.Equal(t, "1", val)
+		}
+
+		val, err := l1Cache.Get(ctx, "l1-voucher-b")
+		assert.Nil(t, err)
+		assert.Equal(t, "2", val)
+	})
+
+	t.Run("ErrCacheNotFound", func(t *testing.T) {
+		_, err := getCachedQuota.Do(ctx, &andromeda.QuotaRequest{QuotaID: "789"})
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaNotFound))
+	})
+
+	t.Run("KeepReadOverlappingOtherKey", func(t *testing.T) {
+		l1Cache, blockCache := newBlockedL1Cache(redisCache, "l1-voucher-x")
+		assert.Nil(t, miniRedis.Set("l1-voucher-x", "1"))
+
+		readDuringIncr(t, ctx, l1Cache, blockCache, "l1-voucher-y")
+		assert.Nil(t, miniRedis.Set("l1-voucher-x", "5"))
+
+		val, err := l1Cache.Get(ctx, "l1-voucher-x")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", val)
+	})
+
+	t.Run("DropReadOverlappingItsKey", func(t *testing.T) {
+		l1Cache, blockCache := newBlockedL1Cache(redisCache, "l1-voucher-w")
+		assert.Nil(t, miniRedis.Set("l1-voucher-w", "1"))
+
+		readDuringIncr(t, ctx, l1Cache, blockCache, "l1-voucher-w")
+		assert.Nil(t, miniRedis.Set("l1-voucher-w", "5"))
+
+		val, err := l1Cache.Get(ctx, "l1-voucher-w")
+		assert.Nil(t, err)
+		assert.Equal(t, "5", val)
+	})
+
+	t.Run("ReportPublishError", func(t *testing.T) {
+		listener := &publishErrorListener{keys: make(chan []string, 1)}
+		l1Cache := andromeda.NewL1Cache(andromeda.L1CacheConfig{
+			Cache:        redisCache,
+			Invalidation: &failedInvalidation{},
+			Listener:     listener,
+		})
+
+		_, err := l1Cache.IncrBy(ctx, "l1-voucher-z", 1)
+		assert.Nil(t, err)
+
+		select {
+		case keys := <-listener.keys:
+			assert.Equal(t, []string{"l1-voucher-z"}, keys)
+		case <-time.After(time.Second):
+			assert.Fail(t, "publish error is not reported")
+		}
+	})
+
+	t.Run("PublishOnlyChangedKeys", func(t *testing.T) {
+		invalidation := &recordInvalidation{keys: make(chan []string, 4)}
+		l1Cache := andromeda.NewL1Cache(andromeda.L1CacheConfig{Cache: redisCache, Invalidation: invalidation})
+		assert.Nil(t, miniRedis.Set("l1-check-123", "1"))
+
+		check, err := andromeda.CheckAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            l1Cache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "l1-check-%s"},
+		}).Check(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), check.Usage)
+
+		_, err = l1Cache.(andromeda.ScriptCache).Eval(ctx, "return redis.call('INCRBY', KEYS[1], 'x')", []string{"l1-check-123"})
+		assert.NotNil(t, err)
+
+		_, err = l1Cache.IncrBy(ctx, "l1-check-456", 1)
+		assert.Nil(t, err)
+
+		select {
+		case keys := <-invalidation.keys:
+			assert.Equal(t, []string{"l1-check-456"}, keys)
+		case <-time.After(time.Second):
+			assert.Fail(t, "changed key is not published")
+		}
+		assert.Empty(t, invalidation.keys)
+	})
+}
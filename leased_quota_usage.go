@@ -0,0 +1,262 @@
+package andromeda
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// LeasedQuotaUsageConfig .
+type LeasedQuotaUsageConfig struct {
+	Next             UpdateQuotaUsage
+	AddQuotaUsage    UpdateQuotaUsage // acquires the blocks, e.g. NewAddQuotaUsage without the usage cost
+	ReduceQuotaUsage UpdateQuotaUsage // returns the unused units of the blocks, e.g. NewReduceQuotaUsage without the usage cost
+	GetQuotaUsageKey GetQuotaKey      // keys the leases by the usage that the blocks are acquired for, e.g. the usage key of the add quota usage
+	BlockSize        int64            // units that are acquired at once
+	LeaseExpiration  time.Duration    // the unused units are returned when the lease is not renewed by a block within the expiration
+	Option           AddUsageOption
+}
+
+// LeasedQuotaUsage is a contract to serve the usage of a very high-throughput quota in memory from the blocks
+// that the process acquires through the add path, the global limit is enforced up to the outstanding blocks
+type LeasedQuotaUsage interface {
+	UpdateQuotaUsage
+	// Expire returns the unused units of the expired leases, e.g. call it periodically
+	Expire(ctx context.Context) error
+	// Close returns the unused units of every lease and rejects the later usage, e.g. on shutdown
+	Close(ctx context.Context) error
+}
+
+// quotaBlockLease is the units of the acquired blocks of a usage key that are not served yet,
+// the request that creates the lease resolves the same key to return the units
+type quotaBlockLease struct {
+	mu        sync.Mutex
+	req       *QuotaRequest
+	remaining int64
+	served    int64
+	expiresAt time.Time
+	closed    bool
+}
+
+type leasedQuotaUsage struct {
+	mu               sync.Mutex
+	leases           map[string]*quotaBlockLease
+	closed           bool
+	addQuotaUsage    UpdateQuotaUsage
+	reduceQuotaUsage UpdateQuotaUsage
+	getQuotaUsageKey GetQuotaKey
+	blockSize        int64
+	leaseExpiration  time.Duration
+	now              func() time.Time
+	next             UpdateQuotaUsage
+	option           AddUsageOption
+}
+
+func (q *leasedQuotaUsage) Do(ctx context.Context, req *QuotaUsageRequest) (res interface{}, err error) {
+	var totalUsage int64
+	var isNextErr bool
+
+	defer func() {
+		if q.option.Listener != nil && !isNextErr {
+			if err == nil {
+				q.option.Listener.OnSuccess(ctx, req, totalUsage)
+			} else {
+				q.option.Listener.OnError(ctx, req, err)
+			}
+		}
+	}()
+
+	usage, err := getUsage(ctx, req, q.option.UsageCost, q.option.ModifiedUsage)
+	if err != nil {
+		return
+	}
+
+	lease, err := q.lease(ctx, req)
+	if err != nil {
+		return
+	}
+
+	lease.mu.Lock()
+	if lease.closed {
+		lease.mu.Unlock()
+		err = ErrLeaseClosed
+		return
+	}
+	if err = q.take(ctx, lease, usage); err != nil {
+		lease.mu.Unlock()
+		return
+	}
+	// the usage that is served by the leases of the process
+	totalUsage = lease.served
+	lease.mu.Unlock()
+
+	res, _err := q.next.Do(ctx, req)
+	if _err != nil {
+		isNextErr = true
+
+		if !q.option.Irreversible {
+			lease.mu.Lock()
+			lease.served -= usage
+			if lease.closed {
+				// the lease is already returned, so the usage is returned by itself
+				if _, er := q.reduceQuotaUsage.Do(ctx, &QuotaUsageRequest{QuotaID: lease.req.QuotaID, Data: lease.req.Data, Usage: usage}); er != nil {
+					err, _err = er, er
+					isNextErr = false
+				}
+			} else {
+				lease.remaining += usage
+			}
+			lease.mu.Unlock()
+		}
+	}
+
+	return res, _err
+}
+
+// lease gets the lease of the usage key of the request, ErrLeaseClosed is returned after the close
+func (q *leasedQuotaUsage) lease(ctx context.Context, req *QuotaUsageRequest) (*quotaBlockLease, error) {
+	quotaReq := &QuotaRequest{QuotaID: req.QuotaID, Data: req.Data}
+	key, err := q.getQuotaUsageKey.Do(ctx, quotaReq)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil, ErrLeaseClosed
+	}
+
+	lease, ok := q.leases[key]
+	if !ok {
+		lease = &quotaBlockLease{req: quotaReq}
+		q.leases[key] = lease
+	}
+	return lease, nil
+}
+
+// take serves the usage from the lease, the expired lease is returned first and a block is acquired when the lease runs dry,
+// the block is cut down to the missing units when the rest of the limit is smaller than the block
+func (q *leasedQuotaUsage) take(ctx context.Context, lease *quotaBlockLease, usage int64) error {
+	if lease.remaining > 0 && !q.now().Before(lease.expiresAt) {
+		if err := q.giveBack(ctx, lease); err != nil {
+			return err
+		}
+	}
+
+	if lease.remaining < usage {
+		need := usage - lease.remaining
+		block := q.blockSize
+		if block < need {
+			block = need
+		}
+
+		err := q.acquire(ctx, lease, block)
+		if errors.Is(err, ErrQuotaLimitExceeded) && block > need {
+			err = q.acquire(ctx, lease, need)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	lease.remaining -= usage
+	lease.served += usage
+	return nil
+}
+
+func (q *leasedQuotaUsage) acquire(ctx context.Context, lease *quotaBlockLease, block int64) error {
+	if _, err := q.addQuotaUsage.Do(ctx, &QuotaUsageRequest{QuotaID: lease.req.QuotaID, Data: lease.req.Data, Usage: block}); err != nil {
+		return err
+	}
+
+	lease.remaining += block
+	lease.expiresAt = q.now().Add(q.leaseExpiration)
+	return nil
+}
+
+func (q *leasedQuotaUsage) giveBack(ctx context.Context, lease *quotaBlockLease) error {
+	if lease.remaining == 0 {
+		return nil
+	}
+
+	if _, err := q.reduceQuotaUsage.Do(ctx, &QuotaUsageRequest{QuotaID: lease.req.QuotaID, Data: lease.req.Data, Usage: lease.remaining}); err != nil {
+		return err
+	}
+
+	lease.remaining = 0
+	return nil
+}
+
+func (q *leasedQuotaUsage) Expire(ctx context.Context) error {
+	return q.release(ctx, true)
+}
+
+func (q *leasedQuotaUsage) Close(ctx context.Context) error {
+	return q.release(ctx, false)
+}
+
+// release returns the unused units of the leases, it keeps the lease that fails to be returned so it can be retried,
+// the close rejects the later usage of every lease
+func (q *leasedQuotaUsage) release(ctx context.Context, expiredOnly bool) error {
+	q.mu.Lock()
+	if !expiredOnly {
+		q.closed = true
+	}
+	leases := make([]*quotaBlockLease, 0, len(q.leases))
+	for _, lease := range q.leases {
+		leases = append(leases, lease)
+	}
+	q.mu.Unlock()
+
+	var err error
+	for _, lease := range leases {
+		lease.mu.Lock()
+		if !expiredOnly || !q.now().Before(lease.expiresAt) {
+			if _err := q.giveBack(ctx, lease); _err != nil {
+				err = _err
+			}
+		}
+		if !expiredOnly {
+			lease.closed = true
+		}
+		lease.mu.Unlock()
+	}
+	return err
+}
+
+// NewLeasedQuotaUsage .
+func NewLeasedQuotaUsage(conf LeasedQuotaUsageConfig) LeasedQuotaUsage {
+	if conf.AddQuotaUsage == nil {
+		panic("AddQuotaUsage is required")
+	}
+	if conf.ReduceQuotaUsage == nil {
+		panic("ReduceQuotaUsage is required")
+	}
+	if conf.GetQuotaUsageKey == nil {
+		panic("GetQuotaUsageKey is required")
+	}
+	if conf.BlockSize <= 0 {
+		panic("BlockSize is required")
+	}
+	if conf.LeaseExpiration <= 0 {
+		panic("LeaseExpiration is required")
+	}
+	if conf.Next == nil {
+		conf.Next = NopUpdateQuotaUsage()
+	}
+
+	return &leasedQuotaUsage{
+		leases:           make(map[string]*quotaBlockLease),
+		addQuotaUsage:    conf.AddQuotaUsage,
+		reduceQuotaUsage: conf.ReduceQuotaUsage,
+		getQuotaUsageKey: conf.GetQuotaUsageKey,
+		blockSize:        conf.BlockSize,
+		leaseExpiration:  conf.LeaseExpiration,
+		now:              time.Now,
+		next:             conf.Next,
+		option:           conf.Option,
+	}
+}
@@ -0,0 +1,173 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLeasedQuotaUsage(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	getQuotaUsageKey := &mockGetQuotaKey{keyFormat: "leased-voucher-%s"}
+	mockNext := mocks.NewMockUpdateQuotaUsage(mockCtrl)
+	conf := andromeda.LeasedQuotaUsageConfig{
+		Next:             mockNext,
+		AddQuotaUsage:    andromeda.NewAddQuotaUsage(redisCache, getQuotaUsageKey, &mockGetQuota{value: 250}, andromeda.NopUpdateQuotaUsage(), andromeda.AddUsageOption{}),
+		ReduceQuotaUsage: andromeda.NewReduceQuotaUsage(redisCache, getQuotaUsageKey, andromeda.NopUpdateQuotaUsage(), andromeda.ReduceUsageOption{}),
+		GetQuotaUsageKey: getQuotaUsageKey,
+		BlockSize:        100,
+		LeaseExpiration:  time.Minute,
+	}
+	leasedQuotaUsage := andromeda.NewLeasedQuotaUsage(conf)
+	req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1}
+
+	t.Run("AcquireBlock", func(t *testing.T) {
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil)
+
+		_, err := leasedQuotaUsage.Do(ctx, req)
+		assert.Nil(t, err)
+
+		usage, err := miniRedis.Get("leased-voucher-123")
+		assert.Nil(t, err)
+		assert.Equal(t, "100", usage)
+	})
+
+	t.Run("ServeFromMemory", func(t *testing.T) {
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil).Times(99)
+
+		for i := 0; i < 99; i++ {
+			_, err := leasedQuotaUsage.Do(ctx, req)
+			assert.Nil(t, err)
+		}
+
+		usage, err := miniRedis.Get("leased-voucher-123")
+		assert.Nil(t, err)
+		assert.Equal(t, "100", usage)
+	})
+
+	t.Run("ReturnUnusedUnitsOnClose", func(t *testing.T) {
+		mockNext.EXPECT().Do(ctx, req).Return(nil, errors.New("error"))
+
+		_, err := leasedQuotaUsage.Do(ctx, req)
+		assert.EqualError(t, err, "error")
+
+		usage, err := miniRedis.Get("leased-voucher-123")
+		assert.Nil(t, err)
+		assert.Equal(t, "200", usage)
+
+		assert.Nil(t, leasedQuotaUsage.Close(ctx))
+
+		usage, err = miniRedis.Get("leased-voucher-123")
+		assert.Nil(t, err)
+		assert.Equal(t, "100", usage)
+	})
+
+	t.Run("ErrorAfterClose", func(t *testing.T) {
+		_, err := leasedQuotaUsage.Do(ctx, req)
+		assert.True(t, errors.Is(err, andromeda.ErrLeaseClosed))
+
+		_, err = leasedQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "789", Usage: 1})
+		assert.True(t, errors.Is(err, andromeda.ErrLeaseClosed))
+
+		usage, err := miniRedis.Get("leased-voucher-123")
+		assert.Nil(t, err)
+		assert.Equal(t, "100", usage)
+		assert.False(t, miniRedis.Exists("leased-voucher-789"))
+	})
+
+	t.Run("CutBlockDownToLimit", func(t *testing.T) {
+		leasedQuotaUsage = andromeda.NewLeasedQuotaUsage(conf)
+
+		req60 := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 60}
+		req90 := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 90}
+		mockNext.EXPECT().Do(ctx, req60).Return(nil, nil)
+		mockNext.EXPECT().Do(ctx, req90).Return(nil, nil)
+
+		_, err := leasedQuotaUsage.Do(ctx, req60)
+		assert.Nil(t, err)
+
+		_, err = leasedQuotaUsage.Do(ctx, req90)
+		assert.Nil(t, err)
+
+		usage, err := miniRedis.Get("leased-voucher-123")
+		assert.Nil(t, err)
+		assert.Equal(t, "250", usage)
+	})
+
+	t.Run("ErrorLimitExceeded", func(t *testing.T) {
+		_, err := leasedQuotaUsage.Do(ctx, req)
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+
+		usage, err := miniRedis.Get("leased-voucher-123")
+		assert.Nil(t, err)
+		assert.Equal(t, "250", usage)
+	})
+
+	t.Run("ReturnUnusedUnitsOnExpiry", func(t *testing.T) {
+		conf.LeaseExpiration = time.Millisecond * 50
+		leasedQuotaUsage := andromeda.NewLeasedQuotaUsage(conf)
+		req := &andromeda.QuotaUsageRequest{QuotaID: "456", Usage: 1}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil)
+
+		_, err := leasedQuotaUsage.Do(ctx, req)
+		assert.Nil(t, err)
+
+		assert.Nil(t, leasedQuotaUsage.Expire(ctx))
+		usage, err := miniRedis.Get("leased-voucher-456")
+		assert.Nil(t, err)
+		assert.Equal(t, "100", usage)
+
+		time.Sleep(time.Millisecond * 60)
+		assert.Nil(t, leasedQuotaUsage.Expire(ctx))
+		usage, err = miniRedis.Get("leased-voucher-456")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", usage)
+	})
+
+	t.Run("LeasePerUsageKey", func(t *testing.T) {
+		mockGetQuotaKey := mocks.NewMockGetQuotaKey(mockCtrl)
+		mockGetQuotaKey.EXPECT().Do(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, req *andromeda.QuotaRequest) (string, error) {
+			return fmt.Sprintf("leased-voucher-%s-%s", req.QuotaID, req.Data), nil
+		}).AnyTimes()
+		leasedQuotaUsage := andromeda.NewLeasedQuotaUsage(andromeda.LeasedQuotaUsageConfig{
+			AddQuotaUsage:    andromeda.NewAddQuotaUsage(redisCache, mockGetQuotaKey, &mockGetQuota{value: 250}, andromeda.NopUpdateQuotaUsage(), andromeda.AddUsageOption{}),
+			ReduceQuotaUsage: andromeda.NewReduceQuotaUsage(redisCache, mockGetQuotaKey, andromeda.NopUpdateQuotaUsage(), andromeda.ReduceUsageOption{}),
+			GetQuotaUsageKey: mockGetQuotaKey,
+			BlockSize:        100,
+			LeaseExpiration:  time.Minute,
+		})
+
+		for _, region := range []string{"id", "sg", "id"} {
+			_, err := leasedQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "789", Data: region, Usage: 1})
+			assert.Nil(t, err)
+		}
+
+		for _, region := range []string{"id", "sg"} {
+			usage, err := miniRedis.Get("leased-voucher-789-" + region)
+			assert.Nil(t, err)
+			assert.Equal(t, "100", usage)
+		}
+
+		assert.Nil(t, leasedQuotaUsage.Close(ctx))
+
+		usage, err := miniRedis.Get("leased-voucher-789-id")
+		assert.Nil(t, err)
+		assert.Equal(t, "2", usage)
+	})
+}
@@ -0,0 +1,197 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: admin.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	andromeda "github.com/ramadani/andromeda"
+)
+
+// MockAdminListener is a mock of AdminListener interface.
+type MockAdminListener struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminListenerMockRecorder
+}
+
+// MockAdminListenerMockRecorder is the mock recorder for MockAdminListener.
+type MockAdminListenerMockRecorder struct {
+	mock *MockAdminListener
+}
+
+// NewMockAdminListener creates a new mock instance.
+func NewMockAdminListener(ctrl *gomock.Controller) *MockAdminListener {
+	mock := &MockAdminListener{ctrl: ctrl}
+	mock.recorder = &MockAdminListenerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdminListener) EXPECT() *MockAdminListenerMockRecorder {
+	return m.recorder
+}
+
+// OnError mocks base method.
+func (m *MockAdminListener) OnError(ctx context.Context, event *andromeda.AdminEvent, err error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnError", ctx, event, err)
+}
+
+// OnError indicates an expected call of OnError.
+func (mr *MockAdminListenerMockRecorder) OnError(ctx, event, err interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnError", reflect.TypeOf((*MockAdminListener)(nil).OnError), ctx, event, err)
+}
+
+// OnSuccess mocks base method.
+func (m *MockAdminListener) OnSuccess(ctx context.Context, event *andromeda.AdminEvent) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnSuccess", ctx, event)
+}
+
+// OnSuccess indicates an expected call of OnSuccess.
+func (mr *MockAdminListenerMockRecorder) OnSuccess(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnSuccess", reflect.TypeOf((*MockAdminListener)(nil).OnSuccess), ctx, event)
+}
+
+// MockAdmin is a mock of Admin interface.
+type MockAdmin struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminMockRecorder
+}
+
+// MockAdminMockRecorder is the mock recorder for MockAdmin.
+type MockAdminMockRecorder struct {
+	mock *MockAdmin
+}
+
+// NewMockAdmin creates a new mock instance.
+func NewMockAdmin(ctrl *gomock.Controller) *MockAdmin {
+	mock := &MockAdmin{ctrl: ctrl}
+	mock.recorder = &MockAdminMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdmin) EXPECT() *MockAdminMockRecorder {
+	return m.recorder
+}
+
+// AdjustUsage mocks base method.
+func (m *MockAdmin) AdjustUsage(ctx context.Context, req *andromeda.QuotaRequest, delta int64, reason string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustUsage", ctx, req, delta, reason)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustUsage indicates an expected call of AdjustUsage.
+func (mr *MockAdminMockRecorder) AdjustUsage(ctx, req, delta, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustUsage", reflect.TypeOf((*MockAdmin)(nil).AdjustUsage), ctx, req, delta, reason)
+}
+
+// Freeze mocks base method.
+func (m *MockAdmin) Freeze(ctx context.Context, req *andromeda.QuotaRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Freeze", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Freeze indicates an expected call of Freeze.
+func (mr *MockAdminMockRecorder) Freeze(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Freeze", reflect.TypeOf((*MockAdmin)(nil).Freeze), ctx, req)
+}
+
+// GetState mocks base method.
+func (m *MockAdmin) GetState(ctx context.Context, req *andromeda.QuotaRequest, direction andromeda.QuotaDirection) (andromeda.QuotaState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetState", ctx, req, direction)
+	ret0, _ := ret[0].(andromeda.QuotaState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetState indicates an expected call of GetState.
+func (mr *MockAdminMockRecorder) GetState(ctx, req, direction interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetState", reflect.TypeOf((*MockAdmin)(nil).GetState), ctx, req, direction)
+}
+
+// Invalidate mocks base method.
+func (m *MockAdmin) Invalidate(ctx context.Context, req *andromeda.QuotaRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Invalidate", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Invalidate indicates an expected call of Invalidate.
+func (mr *MockAdminMockRecorder) Invalidate(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Invalidate", reflect.TypeOf((*MockAdmin)(nil).Invalidate), ctx, req)
+}
+
+// ResetUsage mocks base method.
+func (m *MockAdmin) ResetUsage(ctx context.Context, req *andromeda.QuotaRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetUsage", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetUsage indicates an expected call of ResetUsage.
+func (mr *MockAdminMockRecorder) ResetUsage(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetUsage", reflect.TypeOf((*MockAdmin)(nil).ResetUsage), ctx, req)
+}
+
+// SetState mocks base method.
+func (m *MockAdmin) SetState(ctx context.Context, req *andromeda.QuotaRequest, direction andromeda.QuotaDirection, state andromeda.QuotaState) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetState", ctx, req, direction, state)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetState indicates an expected call of SetState.
+func (mr *MockAdminMockRecorder) SetState(ctx, req, direction, state interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetState", reflect.TypeOf((*MockAdmin)(nil).SetState), ctx, req, direction, state)
+}
+
+// SetUsage mocks base method.
+func (m *MockAdmin) SetUsage(ctx context.Context, req *andromeda.QuotaRequest, usage int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUsage", ctx, req, usage)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUsage indicates an expected call of SetUsage.
+func (mr *MockAdminMockRecorder) SetUsage(ctx, req, usage interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUsage", reflect.TypeOf((*MockAdmin)(nil).SetUsage), ctx, req, usage)
+}
+
+// Unfreeze mocks base method.
+func (m *MockAdmin) Unfreeze(ctx context.Context, req *andromeda.QuotaRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unfreeze", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unfreeze indicates an expected call of Unfreeze.
+func (mr *MockAdminMockRecorder) Unfreeze(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unfreeze", reflect.TypeOf((*MockAdmin)(nil).Unfreeze), ctx, req)
+}
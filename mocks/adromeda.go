@@ -51,6 +51,82 @@ func (mr *MockUpdateQuotaUsageMockRecorder) Do(ctx, req interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockUpdateQuotaUsage)(nil).Do), ctx, req)
 }
 
+// MockUsageCost is a mock of UsageCost interface.
+type MockUsageCost struct {
+	ctrl     *gomock.Controller
+	recorder *MockUsageCostMockRecorder
+}
+
+// MockUsageCostMockRecorder is the mock recorder for MockUsageCost.
+type MockUsageCostMockRecorder struct {
+	mock *MockUsageCost
+}
+
+// NewMockUsageCost creates a new mock instance.
+func NewMockUsageCost(ctrl *gomock.Controller) *MockUsageCost {
+	mock := &MockUsageCost{ctrl: ctrl}
+	mock.recorder = &MockUsageCostMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUsageCost) EXPECT() *MockUsageCostMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockUsageCost) Do(ctx context.Context, req *andromeda.QuotaUsageRequest) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, req)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockUsageCostMockRecorder) Do(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockUsageCost)(nil).Do), ctx, req)
+}
+
+// MockCheckQuotaUsage is a mock of CheckQuotaUsage interface.
+type MockCheckQuotaUsage struct {
+	ctrl     *gomock.Controller
+	recorder *MockCheckQuotaUsageMockRecorder
+}
+
+// MockCheckQuotaUsageMockRecorder is the mock recorder for MockCheckQuotaUsage.
+type MockCheckQuotaUsageMockRecorder struct {
+	mock *MockCheckQuotaUsage
+}
+
+// NewMockCheckQuotaUsage creates a new mock instance.
+func NewMockCheckQuotaUsage(ctrl *gomock.Controller) *MockCheckQuotaUsage {
+	mock := &MockCheckQuotaUsage{ctrl: ctrl}
+	mock.recorder = &MockCheckQuotaUsageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCheckQuotaUsage) EXPECT() *MockCheckQuotaUsageMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockCheckQuotaUsage) Check(ctx context.Context, req *andromeda.QuotaUsageRequest) (*andromeda.QuotaUsageCheck, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", ctx, req)
+	ret0, _ := ret[0].(*andromeda.QuotaUsageCheck)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockCheckQuotaUsageMockRecorder) Check(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockCheckQuotaUsage)(nil).Check), ctx, req)
+}
+
 // MockUpdateQuotaUsageListener is a mock of UpdateQuotaUsageListener interface.
 type MockUpdateQuotaUsageListener struct {
 	ctrl     *gomock.Controller
@@ -98,6 +174,219 @@ func (mr *MockUpdateQuotaUsageListenerMockRecorder) OnSuccess(ctx, req, updatedU
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnSuccess", reflect.TypeOf((*MockUpdateQuotaUsageListener)(nil).OnSuccess), ctx, req, updatedUsage)
 }
 
+// MockQuotaOverdraftListener is a mock of QuotaOverdraftListener interface.
+type MockQuotaOverdraftListener struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuotaOverdraftListenerMockRecorder
+}
+
+// MockQuotaOverdraftListenerMockRecorder is the mock recorder for MockQuotaOverdraftListener.
+type MockQuotaOverdraftListenerMockRecorder struct {
+	mock *MockQuotaOverdraftListener
+}
+
+// NewMockQuotaOverdraftListener creates a new mock instance.
+func NewMockQuotaOverdraftListener(ctrl *gomock.Controller) *MockQuotaOverdraftListener {
+	mock := &MockQuotaOverdraftListener{ctrl: ctrl}
+	mock.recorder = &MockQuotaOverdraftListenerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQuotaOverdraftListener) EXPECT() *MockQuotaOverdraftListenerMockRecorder {
+	return m.recorder
+}
+
+// OnOverdraft mocks base method.
+func (m *MockQuotaOverdraftListener) OnOverdraft(ctx context.Context, req *andromeda.QuotaUsageRequest, overdraftUsage int64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnOverdraft", ctx, req, overdraftUsage)
+}
+
+// OnOverdraft indicates an expected call of OnOverdraft.
+func (mr *MockQuotaOverdraftListenerMockRecorder) OnOverdraft(ctx, req, overdraftUsage interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnOverdraft", reflect.TypeOf((*MockQuotaOverdraftListener)(nil).OnOverdraft), ctx, req, overdraftUsage)
+}
+
+// MockQuotaFallbackListener is a mock of QuotaFallbackListener interface.
+type MockQuotaFallbackListener struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuotaFallbackListenerMockRecorder
+}
+
+// MockQuotaFallbackListenerMockRecorder is the mock recorder for MockQuotaFallbackListener.
+type MockQuotaFallbackListenerMockRecorder struct {
+	mock *MockQuotaFallbackListener
+}
+
+// NewMockQuotaFallbackListener creates a new mock instance.
+func NewMockQuotaFallbackListener(ctrl *gomock.Controller) *MockQuotaFallbackListener {
+	mock := &MockQuotaFallbackListener{ctrl: ctrl}
+	mock.recorder = &MockQuotaFallbackListenerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQuotaFallbackListener) EXPECT() *MockQuotaFallbackListenerMockRecorder {
+	return m.recorder
+}
+
+// OnFallback mocks base method.
+func (m *MockQuotaFallbackListener) OnFallback(ctx context.Context, req *andromeda.QuotaUsageRequest, err error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnFallback", ctx, req, err)
+}
+
+// OnFallback indicates an expected call of OnFallback.
+func (mr *MockQuotaFallbackListenerMockRecorder) OnFallback(ctx, req, err interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnFallback", reflect.TypeOf((*MockQuotaFallbackListener)(nil).OnFallback), ctx, req, err)
+}
+
+// MockQuotaWaitlistListener is a mock of QuotaWaitlistListener interface.
+type MockQuotaWaitlistListener struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuotaWaitlistListenerMockRecorder
+}
+
+// MockQuotaWaitlistListenerMockRecorder is the mock recorder for MockQuotaWaitlistListener.
+type MockQuotaWaitlistListenerMockRecorder struct {
+	mock *MockQuotaWaitlistListener
+}
+
+// NewMockQuotaWaitlistListener creates a new mock instance.
+func NewMockQuotaWaitlistListener(ctrl *gomock.Controller) *MockQuotaWaitlistListener {
+	mock := &MockQuotaWaitlistListener{ctrl: ctrl}
+	mock.recorder = &MockQuotaWaitlistListenerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQuotaWaitlistListener) EXPECT() *MockQuotaWaitlistListenerMockRecorder {
+	return m.recorder
+}
+
+// OnOffered mocks base method.
+func (m *MockQuotaWaitlistListener) OnOffered(ctx context.Context, offer *andromeda.QuotaOffer) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnOffered", ctx, offer)
+}
+
+// OnOffered indicates an expected call of OnOffered.
+func (mr *MockQuotaWaitlistListenerMockRecorder) OnOffered(ctx, offer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnOffered", reflect.TypeOf((*MockQuotaWaitlistListener)(nil).OnOffered), ctx, offer)
+}
+
+// MockQuotaWaitlistErrorListener is a mock of QuotaWaitlistErrorListener interface.
+type MockQuotaWaitlistErrorListener struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuotaWaitlistErrorListenerMockRecorder
+}
+
+// MockQuotaWaitlistErrorListenerMockRecorder is the mock recorder for MockQuotaWaitlistErrorListener.
+type MockQuotaWaitlistErrorListenerMockRecorder struct {
+	mock *MockQuotaWaitlistErrorListener
+}
+
+// NewMockQuotaWaitlistErrorListener creates a new mock instance.
+func NewMockQuotaWaitlistErrorListener(ctrl *gomock.Controller) *MockQuotaWaitlistErrorListener {
+	mock := &MockQuotaWaitlistErrorListener{ctrl: ctrl}
+	mock.recorder = &MockQuotaWaitlistErrorListenerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQuotaWaitlistErrorListener) EXPECT() *MockQuotaWaitlistErrorListenerMockRecorder {
+	return m.recorder
+}
+
+// OnOfferError mocks base method.
+func (m *MockQuotaWaitlistErrorListener) OnOfferError(ctx context.Context, req *andromeda.QuotaUsageRequest, err error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnOfferError", ctx, req, err)
+}
+
+// OnOfferError indicates an expected call of OnOfferError.
+func (mr *MockQuotaWaitlistErrorListenerMockRecorder) OnOfferError(ctx, req, err interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnOfferError", reflect.TypeOf((*MockQuotaWaitlistErrorListener)(nil).OnOfferError), ctx, req, err)
+}
+
+// MockQuotaThresholdListener is a mock of QuotaThresholdListener interface.
+type MockQuotaThresholdListener struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuotaThresholdListenerMockRecorder
+}
+
+// MockQuotaThresholdListenerMockRecorder is the mock recorder for MockQuotaThresholdListener.
+type MockQuotaThresholdListenerMockRecorder struct {
+	mock *MockQuotaThresholdListener
+}
+
+// NewMockQuotaThresholdListener creates a new mock instance.
+func NewMockQuotaThresholdListener(ctrl *gomock.Controller) *MockQuotaThresholdListener {
+	mock := &MockQuotaThresholdListener{ctrl: ctrl}
+	mock.recorder = &MockQuotaThresholdListenerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQuotaThresholdListener) EXPECT() *MockQuotaThresholdListenerMockRecorder {
+	return m.recorder
+}
+
+// OnThresholdCrossed mocks base method.
+func (m *MockQuotaThresholdListener) OnThresholdCrossed(ctx context.Context, event *andromeda.QuotaThresholdEvent) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnThresholdCrossed", ctx, event)
+}
+
+// OnThresholdCrossed indicates an expected call of OnThresholdCrossed.
+func (mr *MockQuotaThresholdListenerMockRecorder) OnThresholdCrossed(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnThresholdCrossed", reflect.TypeOf((*MockQuotaThresholdListener)(nil).OnThresholdCrossed), ctx, event)
+}
+
+// MockGetQuotaThresholds is a mock of GetQuotaThresholds interface.
+type MockGetQuotaThresholds struct {
+	ctrl     *gomock.Controller
+	recorder *MockGetQuotaThresholdsMockRecorder
+}
+
+// MockGetQuotaThresholdsMockRecorder is the mock recorder for MockGetQuotaThresholds.
+type MockGetQuotaThresholdsMockRecorder struct {
+	mock *MockGetQuotaThresholds
+}
+
+// NewMockGetQuotaThresholds creates a new mock instance.
+func NewMockGetQuotaThresholds(ctrl *gomock.Controller) *MockGetQuotaThresholds {
+	mock := &MockGetQuotaThresholds{ctrl: ctrl}
+	mock.recorder = &MockGetQuotaThresholdsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGetQuotaThresholds) EXPECT() *MockGetQuotaThresholdsMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockGetQuotaThresholds) Do(ctx context.Context, req *andromeda.QuotaRequest) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, req)
+	ret0, _ := ret[0].([]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockGetQuotaThresholdsMockRecorder) Do(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockGetQuotaThresholds)(nil).Do), ctx, req)
+}
+
 // MockGetQuota is a mock of GetQuota interface.
 type MockGetQuota struct {
 	ctrl     *gomock.Controller
@@ -136,6 +425,157 @@ func (mr *MockGetQuotaMockRecorder) Do(ctx, req interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockGetQuota)(nil).Do), ctx, req)
 }
 
+// MockSetQuota is a mock of SetQuota interface.
+type MockSetQuota struct {
+	ctrl     *gomock.Controller
+	recorder *MockSetQuotaMockRecorder
+}
+
+// MockSetQuotaMockRecorder is the mock recorder for MockSetQuota.
+type MockSetQuotaMockRecorder struct {
+	mock *MockSetQuota
+}
+
+// NewMockSetQuota creates a new mock instance.
+func NewMockSetQuota(ctrl *gomock.Controller) *MockSetQuota {
+	mock := &MockSetQuota{ctrl: ctrl}
+	mock.recorder = &MockSetQuotaMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSetQuota) EXPECT() *MockSetQuotaMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockSetQuota) Do(ctx context.Context, req *andromeda.QuotaRequest, value int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, req, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockSetQuotaMockRecorder) Do(ctx, req, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockSetQuota)(nil).Do), ctx, req, value)
+}
+
+// MockGetDecimalQuota is a mock of GetDecimalQuota interface.
+type MockGetDecimalQuota struct {
+	ctrl     *gomock.Controller
+	recorder *MockGetDecimalQuotaMockRecorder
+}
+
+// MockGetDecimalQuotaMockRecorder is the mock recorder for MockGetDecimalQuota.
+type MockGetDecimalQuotaMockRecorder struct {
+	mock *MockGetDecimalQuota
+}
+
+// NewMockGetDecimalQuota creates a new mock instance.
+func NewMockGetDecimalQuota(ctrl *gomock.Controller) *MockGetDecimalQuota {
+	mock := &MockGetDecimalQuota{ctrl: ctrl}
+	mock.recorder = &MockGetDecimalQuotaMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGetDecimalQuota) EXPECT() *MockGetDecimalQuotaMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockGetDecimalQuota) Do(ctx context.Context, req *andromeda.QuotaRequest) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, req)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockGetDecimalQuotaMockRecorder) Do(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockGetDecimalQuota)(nil).Do), ctx, req)
+}
+
+// MockGetDecimalUsage is a mock of GetDecimalUsage interface.
+type MockGetDecimalUsage struct {
+	ctrl     *gomock.Controller
+	recorder *MockGetDecimalUsageMockRecorder
+}
+
+// MockGetDecimalUsageMockRecorder is the mock recorder for MockGetDecimalUsage.
+type MockGetDecimalUsageMockRecorder struct {
+	mock *MockGetDecimalUsage
+}
+
+// NewMockGetDecimalUsage creates a new mock instance.
+func NewMockGetDecimalUsage(ctrl *gomock.Controller) *MockGetDecimalUsage {
+	mock := &MockGetDecimalUsage{ctrl: ctrl}
+	mock.recorder = &MockGetDecimalUsageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGetDecimalUsage) EXPECT() *MockGetDecimalUsageMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockGetDecimalUsage) Do(ctx context.Context, req *andromeda.QuotaUsageRequest) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, req)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockGetDecimalUsageMockRecorder) Do(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockGetDecimalUsage)(nil).Do), ctx, req)
+}
+
+// MockGetQuotaStatus is a mock of GetQuotaStatus interface.
+type MockGetQuotaStatus struct {
+	ctrl     *gomock.Controller
+	recorder *MockGetQuotaStatusMockRecorder
+}
+
+// MockGetQuotaStatusMockRecorder is the mock recorder for MockGetQuotaStatus.
+type MockGetQuotaStatusMockRecorder struct {
+	mock *MockGetQuotaStatus
+}
+
+// NewMockGetQuotaStatus creates a new mock instance.
+func NewMockGetQuotaStatus(ctrl *gomock.Controller) *MockGetQuotaStatus {
+	mock := &MockGetQuotaStatus{ctrl: ctrl}
+	mock.recorder = &MockGetQuotaStatusMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGetQuotaStatus) EXPECT() *MockGetQuotaStatusMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockGetQuotaStatus) Do(ctx context.Context, req *andromeda.QuotaRequest) (*andromeda.QuotaStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, req)
+	ret0, _ := ret[0].(*andromeda.QuotaStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockGetQuotaStatusMockRecorder) Do(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockGetQuotaStatus)(nil).Do), ctx, req)
+}
+
 // MockGetQuotaKey is a mock of GetQuotaKey interface.
 type MockGetQuotaKey struct {
 	ctrl     *gomock.Controller
@@ -212,6 +652,234 @@ func (mr *MockGetQuotaExpirationMockRecorder) Do(ctx, req interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockGetQuotaExpiration)(nil).Do), ctx, req)
 }
 
+// MockGetQuotaSchedule is a mock of GetQuotaSchedule interface.
+type MockGetQuotaSchedule struct {
+	ctrl     *gomock.Controller
+	recorder *MockGetQuotaScheduleMockRecorder
+}
+
+// MockGetQuotaScheduleMockRecorder is the mock recorder for MockGetQuotaSchedule.
+type MockGetQuotaScheduleMockRecorder struct {
+	mock *MockGetQuotaSchedule
+}
+
+// NewMockGetQuotaSchedule creates a new mock instance.
+func NewMockGetQuotaSchedule(ctrl *gomock.Controller) *MockGetQuotaSchedule {
+	mock := &MockGetQuotaSchedule{ctrl: ctrl}
+	mock.recorder = &MockGetQuotaScheduleMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGetQuotaSchedule) EXPECT() *MockGetQuotaScheduleMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockGetQuotaSchedule) Do(ctx context.Context, req *andromeda.QuotaRequest) (*andromeda.QuotaSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, req)
+	ret0, _ := ret[0].(*andromeda.QuotaSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockGetQuotaScheduleMockRecorder) Do(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockGetQuotaSchedule)(nil).Do), ctx, req)
+}
+
+// MockGetQuotaRelease is a mock of GetQuotaRelease interface.
+type MockGetQuotaRelease struct {
+	ctrl     *gomock.Controller
+	recorder *MockGetQuotaReleaseMockRecorder
+}
+
+// MockGetQuotaReleaseMockRecorder is the mock recorder for MockGetQuotaRelease.
+type MockGetQuotaReleaseMockRecorder struct {
+	mock *MockGetQuotaRelease
+}
+
+// NewMockGetQuotaRelease creates a new mock instance.
+func NewMockGetQuotaRelease(ctrl *gomock.Controller) *MockGetQuotaRelease {
+	mock := &MockGetQuotaRelease{ctrl: ctrl}
+	mock.recorder = &MockGetQuotaReleaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGetQuotaRelease) EXPECT() *MockGetQuotaReleaseMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockGetQuotaRelease) Do(ctx context.Context, req *andromeda.QuotaRequest) (*andromeda.QuotaRelease, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, req)
+	ret0, _ := ret[0].(*andromeda.QuotaRelease)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockGetQuotaReleaseMockRecorder) Do(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockGetQuotaRelease)(nil).Do), ctx, req)
+}
+
+// MockGetQuotaSubject is a mock of GetQuotaSubject interface.
+type MockGetQuotaSubject struct {
+	ctrl     *gomock.Controller
+	recorder *MockGetQuotaSubjectMockRecorder
+}
+
+// MockGetQuotaSubjectMockRecorder is the mock recorder for MockGetQuotaSubject.
+type MockGetQuotaSubjectMockRecorder struct {
+	mock *MockGetQuotaSubject
+}
+
+// NewMockGetQuotaSubject creates a new mock instance.
+func NewMockGetQuotaSubject(ctrl *gomock.Controller) *MockGetQuotaSubject {
+	mock := &MockGetQuotaSubject{ctrl: ctrl}
+	mock.recorder = &MockGetQuotaSubjectMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGetQuotaSubject) EXPECT() *MockGetQuotaSubjectMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockGetQuotaSubject) Do(ctx context.Context, req *andromeda.QuotaRequest) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, req)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockGetQuotaSubjectMockRecorder) Do(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockGetQuotaSubject)(nil).Do), ctx, req)
+}
+
+// MockGetQuotaClass is a mock of GetQuotaClass interface.
+type MockGetQuotaClass struct {
+	ctrl     *gomock.Controller
+	recorder *MockGetQuotaClassMockRecorder
+}
+
+// MockGetQuotaClassMockRecorder is the mock recorder for MockGetQuotaClass.
+type MockGetQuotaClassMockRecorder struct {
+	mock *MockGetQuotaClass
+}
+
+// NewMockGetQuotaClass creates a new mock instance.
+func NewMockGetQuotaClass(ctrl *gomock.Controller) *MockGetQuotaClass {
+	mock := &MockGetQuotaClass{ctrl: ctrl}
+	mock.recorder = &MockGetQuotaClassMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGetQuotaClass) EXPECT() *MockGetQuotaClassMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockGetQuotaClass) Do(ctx context.Context, req *andromeda.QuotaRequest) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, req)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockGetQuotaClassMockRecorder) Do(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockGetQuotaClass)(nil).Do), ctx, req)
+}
+
+// MockGetQuotaClasses is a mock of GetQuotaClasses interface.
+type MockGetQuotaClasses struct {
+	ctrl     *gomock.Controller
+	recorder *MockGetQuotaClassesMockRecorder
+}
+
+// MockGetQuotaClassesMockRecorder is the mock recorder for MockGetQuotaClasses.
+type MockGetQuotaClassesMockRecorder struct {
+	mock *MockGetQuotaClasses
+}
+
+// NewMockGetQuotaClasses creates a new mock instance.
+func NewMockGetQuotaClasses(ctrl *gomock.Controller) *MockGetQuotaClasses {
+	mock := &MockGetQuotaClasses{ctrl: ctrl}
+	mock.recorder = &MockGetQuotaClassesMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGetQuotaClasses) EXPECT() *MockGetQuotaClassesMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockGetQuotaClasses) Do(ctx context.Context, req *andromeda.QuotaRequest) ([]andromeda.QuotaClass, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, req)
+	ret0, _ := ret[0].([]andromeda.QuotaClass)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockGetQuotaClassesMockRecorder) Do(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockGetQuotaClasses)(nil).Do), ctx, req)
+}
+
+// MockGetQuotaTicket is a mock of GetQuotaTicket interface.
+type MockGetQuotaTicket struct {
+	ctrl     *gomock.Controller
+	recorder *MockGetQuotaTicketMockRecorder
+}
+
+// MockGetQuotaTicketMockRecorder is the mock recorder for MockGetQuotaTicket.
+type MockGetQuotaTicketMockRecorder struct {
+	mock *MockGetQuotaTicket
+}
+
+// NewMockGetQuotaTicket creates a new mock instance.
+func NewMockGetQuotaTicket(ctrl *gomock.Controller) *MockGetQuotaTicket {
+	mock := &MockGetQuotaTicket{ctrl: ctrl}
+	mock.recorder = &MockGetQuotaTicketMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGetQuotaTicket) EXPECT() *MockGetQuotaTicketMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockGetQuotaTicket) Do(ctx context.Context, req *andromeda.QuotaRequest) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", ctx, req)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockGetQuotaTicketMockRecorder) Do(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockGetQuotaTicket)(nil).Do), ctx, req)
+}
+
 // MockXSetNXQuota is a mock of XSetNXQuota interface.
 type MockXSetNXQuota struct {
 	ctrl     *gomock.Controller
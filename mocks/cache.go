@@ -149,3 +149,243 @@ func (mr *MockCacheMockRecorder) SetNX(ctx, key, value, expiration interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNX", reflect.TypeOf((*MockCache)(nil).SetNX), ctx, key, value, expiration)
 }
+
+// MockScriptCache is a mock of ScriptCache interface.
+type MockScriptCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockScriptCacheMockRecorder
+}
+
+// MockScriptCacheMockRecorder is the mock recorder for MockScriptCache.
+type MockScriptCacheMockRecorder struct {
+	mock *MockScriptCache
+}
+
+// NewMockScriptCache creates a new mock instance.
+func NewMockScriptCache(ctrl *gomock.Controller) *MockScriptCache {
+	mock := &MockScriptCache{ctrl: ctrl}
+	mock.recorder = &MockScriptCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockScriptCache) EXPECT() *MockScriptCacheMockRecorder {
+	return m.recorder
+}
+
+// DecrBy mocks base method.
+func (m *MockScriptCache) DecrBy(ctx context.Context, key string, decrement int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecrBy", ctx, key, decrement)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DecrBy indicates an expected call of DecrBy.
+func (mr *MockScriptCacheMockRecorder) DecrBy(ctx, key, decrement interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecrBy", reflect.TypeOf((*MockScriptCache)(nil).DecrBy), ctx, key, decrement)
+}
+
+// Del mocks base method.
+func (m *MockScriptCache) Del(ctx context.Context, keys ...string) (int64, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Del", varargs...)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Del indicates an expected call of Del.
+func (mr *MockScriptCacheMockRecorder) Del(ctx interface{}, keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, keys...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockScriptCache)(nil).Del), varargs...)
+}
+
+// Eval mocks base method.
+func (m *MockScriptCache) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, script, keys}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Eval", varargs...)
+	ret0, _ := ret[0].(interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Eval indicates an expected call of Eval.
+func (mr *MockScriptCacheMockRecorder) Eval(ctx, script, keys interface{}, args ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, script, keys}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Eval", reflect.TypeOf((*MockScriptCache)(nil).Eval), varargs...)
+}
+
+// Exists mocks base method.
+func (m *MockScriptCache) Exists(ctx context.Context, keys ...string) (int64, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Exists", varargs...)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exists indicates an expected call of Exists.
+func (mr *MockScriptCacheMockRecorder) Exists(ctx interface{}, keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, keys...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockScriptCache)(nil).Exists), varargs...)
+}
+
+// Get mocks base method.
+func (m *MockScriptCache) Get(ctx context.Context, key string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockScriptCacheMockRecorder) Get(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockScriptCache)(nil).Get), ctx, key)
+}
+
+// IncrBy mocks base method.
+func (m *MockScriptCache) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrBy", ctx, key, value)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrBy indicates an expected call of IncrBy.
+func (mr *MockScriptCacheMockRecorder) IncrBy(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrBy", reflect.TypeOf((*MockScriptCache)(nil).IncrBy), ctx, key, value)
+}
+
+// Set mocks base method.
+func (m *MockScriptCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, key, value, expiration)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockScriptCacheMockRecorder) Set(ctx, key, value, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockScriptCache)(nil).Set), ctx, key, value, expiration)
+}
+
+// SetNX mocks base method.
+func (m *MockScriptCache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetNX", ctx, key, value, expiration)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetNX indicates an expected call of SetNX.
+func (mr *MockScriptCacheMockRecorder) SetNX(ctx, key, value, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNX", reflect.TypeOf((*MockScriptCache)(nil).SetNX), ctx, key, value, expiration)
+}
+
+// MockReadOnlyScriptCache is a mock of ReadOnlyScriptCache interface.
+type MockReadOnlyScriptCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockReadOnlyScriptCacheMockRecorder
+}
+
+// MockReadOnlyScriptCacheMockRecorder is the mock recorder for MockReadOnlyScriptCache.
+type MockReadOnlyScriptCacheMockRecorder struct {
+	mock *MockReadOnlyScriptCache
+}
+
+// NewMockReadOnlyScriptCache creates a new mock instance.
+func NewMockReadOnlyScriptCache(ctrl *gomock.Controller) *MockReadOnlyScriptCache {
+	mock := &MockReadOnlyScriptCache{ctrl: ctrl}
+	mock.recorder = &MockReadOnlyScriptCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReadOnlyScriptCache) EXPECT() *MockReadOnlyScriptCacheMockRecorder {
+	return m.recorder
+}
+
+// EvalReadOnly mocks base method.
+func (m *MockReadOnlyScriptCache) EvalReadOnly(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, script, keys}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "EvalReadOnly", varargs...)
+	ret0, _ := ret[0].(interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EvalReadOnly indicates an expected call of EvalReadOnly.
+func (mr *MockReadOnlyScriptCacheMockRecorder) EvalReadOnly(ctx, script, keys interface{}, args ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, script, keys}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EvalReadOnly", reflect.TypeOf((*MockReadOnlyScriptCache)(nil).EvalReadOnly), varargs...)
+}
+
+// MockLimitCache is a mock of LimitCache interface.
+type MockLimitCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockLimitCacheMockRecorder
+}
+
+// MockLimitCacheMockRecorder is the mock recorder for MockLimitCache.
+type MockLimitCacheMockRecorder struct {
+	mock *MockLimitCache
+}
+
+// NewMockLimitCache creates a new mock instance.
+func NewMockLimitCache(ctrl *gomock.Controller) *MockLimitCache {
+	mock := &MockLimitCache{ctrl: ctrl}
+	mock.recorder = &MockLimitCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLimitCache) EXPECT() *MockLimitCacheMockRecorder {
+	return m.recorder
+}
+
+// IncrByLimit mocks base method.
+func (m *MockLimitCache) IncrByLimit(ctx context.Context, key string, value, limit int64) (int64, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrByLimit", ctx, key, value, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// IncrByLimit indicates an expected call of IncrByLimit.
+func (mr *MockLimitCacheMockRecorder) IncrByLimit(ctx, key, value, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrByLimit", reflect.TypeOf((*MockLimitCache)(nil).IncrByLimit), ctx, key, value, limit)
+}
@@ -0,0 +1,84 @@
+package andromeda
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// quotaClassUsageScript gets the usage of every class from the hash of the class key
+const quotaClassUsageScript = `return redis.call('HMGET', KEYS[1], unpack(ARGV))`
+
+// getQuotaClass gets the class of the request from the classes of the quota, the shares of the classes must not be
+// negative or sum over the limit
+func getQuotaClass(ctx context.Context, getQuotaClass GetQuotaClass, req *QuotaRequest, classes []QuotaClass) (*QuotaClass, error) {
+	var shares int64
+	for _, class := range classes {
+		if class.Share < 0 {
+			return nil, fmt.Errorf("%w: share %d of class %s of quota %s", ErrInvalidQuotaClass, class.Share, class.Name, req.QuotaID)
+		}
+		shares += class.Share
+	}
+	if shares > 100 {
+		return nil, fmt.Errorf("%w: shares %d of quota %s above 100 percent", ErrInvalidQuotaClass, shares, req.QuotaID)
+	}
+
+	name, err := getQuotaClass.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range classes {
+		if classes[i].Name == name {
+			return &classes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: class %s of quota %s", ErrInvalidQuotaClass, name, req.QuotaID)
+}
+
+// getQuotaClassStatuses gets the usage of every class and the remaining capacity the same way as the add script
+func getQuotaClassStatuses(ctx context.Context, cache Cache, key string, classes []QuotaClass, limit, usage int64) ([]*QuotaClassStatus, error) {
+	if len(classes) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(classes))
+	for i, class := range classes {
+		args[i] = class.Name
+	}
+
+	res, err := evalReadOnlyScript(ctx, cache, quotaClassUsageScript, []string{key}, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	vals, _ := res.([]interface{})
+	statuses := make([]*QuotaClassStatus, len(classes))
+	for i, class := range classes {
+		statuses[i] = &QuotaClassStatus{Class: class.Name, Reserved: limit * class.Share / 100}
+		if i < len(vals) && vals[i] != nil {
+			if statuses[i].Usage, err = strconv.ParseInt(fmt.Sprint(vals[i]), 10, 64); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i, status := range statuses {
+		status.Remaining = limit - usage
+		for j, other := range statuses {
+			if i != j && other.Reserved > other.Usage {
+				status.Remaining -= other.Reserved - other.Usage
+			}
+		}
+		if !classes[i].Borrow && status.Reserved-status.Usage < status.Remaining {
+			status.Remaining = status.Reserved - status.Usage
+		}
+	}
+
+	return statuses, nil
+}
+
+// NewQuotaClassLimitExceededError is a error helper for quota of a priority class exceeded
+func NewQuotaClassLimitExceededError(key, class string, limit, usage int64) error {
+	return &QuotaLimitExceededError{Key: key, Class: class, Limit: limit, Usage: usage}
+}
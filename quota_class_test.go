@@ -0,0 +1,119 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestQuotaClass(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	getQuotaClasses := &mockGetQuotaClasses{classes: []andromeda.QuotaClass{
+		{Name: "premium", Share: 20, Borrow: true},
+		{Name: "regular", Borrow: true},
+		{Name: "partner", Share: 30},
+	}}
+	addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+		Cache:            redisCache,
+		GetQuotaLimit:    &mockGetQuota{value: 10},
+		GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "class-usage-%s"},
+		GetQuotaClasses:  getQuotaClasses,
+		GetQuotaClass:    &mockGetQuotaClass{},
+		GetQuotaClassKey: &mockGetQuotaKey{keyFormat: "class-usage-%s-classes"},
+	})
+	reduceQuotaUsage := andromeda.ReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+		Cache:            redisCache,
+		GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "class-usage-%s"},
+		GetQuotaClass:    &mockGetQuotaClass{},
+		GetQuotaClassKey: &mockGetQuotaKey{keyFormat: "class-usage-%s-classes"},
+	})
+	getQuotaStatus := andromeda.NewGetQuotaStatus(andromeda.GetQuotaStatusConfig{
+		Cache:            redisCache,
+		GetQuotaLimit:    &mockGetQuota{value: 10},
+		GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "class-usage-%s"},
+		GetQuotaClasses:  getQuotaClasses,
+		GetQuotaClassKey: &mockGetQuotaKey{keyFormat: "class-usage-%s-classes"},
+	})
+
+	t.Run("KeepShareOfOtherClasses", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "regular", Usage: 5})
+		assert.Nil(t, err)
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "regular", Usage: 1})
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaClassLimitExceeded))
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+		assert.EqualError(t, err, "quota class limit exceeded: limit 5 and usage 5 for class regular of key class-usage-123")
+	})
+
+	t.Run("CapClassWithoutBorrowing", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "partner", Usage: 4})
+		assert.EqualError(t, err, andromeda.NewQuotaClassLimitExceededError("class-usage-123", "partner", 3, 0).Error())
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "partner", Usage: 3})
+		assert.Nil(t, err)
+	})
+
+	t.Run("UseGuaranteedShare", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "premium", Usage: 2})
+		assert.Nil(t, err)
+
+		status, err := getQuotaStatus.Do(ctx, &andromeda.QuotaRequest{QuotaID: "123"})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(10), status.Usage)
+		assert.Equal(t, []*andromeda.QuotaClassStatus{
+			{Class: "premium", Reserved: 2, Usage: 2, Remaining: 0},
+			{Class: "regular", Reserved: 0, Usage: 5, Remaining: 0},
+			{Class: "partner", Reserved: 3, Usage: 3, Remaining: 0},
+		}, status.Classes)
+	})
+
+	t.Run("ReduceClassUsage", func(t *testing.T) {
+		_, err := reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "regular", Usage: 2})
+		assert.Nil(t, err)
+
+		status, err := getQuotaStatus.Do(ctx, &andromeda.QuotaRequest{QuotaID: "123"})
+		assert.Nil(t, err)
+		assert.Equal(t, []*andromeda.QuotaClassStatus{
+			{Class: "premium", Reserved: 2, Usage: 2, Remaining: 2},
+			{Class: "regular", Reserved: 0, Usage: 3, Remaining: 2},
+			{Class: "partner", Reserved: 3, Usage: 3, Remaining: 0},
+		}, status.Classes)
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "premium", Usage: 2})
+		assert.Nil(t, err)
+	})
+
+	t.Run("ErrorInvalidClass", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "guest", Usage: 1})
+
+		assert.True(t, errors.Is(err, andromeda.ErrInvalidQuotaClass))
+	})
+
+	t.Run("ErrorSharesAboveLimit", func(t *testing.T) {
+		addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "class-usage-%s"},
+			GetQuotaClasses: &mockGetQuotaClasses{classes: []andromeda.QuotaClass{
+				{Name: "premium", Share: 70},
+				{Name: "partner", Share: 40},
+			}},
+			GetQuotaClass:    &mockGetQuotaClass{},
+			GetQuotaClassKey: &mockGetQuotaKey{keyFormat: "class-usage-%s-classes"},
+		})
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "456", Data: "premium", Usage: 1})
+
+		assert.True(t, errors.Is(err, andromeda.ErrInvalidQuotaClass))
+		assert.False(t, miniRedis.Exists("class-usage-456"))
+	})
+}
@@ -0,0 +1,21 @@
+package andromeda
+
+import "context"
+
+type percentQuota struct {
+	getQuota GetQuota
+	percent  int64
+}
+
+func (q *percentQuota) Do(ctx context.Context, req *QuotaRequest) (int64, error) {
+	val, err := q.getQuota.Do(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	return val * q.percent / 100, nil
+}
+
+// NewPercentQuota gets the percent of the quota, e.g. the overdraft of 5 percent of the limit
+func NewPercentQuota(getQuota GetQuota, percent int64) GetQuota {
+	return &percentQuota{getQuota: getQuota, percent: percent}
+}
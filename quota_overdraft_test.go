@@ -0,0 +1,161 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type mockOverdraftListener struct {
+	*mocks.MockUpdateQuotaUsageListener
+	*mocks.MockQuotaOverdraftListener
+}
+
+func TestQuotaOverdraft(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	mockListener := &mockOverdraftListener{
+		MockUpdateQuotaUsageListener: mocks.NewMockUpdateQuotaUsageListener(mockCtrl),
+		MockQuotaOverdraftListener:   mocks.NewMockQuotaOverdraftListener(mockCtrl),
+	}
+	mockNext := mocks.NewMockUpdateQuotaUsage(mockCtrl)
+	conf := andromeda.AddQuotaUsageConfig{
+		Cache:                redisCache,
+		GetQuotaLimit:        &mockGetQuota{value: 100},
+		GetQuotaUsageKey:     &mockGetQuotaKey{keyFormat: "overdraft-usage-%s"},
+		GetQuotaOverdraft:    andromeda.NewPercentQuota(&mockGetQuota{value: 100}, 10),
+		GetQuotaOverdraftKey: &mockGetQuotaKey{keyFormat: "overdraft-usage-%s-overdraft"},
+		Next:                 mockNext,
+		Option:               andromeda.AddUsageOption{Listener: mockListener},
+	}
+	addQuotaUsage := andromeda.AddQuotaUsage(conf)
+	getQuotaStatus := andromeda.NewGetQuotaStatus(andromeda.GetQuotaStatusConfig{
+		Cache:                redisCache,
+		GetQuotaLimit:        &mockGetQuota{value: 100},
+		GetQuotaUsageKey:     &mockGetQuotaKey{keyFormat: "overdraft-usage-%s"},
+		GetQuotaOverdraftKey: &mockGetQuotaKey{keyFormat: "overdraft-usage-%s-overdraft"},
+	})
+	reduceQuotaUsage := andromeda.ReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+		Cache:                redisCache,
+		GetQuotaUsageKey:     &mockGetQuotaKey{keyFormat: "overdraft-usage-%s"},
+		GetQuotaOverdraftKey: &mockGetQuotaKey{keyFormat: "overdraft-usage-%s-overdraft"},
+		Next:                 mockNext,
+	})
+	getCache := func(key string) string {
+		val, _ := miniRedis.Get(key)
+		return val
+	}
+
+	t.Run("WithinLimit", func(t *testing.T) {
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 95}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil)
+		mockListener.MockUpdateQuotaUsageListener.EXPECT().OnSuccess(ctx, req, int64(95))
+
+		_, err := addQuotaUsage.Do(ctx, req)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "", getCache("overdraft-usage-123-overdraft"))
+	})
+
+	t.Run("AcceptOverdraft", func(t *testing.T) {
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 10}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil)
+		mockListener.MockUpdateQuotaUsageListener.EXPECT().OnSuccess(ctx, req, int64(105))
+		mockListener.MockQuotaOverdraftListener.EXPECT().OnOverdraft(ctx, req, int64(5))
+
+		_, err := addQuotaUsage.Do(ctx, req)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "105", getCache("overdraft-usage-123"))
+		assert.Equal(t, "5", getCache("overdraft-usage-123-overdraft"))
+	})
+
+	t.Run("CheckOverdraft", func(t *testing.T) {
+		check, err := andromeda.CheckAddQuotaUsage(conf).Check(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), check.Overdraft)
+		assert.Equal(t, int64(100), check.Limit)
+		assert.Equal(t, int64(10), check.Allowance)
+		assert.Equal(t, int64(-7), check.Remaining)
+	})
+
+	t.Run("CheckOverdraftExceeded", func(t *testing.T) {
+		check, err := andromeda.CheckAddQuotaUsage(conf).Check(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 6})
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+		assert.Equal(t, int64(100), check.Limit)
+		assert.Equal(t, int64(10), check.Allowance)
+	})
+
+	t.Run("ReverseOverdraftWhenNextHasError", func(t *testing.T) {
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 3}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, errors.New("error"))
+
+		_, err := addQuotaUsage.Do(ctx, req)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "105", getCache("overdraft-usage-123"))
+		assert.Equal(t, "5", getCache("overdraft-usage-123-overdraft"))
+	})
+
+	t.Run("ErrorOverdraftExceeded", func(t *testing.T) {
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 6}
+		mockListener.MockUpdateQuotaUsageListener.EXPECT().OnError(ctx, req, gomock.Any())
+
+		_, err := addQuotaUsage.Do(ctx, req)
+
+		var limitErr *andromeda.QuotaLimitExceededError
+		assert.True(t, errors.As(err, &limitErr))
+		assert.Equal(t, &andromeda.QuotaLimitExceededError{Key: "overdraft-usage-123", Limit: 100, Allowance: 10, Usage: 105}, limitErr)
+		assert.EqualError(t, err, "quota limit exceeded: limit 100 and usage 105 for key overdraft-usage-123, overdraft 10")
+	})
+
+	t.Run("StatusWithOverdraft", func(t *testing.T) {
+		status, err := getQuotaStatus.Do(ctx, &andromeda.QuotaRequest{QuotaID: "123"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, &andromeda.QuotaStatus{Key: "overdraft-usage-123", Limit: 100, Usage: 105, Remaining: -5, Overdraft: 5}, status)
+	})
+
+	t.Run("ReverseRefundOfOverdraftWhenNextHasError", func(t *testing.T) {
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 3}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, errors.New("error"))
+
+		_, err := reduceQuotaUsage.Do(ctx, req)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "105", getCache("overdraft-usage-123"))
+		assert.Equal(t, "5", getCache("overdraft-usage-123-overdraft"))
+	})
+
+	t.Run("RefundOverdraftFirst", func(t *testing.T) {
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 3}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil).Times(2)
+
+		_, err := reduceQuotaUsage.Do(ctx, req)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "102", getCache("overdraft-usage-123"))
+		assert.Equal(t, "2", getCache("overdraft-usage-123-overdraft"))
+
+		_, err = reduceQuotaUsage.Do(ctx, req)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "99", getCache("overdraft-usage-123"))
+		assert.Equal(t, "0", getCache("overdraft-usage-123-overdraft"))
+	})
+}
@@ -0,0 +1,145 @@
+package andromeda
+
+import (
+	"context"
+	"math/big"
+	"time"
+)
+
+// QuotaReleaseMode is a mode of releasing the quota limit
+type QuotaReleaseMode string
+
+const (
+	QuotaReleaseStep   QuotaReleaseMode = "step"   // releases the amount at the start of every interval
+	QuotaReleaseLinear QuotaReleaseMode = "linear" // releases the amount evenly during every interval
+)
+
+// QuotaRelease is a model for releasing the quota limit in waves
+type QuotaRelease struct {
+	StartAt  time.Time
+	Interval time.Duration
+	Amount   int64 // released amount for every interval
+	Mode     QuotaReleaseMode
+}
+
+// Released returns the total released amount at the given time
+func (r *QuotaRelease) Released(at time.Time) int64 {
+	if at.Before(r.StartAt) || r.Interval <= 0 {
+		return 0
+	}
+
+	elapsed := at.Sub(r.StartAt)
+	if r.Mode == QuotaReleaseLinear {
+		waves := int64(elapsed / r.Interval)
+		rest := elapsed % r.Interval
+		return waves*r.Amount + mulDiv(r.Amount, int64(rest), int64(r.Interval), false)
+	}
+	return (int64(elapsed/r.Interval) + 1) * r.Amount
+}
+
+// NextAt returns the time of the next release after the given time
+func (r *QuotaRelease) NextAt(at time.Time) time.Time {
+	if at.Before(r.StartAt) {
+		return r.StartAt
+	}
+	if r.Interval <= 0 || r.Amount <= 0 {
+		return time.Time{}
+	}
+
+	elapsed := at.Sub(r.StartAt)
+	if r.Mode == QuotaReleaseLinear {
+		next := r.Released(at) + 1
+		waves := next / r.Amount
+		rest := next % r.Amount
+		// round up to the first nanosecond where the next unit is released
+		offset := mulDiv(int64(r.Interval), rest, r.Amount, true)
+		return r.StartAt.Add(time.Duration(waves)*r.Interval + time.Duration(offset))
+	}
+	return r.StartAt.Add((elapsed/r.Interval + 1) * r.Interval)
+}
+
+// mulDiv returns a*b/c without the overflow of the product of the amount and the nanoseconds
+func mulDiv(a, b, c int64, roundUp bool) int64 {
+	product := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	quo, rem := new(big.Int).QuoRem(product, big.NewInt(c), new(big.Int))
+	if roundUp && rem.Sign() > 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	return quo.Int64()
+}
+
+// GetQuotaNextRelease is an optional contract of a quota limit that is released in waves,
+// the time of the next release is reported in QuotaLimitExceededError
+type GetQuotaNextRelease interface {
+	NextReleaseAt(ctx context.Context, req *QuotaRequest) (time.Time, error)
+}
+
+// ReleaseQuotaLimitConfig .
+type ReleaseQuotaLimitConfig struct {
+	GetQuotaLimit   GetQuota // total limit of the quota
+	GetQuotaRelease GetQuotaRelease
+	Now             func() time.Time // optional, default is time.Now
+}
+
+type releaseQuotaLimit struct {
+	getQuotaLimit   GetQuota
+	getQuotaRelease GetQuotaRelease
+	now             func() time.Time
+}
+
+func (q *releaseQuotaLimit) Do(ctx context.Context, req *QuotaRequest) (int64, error) {
+	limit, err := q.getQuotaLimit.Do(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+
+	release, err := q.getQuotaRelease.Do(ctx, req)
+	if err != nil {
+		return 0, err
+	} else if release == nil {
+		return limit, nil
+	}
+
+	if released := release.Released(q.now()); released < limit {
+		return released, nil
+	}
+	return limit, nil
+}
+
+func (q *releaseQuotaLimit) NextReleaseAt(ctx context.Context, req *QuotaRequest) (time.Time, error) {
+	limit, err := q.getQuotaLimit.Do(ctx, req)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	release, err := q.getQuotaRelease.Do(ctx, req)
+	if err != nil || release == nil {
+		return time.Time{}, err
+	}
+
+	now := q.now()
+	if release.Released(now) >= limit {
+		return time.Time{}, nil
+	}
+	return release.NextAt(now), nil
+}
+
+// NewReleaseQuotaLimit gets the released part of the quota limit at request time,
+// it is used as GetQuotaLimit of add quota usage without GetQuotaLimitKey since the cached limit is not released
+func NewReleaseQuotaLimit(conf ReleaseQuotaLimitConfig) GetQuota {
+	if conf.GetQuotaLimit == nil {
+		panic("GetQuotaLimit is required")
+	}
+	if conf.GetQuotaRelease == nil {
+		panic("GetQuotaRelease is required")
+	}
+	if conf.Now == nil {
+		conf.Now = time.Now
+	}
+
+	return &releaseQuotaLimit{
+		getQuotaLimit:   conf.GetQuotaLimit,
+		getQuotaRelease: conf.GetQuotaRelease,
+		now:             conf.Now,
+	}
+}
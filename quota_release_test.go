@@ -0,0 +1,169 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestQuotaRelease(t *testing.T) {
+	startAt := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("Step", func(t *testing.T) {
+		release := &andromeda.QuotaRelease{StartAt: startAt, Interval: time.Hour, Amount: 1000, Mode: andromeda.QuotaReleaseStep}
+
+		assert.Equal(t, int64(0), release.Released(startAt.Add(-time.Second)))
+		assert.Equal(t, int64(1000), release.Released(startAt))
+		assert.Equal(t, int64(2000), release.Released(startAt.Add(time.Hour+time.Minute)))
+		assert.Equal(t, startAt, release.NextAt(startAt.Add(-time.Second)))
+		assert.Equal(t, startAt.Add(time.Hour*2), release.NextAt(startAt.Add(time.Hour+time.Minute)))
+	})
+
+	t.Run("Linear", func(t *testing.T) {
+		release := &andromeda.QuotaRelease{StartAt: startAt, Interval: time.Hour, Amount: 60, Mode: andromeda.QuotaReleaseLinear}
+
+		assert.Equal(t, int64(0), release.Released(startAt))
+		assert.Equal(t, int64(30), release.Released(startAt.Add(time.Minute*30+time.Second)))
+		assert.Equal(t, int64(90), release.Released(startAt.Add(time.Minute*90)))
+		assert.Equal(t, startAt.Add(time.Minute*31), release.NextAt(startAt.Add(time.Minute*30+time.Second)))
+		assert.Equal(t, startAt.Add(time.Minute*91), release.NextAt(startAt.Add(time.Minute*90)))
+	})
+
+	t.Run("LinearLargeAmount", func(t *testing.T) {
+		release := &andromeda.QuotaRelease{StartAt: startAt, Interval: time.Hour * 24, Amount: 1e9, Mode: andromeda.QuotaReleaseLinear}
+
+		assert.Equal(t, int64(5e8), release.Released(startAt.Add(time.Hour*12)))
+		assert.Equal(t, startAt.Add(time.Hour*12+time.Nanosecond*86400), release.NextAt(startAt.Add(time.Hour*12)))
+	})
+}
+
+func TestReleaseQuotaLimit(t *testing.T) {
+	ctx := context.TODO()
+	mockCtrl := gomock.NewController(t)
+	mockGetQuotaRelease := mocks.NewMockGetQuotaRelease(mockCtrl)
+	startAt := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	now := startAt.Add(time.Minute * 30)
+	req := &andromeda.QuotaRequest{QuotaID: "123"}
+	release := &andromeda.QuotaRelease{StartAt: startAt, Interval: time.Hour, Amount: 1000, Mode: andromeda.QuotaReleaseStep}
+
+	t.Run("ReleasedLimit", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		getQuotaLimit := andromeda.NewReleaseQuotaLimit(andromeda.ReleaseQuotaLimitConfig{
+			GetQuotaLimit:   &mockGetQuota{value: 5000},
+			GetQuotaRelease: mockGetQuotaRelease,
+			Now:             func() time.Time { return now },
+		})
+		mockGetQuotaRelease.EXPECT().Do(ctx, req).Return(release, nil)
+
+		limit, err := getQuotaLimit.Do(ctx, req)
+
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1000), limit)
+	})
+
+	t.Run("CappedByTotalLimit", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		getQuotaLimit := andromeda.NewReleaseQuotaLimit(andromeda.ReleaseQuotaLimitConfig{
+			GetQuotaLimit:   &mockGetQuota{value: 500},
+			GetQuotaRelease: mockGetQuotaRelease,
+			Now:             func() time.Time { return now },
+		})
+		mockGetQuotaRelease.EXPECT().Do(ctx, req).Return(release, nil).Times(2)
+
+		limit, err := getQuotaLimit.Do(ctx, req)
+		nextAt, _ := getQuotaLimit.(andromeda.GetQuotaNextRelease).NextReleaseAt(ctx, req)
+
+		assert.Nil(t, err)
+		assert.Equal(t, int64(500), limit)
+		assert.True(t, nextAt.IsZero())
+	})
+
+	t.Run("WholeLimitWithoutRelease", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		getQuotaLimit := andromeda.NewReleaseQuotaLimit(andromeda.ReleaseQuotaLimitConfig{
+			GetQuotaLimit:   &mockGetQuota{value: 5000},
+			GetQuotaRelease: mockGetQuotaRelease,
+		})
+		mockGetQuotaRelease.EXPECT().Do(ctx, req).Return(nil, nil).Times(2)
+
+		limit, err := getQuotaLimit.Do(ctx, req)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(5000), limit)
+
+		nextAt, err := getQuotaLimit.(andromeda.GetQuotaNextRelease).NextReleaseAt(ctx, req)
+		assert.Nil(t, err)
+		assert.True(t, nextAt.IsZero())
+	})
+
+	t.Run("ErrorGetQuotaRelease", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		getQuotaLimit := andromeda.NewReleaseQuotaLimit(andromeda.ReleaseQuotaLimitConfig{
+			GetQuotaLimit:   &mockGetQuota{value: 5000},
+			GetQuotaRelease: mockGetQuotaRelease,
+		})
+		mockErr := errors.New("unexpected")
+		mockGetQuotaRelease.EXPECT().Do(ctx, req).Return(nil, mockErr)
+
+		limit, err := getQuotaLimit.Do(ctx, req)
+
+		assert.EqualError(t, err, mockErr.Error())
+		assert.Equal(t, int64(0), limit)
+	})
+
+	t.Run("ErrorReportsNextRelease", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		miniRedis, err := miniredis.Run()
+		assert.Nil(t, err)
+
+		addQuotaUsage := andromeda.NewAtomicAddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()})),
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "release-usage-%s"},
+			GetQuotaLimit: andromeda.NewReleaseQuotaLimit(andromeda.ReleaseQuotaLimitConfig{
+				GetQuotaLimit:   &mockGetQuota{value: 5000},
+				GetQuotaRelease: mockGetQuotaRelease,
+				Now:             func() time.Time { return now },
+			}),
+		})
+		assert.Nil(t, miniRedis.Set("release-usage-123", "1000"))
+		mockGetQuotaRelease.EXPECT().Do(ctx, req).Return(release, nil).Times(3)
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1})
+
+		var limitErr *andromeda.QuotaLimitExceededError
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+		assert.True(t, errors.As(err, &limitErr))
+		assert.Equal(t, startAt.Add(time.Hour), limitErr.NextReleaseAt)
+		assert.EqualError(t, err, "quota limit exceeded: limit 1000 and usage 1000 for key release-usage-123, next release at 2021-05-01T11:00:00Z")
+	})
+}
+
+func TestNewReleaseQuotaLimit(t *testing.T) {
+	assert.PanicsWithValue(t, "GetQuotaLimit is required", func() {
+		andromeda.NewReleaseQuotaLimit(andromeda.ReleaseQuotaLimitConfig{})
+	})
+	assert.PanicsWithValue(t, "GetQuotaRelease is required", func() {
+		andromeda.NewReleaseQuotaLimit(andromeda.ReleaseQuotaLimitConfig{GetQuotaLimit: &mockGetQuota{}})
+	})
+	assert.PanicsWithValue(t, "GetQuotaLimitKey must not be set with a released limit", func() {
+		andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:                   cache.NewCacheRedis(redis.NewClient(&redis.Options{})),
+			GetQuotaLimit:           andromeda.NewReleaseQuotaLimit(andromeda.ReleaseQuotaLimitConfig{GetQuotaLimit: &mockGetQuota{}, GetQuotaRelease: mocks.NewMockGetQuotaRelease(gomock.NewController(t))}),
+			GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "release-%s"},
+			GetQuotaLimitKey:        &mockGetQuotaKey{keyFormat: "release-%s-limit"},
+			GetQuotaLimitExpiration: &mockGetQuotaExp{},
+		})
+	})
+}
@@ -0,0 +1,38 @@
+package andromeda
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuotaScheduleError is error for the quota usage outside of the schedule
+type QuotaScheduleError struct {
+	Err      error // ErrQuotaNotOpen or ErrQuotaClosed
+	Key      string
+	Schedule QuotaSchedule
+	Now      time.Time // time of the cache server
+}
+
+func (e *QuotaScheduleError) Error() string {
+	if e.Err == ErrQuotaNotOpen {
+		return fmt.Sprintf("%v: opens at %s for key %s", e.Err, e.Schedule.StartAt.Format(time.RFC3339), e.Key)
+	}
+	return fmt.Sprintf("%v: closed at %s for key %s", e.Err, e.Schedule.EndAt.Format(time.RFC3339), e.Key)
+}
+
+func (e *QuotaScheduleError) Unwrap() error {
+	return e.Err
+}
+
+// NewQuotaScheduleError is a error helper for the quota usage outside of the schedule
+func NewQuotaScheduleError(err error, key string, schedule *QuotaSchedule, now time.Time) error {
+	return &QuotaScheduleError{Err: err, Key: key, Schedule: *schedule, Now: now}
+}
+
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromUnixMilli(msec int64) time.Time {
+	return time.Unix(0, msec*int64(time.Millisecond))
+}
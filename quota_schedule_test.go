@@ -0,0 +1,70 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestQuotaSchedule(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	startAt := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	endAt := startAt.Add(time.Hour)
+	addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+		Cache:                   redisCache,
+		GetQuotaLimit:           &mockGetQuota{value: 10},
+		GetQuotaUsage:           &mockGetQuota{value: 0},
+		GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "schedule-usage-%s"},
+		GetQuotaUsageExpiration: &mockGetQuotaExp{},
+		GetQuotaSchedule:        &mockGetQuotaSchedule{schedule: andromeda.QuotaSchedule{StartAt: startAt, EndAt: endAt}},
+	})
+	getCache := func(key string) string {
+		val, _ := miniRedis.Get(key)
+		return val
+	}
+
+	t.Run("ErrorQuotaNotOpen", func(t *testing.T) {
+		miniRedis.SetTime(startAt.Add(-time.Minute))
+
+		res, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1})
+
+		var scheduleErr *andromeda.QuotaScheduleError
+		assert.Nil(t, res)
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaNotOpen))
+		assert.True(t, errors.As(err, &scheduleErr))
+		assert.True(t, startAt.Equal(scheduleErr.Schedule.StartAt))
+		assert.True(t, startAt.Add(-time.Minute).Equal(scheduleErr.Now))
+		assert.EqualError(t, err, "quota not open: opens at 2021-05-01T10:00:00Z for key schedule-usage-123")
+		assert.Equal(t, "0", getCache("schedule-usage-123"))
+	})
+
+	t.Run("SucceedWhenOpen", func(t *testing.T) {
+		miniRedis.SetTime(startAt)
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1", getCache("schedule-usage-123"))
+	})
+
+	t.Run("ErrorQuotaClosed", func(t *testing.T) {
+		miniRedis.SetTime(endAt)
+
+		res, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1})
+
+		assert.Nil(t, res)
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaClosed))
+		assert.EqualError(t, err, "quota closed: closed at 2021-05-01T11:00:00Z for key schedule-usage-123")
+		assert.Equal(t, "1", getCache("schedule-usage-123"))
+	})
+}
@@ -0,0 +1,183 @@
+package andromeda
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// QuotaSemaphoreConfig .
+type QuotaSemaphoreConfig struct {
+	Cache                   Cache
+	GetQuotaLimit           GetQuota    // max active leases
+	GetQuotaKey             GetQuotaKey // keeps the active leases by their expiration
+	GetQuotaLeaseExpiration GetQuotaExpiration
+}
+
+// QuotaSemaphore is a contract to limit the concurrent usage of a quota by leases, e.g. max 3 active streams per account,
+// the lease of a crashed client expires by itself
+type QuotaSemaphore interface {
+	Acquire(ctx context.Context, req *QuotaRequest) (*QuotaLease, error)
+	Renew(ctx context.Context, lease *QuotaLease) (*QuotaLease, error)
+	Release(ctx context.Context, lease *QuotaLease) error
+}
+
+// QuotaLease is a model for an active slot of the quota semaphore
+type QuotaLease struct {
+	Request   *QuotaRequest
+	Key       string
+	ID        string
+	ExpiresAt time.Time
+}
+
+// acquireQuotaLeaseScript removes the expired leases before it counts the active leases against the limit
+const acquireQuotaLeaseScript = usageScriptPrelude + `
+local now = now()
+redis.call('ZREMRANGEBYSCORE', key('lease'), '-inf', now)
+
+local active = redis.call('ZCARD', key('lease'))
+if active >= tonumber(p.limit) then
+	return {1, active, tonumber(p.limit)}
+end
+
+local expireAt = now + tonumber(p.expiration)
+redis.call('ZADD', key('lease'), expireAt, p.id)
+if redis.call('PTTL', key('lease')) < tonumber(p.expiration) then
+	redis.call('PEXPIRE', key('lease'), p.expiration)
+end
+return {0, expireAt}
+`
+
+const renewQuotaLeaseScript = usageScriptPrelude + `
+local now = now()
+redis.call('ZREMRANGEBYSCORE', key('lease'), '-inf', now)
+if not redis.call('ZSCORE', key('lease'), p.id) then
+	return {2, 0}
+end
+
+local expireAt = now + tonumber(p.expiration)
+redis.call('ZADD', key('lease'), 'XX', expireAt, p.id)
+if redis.call('PTTL', key('lease')) < tonumber(p.expiration) then
+	redis.call('PEXPIRE', key('lease'), p.expiration)
+end
+return {0, expireAt}
+`
+
+const releaseQuotaLeaseScript = usageScriptPrelude + `
+redis.call('ZREMRANGEBYSCORE', key('lease'), '-inf', now())
+if redis.call('ZREM', key('lease'), p.id) == 0 then
+	return {2, 0}
+end
+return {0, 0}
+`
+
+type quotaSemaphore struct {
+	cache                   Cache
+	getQuotaLimit           GetQuota
+	getQuotaKey             GetQuotaKey
+	getQuotaLeaseExpiration GetQuotaExpiration
+}
+
+func (s *quotaSemaphore) Acquire(ctx context.Context, req *QuotaRequest) (*QuotaLease, error) {
+	key, err := s.getQuotaKey.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := s.getQuotaLimit.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	exp, err := s.getQuotaLeaseExpiration.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newQuotaLeaseID()
+	if err != nil {
+		return nil, err
+	}
+
+	script := new(usageScript).withKey("lease", key).withArg("id", id).withArg("limit", limit).withArg("expiration", exp.Milliseconds())
+	code, values, err := script.run(ctx, s.cache, acquireQuotaLeaseScript)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+	}
+
+	if code == usageScriptLimitExceeded {
+		return nil, NewQuotaLimitExceededError(key, values[1], values[0])
+	}
+
+	return &QuotaLease{Request: req, Key: key, ID: id, ExpiresAt: fromUnixMilli(values[0])}, nil
+}
+
+func (s *quotaSemaphore) Renew(ctx context.Context, lease *QuotaLease) (*QuotaLease, error) {
+	exp, err := s.getQuotaLeaseExpiration.Do(ctx, lease.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	script := new(usageScript).withKey("lease", lease.Key).withArg("id", lease.ID).withArg("expiration", exp.Milliseconds())
+	code, values, err := script.run(ctx, s.cache, renewQuotaLeaseScript)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+	}
+
+	if code == usageScriptNotFound {
+		return nil, NewLeaseNotFoundError(lease)
+	}
+
+	return &QuotaLease{Request: lease.Request, Key: lease.Key, ID: lease.ID, ExpiresAt: fromUnixMilli(values[0])}, nil
+}
+
+func (s *quotaSemaphore) Release(ctx context.Context, lease *QuotaLease) error {
+	code, _, err := new(usageScript).withKey("lease", lease.Key).withArg("id", lease.ID).run(ctx, s.cache, releaseQuotaLeaseScript)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrReduceQuotaUsage, err)
+	}
+
+	if code == usageScriptNotFound {
+		return NewLeaseNotFoundError(lease)
+	}
+	return nil
+}
+
+func newQuotaLeaseID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewLeaseNotFoundError is a error helper for the lease that is expired or released
+func NewLeaseNotFoundError(lease *QuotaLease) error {
+	return fmt.Errorf("%w: lease %s for key %s", ErrLeaseNotFound, lease.ID, lease.Key)
+}
+
+// NewQuotaSemaphore .
+func NewQuotaSemaphore(conf QuotaSemaphoreConfig) QuotaSemaphore {
+	if conf.Cache == nil {
+		panic("Cache is required")
+	}
+	mustScriptCache(conf.Cache)
+	if conf.GetQuotaLimit == nil {
+		panic("GetQuotaLimit is required")
+	}
+	if conf.GetQuotaKey == nil {
+		panic("GetQuotaKey is required")
+	}
+	if conf.GetQuotaLeaseExpiration == nil {
+		panic("GetQuotaLeaseExpiration is required")
+	}
+
+	return &quotaSemaphore{
+		cache:                   conf.Cache,
+		getQuotaLimit:           conf.GetQuotaLimit,
+		getQuotaKey:             conf.GetQuotaKey,
+		getQuotaLeaseExpiration: conf.GetQuotaLeaseExpiration,
+	}
+}
@@ -0,0 +1,80 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestQuotaSemaphore(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	now := time.Now().Truncate(time.Millisecond)
+	miniRedis.SetTime(now)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	semaphore := andromeda.NewQuotaSemaphore(andromeda.QuotaSemaphoreConfig{
+		Cache:                   redisCache,
+		GetQuotaLimit:           &mockGetQuota{value: 2},
+		GetQuotaKey:             &mockGetQuotaKey{keyFormat: "stream-%s"},
+		GetQuotaLeaseExpiration: &mockGetQuotaExp{},
+	})
+	req := &andromeda.QuotaRequest{QuotaID: "123"}
+
+	first, err := semaphore.Acquire(ctx, req)
+	assert.Nil(t, err)
+	second, err := semaphore.Acquire(ctx, req)
+	assert.Nil(t, err)
+
+	t.Run("AcquireLease", func(t *testing.T) {
+		assert.Equal(t, "stream-123", first.Key)
+		assert.NotEqual(t, first.ID, second.ID)
+		assert.Equal(t, now.Add(time.Second*30), first.ExpiresAt)
+	})
+
+	t.Run("ErrorLimitExceeded", func(t *testing.T) {
+		_, err := semaphore.Acquire(ctx, req)
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+		assert.EqualError(t, err, andromeda.NewQuotaLimitExceededError("stream-123", 2, 2).Error())
+	})
+
+	t.Run("ReleaseLease", func(t *testing.T) {
+		assert.Nil(t, semaphore.Release(ctx, second))
+		assert.True(t, errors.Is(semaphore.Release(ctx, second), andromeda.ErrLeaseNotFound))
+
+		second, err = semaphore.Acquire(ctx, req)
+		assert.Nil(t, err)
+	})
+
+	t.Run("RenewLease", func(t *testing.T) {
+		miniRedis.SetTime(now.Add(time.Second * 20))
+
+		renewed, err := semaphore.Renew(ctx, first)
+
+		assert.Nil(t, err)
+		assert.Equal(t, first.ID, renewed.ID)
+		assert.Equal(t, now.Add(time.Second*50), renewed.ExpiresAt)
+	})
+
+	t.Run("ExpireLease", func(t *testing.T) {
+		miniRedis.SetTime(now.Add(time.Second * 40))
+
+		_, err := semaphore.Renew(ctx, second)
+		assert.True(t, errors.Is(err, andromeda.ErrLeaseNotFound))
+
+		_, err = semaphore.Acquire(ctx, req)
+		assert.Nil(t, err)
+
+		_, err = semaphore.Acquire(ctx, req)
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+	})
+}
@@ -0,0 +1,61 @@
+package andromeda
+
+import (
+	"context"
+	"fmt"
+)
+
+// QuotaDirection is a direction of quota usage changes
+type QuotaDirection string
+
+// QuotaState is a state of a quota direction, the state is active when it is not set
+type QuotaState string
+
+const (
+	QuotaDirectionAdd    QuotaDirection = "add"
+	QuotaDirectionReduce QuotaDirection = "reduce"
+
+	QuotaStateActive QuotaState = "active"
+	QuotaStatePaused QuotaState = "paused"
+	QuotaStateClosed QuotaState = "closed"
+)
+
+const setQuotaStateScript = `
+if ARGV[2] == 'active' then
+	return redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+`
+
+const getQuotaStateScript = `
+return redis.call('HGET', KEYS[1], ARGV[1]) or 'active'
+`
+
+func setQuotaState(ctx context.Context, cache Cache, key string, direction QuotaDirection, state QuotaState) error {
+	switch state {
+	case QuotaStateActive, QuotaStatePaused, QuotaStateClosed:
+	default:
+		return fmt.Errorf("invalid quota state %q", state)
+	}
+
+	_, err := evalScript(ctx, cache, setQuotaStateScript, []string{key}, string(direction), string(state))
+	return err
+}
+
+func getQuotaState(ctx context.Context, cache Cache, key string, direction QuotaDirection) (QuotaState, error) {
+	res, err := evalReadOnlyScript(ctx, cache, getQuotaStateScript, []string{key}, string(direction))
+	if err != nil {
+		return "", err
+	}
+
+	return QuotaState(fmt.Sprint(res)), nil
+}
+
+// NewQuotaStateError is a error helper for paused or closed quota
+func NewQuotaStateError(key string, direction QuotaDirection, state QuotaState) error {
+	err := ErrQuotaPaused
+	if state == QuotaStateClosed {
+		err = ErrQuotaClosed
+	}
+	return fmt.Errorf("%w: %s usage for key %s", err, direction, key)
+}
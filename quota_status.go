@@ -0,0 +1,137 @@
+package andromeda
+
+import (
+	"context"
+	"errors"
+)
+
+// GetQuotaStatusConfig .
+type GetQuotaStatusConfig struct {
+	Cache                Cache
+	GetQuotaLimit        GetQuota
+	GetQuotaUsage        GetQuota // optional, gets the usage when it is not in the cache
+	GetQuotaUsageKey     GetQuotaKey
+	GetQuotaLimitKey     GetQuotaKey     // optional, gets the limit from the cache before GetQuotaLimit
+	GetQuotaOverdraftKey GetQuotaKey     // optional, gets the overdraft usage from the cache
+	GetQuotaClasses      GetQuotaClasses // optional, gets the status of every priority class
+	GetQuotaClassKey     GetQuotaKey     // required when GetQuotaClasses is set
+	Scale                int             // decimal places of the minor units, zero for the integer quota
+}
+
+type getQuotaStatus struct {
+	cache             Cache
+	getQuotaLimit     GetQuota
+	getQuotaUsage     GetQuota
+	getQuotaUsageKey  GetQuotaKey
+	getQuotaOverdraft GetQuota
+	getQuotaClasses   GetQuotaClasses
+	getQuotaClassKey  GetQuotaKey
+	scale             int
+}
+
+func (q *getQuotaStatus) Do(ctx context.Context, req *QuotaRequest) (*QuotaStatus, error) {
+	key, err := q.getQuotaUsageKey.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := q.getQuotaLimit.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := q.getQuotaUsage.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &QuotaStatus{Key: key, Limit: limit, Usage: usage, Remaining: limit - usage, Scale: q.scale}
+	if q.getQuotaOverdraft != nil {
+		if status.Overdraft, err = q.getQuotaOverdraft.Do(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+
+	if q.getQuotaClasses != nil {
+		classes, err := q.getQuotaClasses.Do(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		classKey, err := q.getQuotaClassKey.Do(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if status.Classes, err = getQuotaClassStatuses(ctx, q.cache, classKey, classes, limit, usage); err != nil {
+			return nil, err
+		}
+	}
+
+	return status, nil
+}
+
+// fallbackQuota gets the quota from the next when the first is not found
+type fallbackQuota struct {
+	first, next GetQuota
+}
+
+func (q *fallbackQuota) Do(ctx context.Context, req *QuotaRequest) (int64, error) {
+	val, err := q.first.Do(ctx, req)
+	if errors.Is(err, ErrQuotaNotFound) {
+		return q.next.Do(ctx, req)
+	}
+	return val, err
+}
+
+// zeroQuota is the usage of a quota that has not been used
+type zeroQuota struct{}
+
+func (q *zeroQuota) Do(_ context.Context, _ *QuotaRequest) (int64, error) {
+	return 0, nil
+}
+
+// NewGetQuotaStatus gets the limit and the usage of a quota from the cache, the usage is zero when it is not found
+func NewGetQuotaStatus(conf GetQuotaStatusConfig) GetQuotaStatus {
+	if conf.Cache == nil {
+		panic("Cache is required")
+	}
+	if conf.GetQuotaLimit == nil {
+		panic("GetQuotaLimit is required")
+	}
+	if conf.GetQuotaUsageKey == nil {
+		panic("GetQuotaUsageKey is required")
+	}
+	if conf.GetQuotaClasses != nil && conf.GetQuotaClassKey == nil {
+		panic("GetQuotaClassKey is required")
+	}
+	if conf.GetQuotaClasses != nil {
+		mustScriptCache(conf.Cache)
+	}
+
+	getQuotaLimit := conf.GetQuotaLimit
+	if conf.GetQuotaLimitKey != nil {
+		getQuotaLimit = &fallbackQuota{first: NewGetCachedQuota(conf.Cache, conf.GetQuotaLimitKey), next: getQuotaLimit}
+	}
+
+	getQuotaUsage := conf.GetQuotaUsage
+	if getQuotaUsage == nil {
+		getQuotaUsage = &zeroQuota{}
+	}
+
+	var getQuotaOverdraft GetQuota
+	if conf.GetQuotaOverdraftKey != nil {
+		getQuotaOverdraft = &fallbackQuota{first: NewGetCachedQuota(conf.Cache, conf.GetQuotaOverdraftKey), next: &zeroQuota{}}
+	}
+
+	return &getQuotaStatus{
+		cache:             conf.Cache,
+		getQuotaLimit:     getQuotaLimit,
+		getQuotaUsage:     &fallbackQuota{first: NewGetCachedQuota(conf.Cache, conf.GetQuotaUsageKey), next: getQuotaUsage},
+		getQuotaUsageKey:  conf.GetQuotaUsageKey,
+		getQuotaOverdraft: getQuotaOverdraft,
+		getQuotaClasses:   conf.GetQuotaClasses,
+		getQuotaClassKey:  conf.GetQuotaClassKey,
+		scale:             conf.Scale,
+	}
+}
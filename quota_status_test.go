@@ -0,0 +1,52 @@
+package andromeda_test
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetQuotaStatus(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	getQuotaStatus := andromeda.NewGetQuotaStatus(andromeda.GetQuotaStatusConfig{
+		Cache:            redisCache,
+		GetQuotaLimit:    &mockGetQuota{value: 10},
+		GetQuotaUsage:    &mockGetQuota{value: 2},
+		GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "status-usage-%s"},
+		GetQuotaLimitKey: &mockGetQuotaKey{keyFormat: "status-limit-%s"},
+	})
+
+	t.Run("FromSource", func(t *testing.T) {
+		status, err := getQuotaStatus.Do(ctx, &andromeda.QuotaRequest{QuotaID: "123"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, &andromeda.QuotaStatus{Key: "status-usage-123", Limit: 10, Usage: 2, Remaining: 8}, status)
+	})
+
+	t.Run("FromCache", func(t *testing.T) {
+		assert.Nil(t, miniRedis.Set("status-usage-123", "5"))
+		assert.Nil(t, miniRedis.Set("status-limit-123", "7"))
+
+		status, err := getQuotaStatus.Do(ctx, &andromeda.QuotaRequest{QuotaID: "123"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, &andromeda.QuotaStatus{Key: "status-usage-123", Limit: 7, Usage: 5, Remaining: 2}, status)
+	})
+}
+
+func TestNewGetQuotaStatus(t *testing.T) {
+	assert.PanicsWithValue(t, "Cache is required", func() {
+		andromeda.NewGetQuotaStatus(andromeda.GetQuotaStatusConfig{})
+	})
+	assert.PanicsWithValue(t, "GetQuotaUsageKey is required", func() {
+		andromeda.NewGetQuotaStatus(andromeda.GetQuotaStatusConfig{Cache: cache.NewCacheRedis(nil), GetQuotaLimit: &mockGetQuota{}})
+	})
+}
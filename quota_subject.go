@@ -0,0 +1,37 @@
+package andromeda
+
+import (
+	"context"
+	"fmt"
+)
+
+// quotaSubjectKey is the key of the subject usage, it keeps the hash tag of the quota key
+func quotaSubjectKey(key, subject string) string {
+	return fmt.Sprintf("%s-subject-%s", key, subject)
+}
+
+func getQuotaSubject(ctx context.Context, getQuotaSubject GetQuotaSubject, req *QuotaRequest) (string, error) {
+	subject, err := getQuotaSubject.Do(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if subject == "" {
+		return "", fmt.Errorf("%w: empty subject of quota %s", ErrInvalidQuotaSubject, req.QuotaID)
+	}
+	return subject, nil
+}
+
+// NewQuotaSubjectLimitExceededError is a error helper for quota of a subject exceeded
+func NewQuotaSubjectLimitExceededError(key, subject string, limit, usage int64) error {
+	return &QuotaLimitExceededError{Key: key, Subject: subject, Limit: limit, Usage: usage}
+}
+
+// NewAlreadyClaimedError is a error helper for subject that already claimed the quota
+func NewAlreadyClaimedError(key, subject string) error {
+	return fmt.Errorf("%w: subject %s for key %s", ErrAlreadyClaimed, subject, key)
+}
+
+// NewRefundExceedsUsageError is a error helper for refund that exceeds the usage of the subject
+func NewRefundExceedsUsageError(key, subject string, refund, usage int64) error {
+	return fmt.Errorf("%w: refund %d and usage %d of subject %s for key %s", ErrRefundExceedsUsage, refund, usage, subject, key)
+}
@@ -0,0 +1,293 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestQuotaSubject(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+		Cache:                     redisCache,
+		GetQuotaLimit:             &mockGetQuota{value: 3},
+		GetQuotaUsageKey:          &mockGetQuotaKey{keyFormat: "subject-usage-%s"},
+		GetQuotaSubject:           &mockGetQuotaSubject{},
+		GetQuotaSubjectLimit:      &mockGetQuota{value: 2},
+		GetQuotaSubjectExpiration: &mockGetQuotaExp{},
+	})
+	reduceQuotaUsage := andromeda.ReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+		Cache:            redisCache,
+		GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "subject-usage-%s"},
+		GetQuotaSubject:  &mockGetQuotaSubject{},
+	})
+	getCache := func(key string) string {
+		val, _ := miniRedis.Get(key)
+		return val
+	}
+
+	t.Run("SucceedWithinSubjectLimit", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "2", getCache("subject-usage-123"))
+		assert.Equal(t, "2", getCache("subject-usage-123-subject-user-1"))
+		assert.Equal(t, time.Second*30, miniRedis.TTL("subject-usage-123-subject-user-1"))
+	})
+
+	t.Run("ErrorSubjectLimitExceeded", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 1})
+
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaSubjectLimitExceeded))
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+		assert.EqualError(t, err, "quota subject limit exceeded: limit 2 and usage 2 for subject user-1 of key subject-usage-123")
+		assert.Equal(t, "2", getCache("subject-usage-123"))
+	})
+
+	t.Run("ErrorQuotaLimitExceeded", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-2", Usage: 2})
+
+		assert.False(t, errors.Is(err, andromeda.ErrQuotaSubjectLimitExceeded))
+		assert.EqualError(t, err, andromeda.NewQuotaLimitExceededError("subject-usage-123", 3, 2).Error())
+		assert.Equal(t, "", getCache("subject-usage-123-subject-user-2"))
+	})
+
+	t.Run("ReduceSubjectUsage", func(t *testing.T) {
+		_, err := reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 1})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1", getCache("subject-usage-123"))
+		assert.Equal(t, "1", getCache("subject-usage-123-subject-user-1"))
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 1})
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ErrorEmptySubject", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1})
+
+		assert.True(t, errors.Is(err, andromeda.ErrInvalidQuotaSubject))
+	})
+
+	t.Run("SubjectWithoutExpiration", func(t *testing.T) {
+		addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:                     redisCache,
+			GetQuotaLimit:             &mockGetQuota{value: 3},
+			GetQuotaUsageKey:          &mockGetQuotaKey{keyFormat: "subject-usage-%s"},
+			GetQuotaSubject:           &mockGetQuotaSubject{},
+			GetQuotaSubjectLimit:      &mockGetQuota{value: 1},
+			GetQuotaSubjectExpiration: &mockGetQuotaNoExp{},
+		})
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "456", Data: "user-1", Usage: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, "1", getCache("subject-usage-456-subject-user-1"))
+		assert.Equal(t, time.Duration(0), miniRedis.TTL("subject-usage-456-subject-user-1"))
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "456", Data: "user-1", Usage: 1})
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaSubjectLimitExceeded))
+	})
+}
+
+func TestQuotaClaim(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	mockCtrl := gomock.NewController(t)
+	mockNext := mocks.NewMockUpdateQuotaUsage(mockCtrl)
+	addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+		Cache:                   redisCache,
+		GetQuotaLimit:           &mockGetQuota{value: 10},
+		GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "claim-usage-%s"},
+		GetQuotaSubject:         &mockGetQuotaSubject{},
+		GetQuotaClaimKey:        &mockGetQuotaKey{keyFormat: "claim-members-%s"},
+		GetQuotaClaimExpiration: &mockGetQuotaExp{},
+		Next:                    mockNext,
+	})
+	reduceQuotaUsage := andromeda.ReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+		Cache:            redisCache,
+		GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "claim-usage-%s"},
+		GetQuotaSubject:  &mockGetQuotaSubject{},
+		GetQuotaClaimKey: &mockGetQuotaKey{keyFormat: "claim-members-%s"},
+	})
+	getCache := func(key string) string {
+		val, _ := miniRedis.Get(key)
+		return val
+	}
+	isMember := func(subject string) bool {
+		ok, _ := miniRedis.SIsMember("claim-members-123", subject)
+		return ok
+	}
+
+	t.Run("SucceedFirstClaim", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 1}
+		mockNext.EXPECT().Do(ctx, req).Return("result", nil)
+
+		_, err := addQuotaUsage.Do(ctx, req)
+
+		assert.Nil(t, err)
+		assert.True(t, isMember("user-1"))
+		assert.Equal(t, time.Second*30, miniRedis.TTL("claim-members-123"))
+	})
+
+	t.Run("ErrorAlreadyClaimed", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 1})
+
+		assert.True(t, errors.Is(err, andromeda.ErrAlreadyClaimed))
+		assert.EqualError(t, err, andromeda.NewAlreadyClaimedError("claim-usage-123", "user-1").Error())
+		assert.Equal(t, "1", getCache("claim-usage-123"))
+	})
+
+	t.Run("RemoveClaimWhenNextHasError", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-2", Usage: 1}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, errors.New("unexpected"))
+
+		_, err := addQuotaUsage.Do(ctx, req)
+
+		assert.NotNil(t, err)
+		assert.False(t, isMember("user-2"))
+		assert.Equal(t, "1", getCache("claim-usage-123"))
+	})
+
+	t.Run("ReduceRemovesClaim", func(t *testing.T) {
+		_, err := reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 1})
+
+		assert.Nil(t, err)
+		assert.False(t, isMember("user-1"))
+		assert.Equal(t, "0", getCache("claim-usage-123"))
+	})
+
+	t.Run("ClaimWithoutExpiration", func(t *testing.T) {
+		addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:                   redisCache,
+			GetQuotaLimit:           &mockGetQuota{value: 10},
+			GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "claim-usage-%s"},
+			GetQuotaSubject:         &mockGetQuotaSubject{},
+			GetQuotaClaimKey:        &mockGetQuotaKey{keyFormat: "claim-members-%s"},
+			GetQuotaClaimExpiration: &mockGetQuotaNoExp{},
+		})
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "456", Data: "user-1", Usage: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, time.Duration(0), miniRedis.TTL("claim-members-456"))
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "456", Data: "user-1", Usage: 1})
+		assert.True(t, errors.Is(err, andromeda.ErrAlreadyClaimed))
+	})
+}
+
+func TestQuotaOwner(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+		Cache:                   redisCache,
+		GetQuotaLimit:           &mockGetQuota{value: 10},
+		GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "owner-usage-%s"},
+		GetQuotaSubject:         &mockGetQuotaSubject{},
+		GetQuotaOwnerKey:        &mockGetQuotaKey{keyFormat: "owner-%s"},
+		GetQuotaOwnerExpiration: &mockGetQuotaExp{},
+	})
+	reduceQuotaUsage := andromeda.ReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+		Cache:            redisCache,
+		GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "owner-usage-%s"},
+		GetQuotaSubject:  &mockGetQuotaSubject{},
+		GetQuotaOwnerKey: &mockGetQuotaKey{keyFormat: "owner-%s"},
+	})
+	getCache := func(key string) string {
+		val, _ := miniRedis.Get(key)
+		return val
+	}
+
+	t.Run("RecordUsageOfSubject", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 3})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "3", miniRedis.HGet("owner-123", "user-1"))
+		assert.Equal(t, time.Second*30, miniRedis.TTL("owner-123"))
+	})
+
+	t.Run("ErrorRefundExceedsUsage", func(t *testing.T) {
+		_, err := reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-2", Usage: 1})
+
+		assert.True(t, errors.Is(err, andromeda.ErrRefundExceedsUsage))
+		assert.EqualError(t, err, andromeda.NewRefundExceedsUsageError("owner-usage-123", "user-2", 1, 0).Error())
+		assert.Equal(t, "3", getCache("owner-usage-123"))
+	})
+
+	t.Run("PartialRefund", func(t *testing.T) {
+		_, err := reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1", miniRedis.HGet("owner-123", "user-1"))
+		assert.Equal(t, "1", getCache("owner-usage-123"))
+
+		_, err = reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 2})
+
+		assert.True(t, errors.Is(err, andromeda.ErrRefundExceedsUsage))
+
+		_, err = reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 1})
+
+		assert.Nil(t, err)
+		assert.False(t, miniRedis.Exists("owner-123"))
+	})
+
+	t.Run("RefreshExpirationOfLaterSubject", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "456", Data: "user-1", Usage: 1})
+		assert.Nil(t, err)
+
+		miniRedis.FastForward(time.Second * 20)
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "456", Data: "user-2", Usage: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, time.Second*30, miniRedis.TTL("owner-456"))
+
+		miniRedis.FastForward(time.Second * 20)
+		_, err = reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "456", Data: "user-2", Usage: 1})
+		assert.Nil(t, err)
+	})
+
+	t.Run("RestoreExpirationWhenReverseRefund", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		mockNext := mocks.NewMockUpdateQuotaUsage(mockCtrl)
+		reduceQuotaUsage := andromeda.ReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+			Next:             mockNext,
+			Cache:            redisCache,
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "owner-usage-%s"},
+			GetQuotaSubject:  &mockGetQuotaSubject{},
+			GetQuotaOwnerKey: &mockGetQuotaKey{keyFormat: "owner-%s"},
+		})
+		req := &andromeda.QuotaUsageRequest{QuotaID: "789", Data: "user-1", Usage: 1}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, errors.New("unexpected"))
+
+		_, err := addQuotaUsage.Do(ctx, req)
+		assert.Nil(t, err)
+
+		_, err = reduceQuotaUsage.Do(ctx, req)
+		assert.NotNil(t, err)
+		assert.Equal(t, "1", miniRedis.HGet("owner-789", "user-1"))
+		assert.Equal(t, time.Second*30, miniRedis.TTL("owner-789"))
+	})
+}
@@ -0,0 +1,133 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestQuotaThreshold(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	mockListener := mocks.NewMockQuotaThresholdListener(mockCtrl)
+	mockNext := mocks.NewMockUpdateQuotaUsage(mockCtrl)
+	conf := andromeda.AddQuotaUsageConfig{
+		Cache:                redisCache,
+		GetQuotaLimit:        &mockGetQuota{value: 10},
+		GetQuotaUsageKey:     &mockGetQuotaKey{keyFormat: "threshold-usage-%s"},
+		GetQuotaThresholds:   &mockGetQuotaThresholds{values: []int64{50, 80}},
+		GetQuotaThresholdKey: &mockGetQuotaKey{keyFormat: "threshold-usage-%s-crossed"},
+		ThresholdListener:    mockListener,
+		Next:                 mockNext,
+	}
+	addQuotaUsage := andromeda.AddQuotaUsage(conf)
+	reduceQuotaUsage := andromeda.ReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+		Cache:                redisCache,
+		GetQuotaUsageKey:     &mockGetQuotaKey{keyFormat: "threshold-usage-%s"},
+		GetQuotaThresholdKey: &mockGetQuotaKey{keyFormat: "threshold-usage-%s-crossed"},
+		Next:                 mockNext,
+	})
+	assertThreshold := func(t *testing.T, threshold int64) func(context.Context, *andromeda.QuotaThresholdEvent) {
+		return func(_ context.Context, event *andromeda.QuotaThresholdEvent) {
+			assert.Equal(t, threshold, event.Threshold)
+		}
+	}
+
+	t.Run("NotCrossed", func(t *testing.T) {
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 4}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil)
+
+		_, err := addQuotaUsage.Do(ctx, req)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("FireOncePerCrossing", func(t *testing.T) {
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1}
+		event := &andromeda.QuotaThresholdEvent{Request: req, Key: "threshold-usage-123", Threshold: 50, Limit: 10, Usage: 5}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil).Times(2)
+		mockListener.EXPECT().OnThresholdCrossed(ctx, event).Times(1)
+
+		_, err := addQuotaUsage.Do(ctx, req)
+		assert.Nil(t, err)
+
+		_, err = addQuotaUsage.Do(ctx, req)
+		assert.Nil(t, err)
+	})
+
+	t.Run("DeduplicateAcrossInstances", func(t *testing.T) {
+		otherAddQuotaUsage := andromeda.AddQuotaUsage(conf)
+		mockNext.EXPECT().Do(ctx, gomock.Any()).Return(nil, nil).Times(2)
+		mockListener.EXPECT().OnThresholdCrossed(ctx, gomock.Any()).Do(assertThreshold(t, 80)).Times(1)
+
+		var wg sync.WaitGroup
+		for _, updateQuotaUsage := range []andromeda.UpdateQuotaUsage{addQuotaUsage, otherAddQuotaUsage} {
+			wg.Add(1)
+			go func(updateQuotaUsage andromeda.UpdateQuotaUsage) {
+				defer wg.Done()
+				_, err := updateQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1})
+				assert.Nil(t, err)
+			}(updateQuotaUsage)
+		}
+		wg.Wait()
+	})
+
+	t.Run("FireAgainAfterReduceBelowThreshold", func(t *testing.T) {
+		mockNext.EXPECT().Do(ctx, gomock.Any()).Return(nil, nil).Times(2)
+		mockListener.EXPECT().OnThresholdCrossed(ctx, gomock.Any()).Do(assertThreshold(t, 80)).Times(1)
+
+		_, err := reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 2})
+		assert.Nil(t, err)
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 2})
+		assert.Nil(t, err)
+	})
+
+	t.Run("NotFireWhenNextHasError", func(t *testing.T) {
+		req := &andromeda.QuotaUsageRequest{QuotaID: "456", Usage: 8}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, errors.New("error"))
+
+		_, err := addQuotaUsage.Do(ctx, req)
+		assert.NotNil(t, err)
+		assert.False(t, miniRedis.Exists("threshold-usage-456-crossed"))
+	})
+
+	t.Run("NotFireAgainWhenReduceIsReversed", func(t *testing.T) {
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 5}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, errors.New("error"))
+
+		_, err := reduceQuotaUsage.Do(ctx, req)
+		assert.NotNil(t, err)
+
+		val := miniRedis.HGet("threshold-usage-123-crossed", "80")
+		assert.Equal(t, "8", val)
+	})
+
+	t.Run("FireWhenIrreversibleNextHasError", func(t *testing.T) {
+		irreversibleConf := conf
+		irreversibleConf.Option = andromeda.AddUsageOption{Irreversible: true}
+		irreversibleAddQuotaUsage := andromeda.AddQuotaUsage(irreversibleConf)
+		req := &andromeda.QuotaUsageRequest{QuotaID: "789", Usage: 8}
+		event := &andromeda.QuotaThresholdEvent{Request: req, Key: "threshold-usage-789", Threshold: 50, Limit: 10, Usage: 8}
+		mockNext.EXPECT().Do(ctx, req).Return(nil, errors.New("error"))
+		mockListener.EXPECT().OnThresholdCrossed(ctx, event)
+		mockListener.EXPECT().OnThresholdCrossed(ctx, gomock.Any()).Do(assertThreshold(t, 80))
+
+		_, err := irreversibleAddQuotaUsage.Do(ctx, req)
+		assert.NotNil(t, err)
+	})
+}
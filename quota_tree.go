@@ -0,0 +1,355 @@
+package andromeda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// QuotaTreeLevel is a level of the quota tree, every level resolves its key and limit from the request of the child
+type QuotaTreeLevel struct {
+	GetQuotaUsageKey        GetQuotaKey
+	GetQuotaLimit           GetQuota
+	GetQuotaUsage           GetQuota           // optional, loads the usage when it is not in the cache
+	GetQuotaUsageExpiration GetQuotaExpiration // required when GetQuotaUsage is set
+}
+
+// QuotaTreeConfig .
+// Every level is updated by a single script, so the keys of the levels must share the same hash tag
+// on Redis Cluster, otherwise the script fails with CROSSSLOT
+type QuotaTreeConfig struct {
+	Cache               Cache
+	Levels              []QuotaTreeLevel // ordered from the child to the root
+	GetQuotaUsageConfig GetQuotaUsageConfig
+}
+
+// QuotaTree is a contract to charge the usage of a child quota up through every ancestor,
+// the level that is not found for the request is skipped, e.g. a voucher without a campaign
+type QuotaTree interface {
+	AddQuotaUsage(next UpdateQuotaUsage, option AddUsageOption) UpdateQuotaUsage
+	ReduceQuotaUsage(next UpdateQuotaUsage, option ReduceUsageOption) UpdateQuotaUsage
+	GetStatus(ctx context.Context, req *QuotaRequest) ([]*QuotaStatus, error)
+}
+
+const addQuotaTreeUsageScript = usageScriptPrelude + `
+local usage = tonumber(p.usage)
+for i = 1, tonumber(p.levels) do
+	local current = tonumber(redis.call('GET', key('level' .. i)) or '0')
+	local limit = tonumber(p['limit' .. i])
+	if current + usage > limit then
+		return {1, i, current, limit}
+	end
+end
+
+local values = {0}
+for i = 1, tonumber(p.levels) do
+	values[#values + 1] = redis.call('INCRBY', key('level' .. i), p.usage)
+end
+return values
+`
+
+const reduceQuotaTreeUsageScript = usageScriptPrelude + `
+local usage = tonumber(p.usage)
+for i = 1, tonumber(p.levels) do
+	local current = tonumber(redis.call('GET', key('level' .. i)) or '0')
+	if current - usage < 0 then
+		return {4, i, current - usage}
+	end
+end
+
+local values = {0}
+for i = 1, tonumber(p.levels) do
+	values[#values + 1] = redis.call('DECRBY', key('level' .. i), p.usage)
+end
+return values
+`
+
+// reverseQuotaTreeUsageScript increments every level without any check
+const reverseQuotaTreeUsageScript = usageScriptPrelude + `
+local values = {0}
+for i = 1, tonumber(p.levels) do
+	values[#values + 1] = redis.call('INCRBY', key('level' .. i), p.usage)
+end
+return values
+`
+
+type quotaTree struct {
+	cache  Cache
+	levels []QuotaTreeLevel
+	config GetQuotaUsageConfig
+}
+
+func (t *quotaTree) AddQuotaUsage(next UpdateQuotaUsage, option AddUsageOption) UpdateQuotaUsage {
+	if next == nil {
+		next = NopUpdateQuotaUsage()
+	}
+
+	return t.withWarmUp(&addQuotaTreeUsage{tree: t, next: next, option: option})
+}
+
+func (t *quotaTree) ReduceQuotaUsage(next UpdateQuotaUsage, option ReduceUsageOption) UpdateQuotaUsage {
+	if next == nil {
+		next = NopUpdateQuotaUsage()
+	}
+
+	return t.withWarmUp(&reduceQuotaTreeUsage{tree: t, next: next, option: option})
+}
+
+func (t *quotaTree) GetStatus(ctx context.Context, req *QuotaRequest) ([]*QuotaStatus, error) {
+	statuses := make([]*QuotaStatus, 0, len(t.levels))
+	for _, level := range t.levels {
+		key, err := level.GetQuotaUsageKey.Do(ctx, req)
+		if errors.Is(err, ErrQuotaNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		limit, err := level.GetQuotaLimit.Do(ctx, req)
+		if errors.Is(err, ErrQuotaNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var usage int64
+		val, err := t.cache.Get(ctx, key)
+		if errors.Is(err, ErrCacheNotFound) && level.GetQuotaUsage != nil {
+			usage, err = level.GetQuotaUsage.Do(ctx, req)
+		} else if err == nil {
+			usage, err = strconv.ParseInt(val, 10, 64)
+		} else if errors.Is(err, ErrCacheNotFound) {
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, &QuotaStatus{Key: key, Limit: limit, Usage: usage, Remaining: limit - usage})
+	}
+
+	return statuses, nil
+}
+
+// withWarmUp loads the usage of every level before the usage is updated
+func (t *quotaTree) withWarmUp(updateQuotaUsage UpdateQuotaUsage) UpdateQuotaUsage {
+	for i := len(t.levels) - 1; i >= 0; i-- {
+		level := t.levels[i]
+		if level.GetQuotaUsage == nil {
+			continue
+		}
+
+		xSetNXQuotaUsage := NewXSetNXQuota(t.cache, level.GetQuotaUsageKey, level.GetQuotaUsageExpiration, level.GetQuotaUsage, t.config.GetLockIn())
+		xSetNXQuotaUsage = NewRetryableXSetNXQuota(xSetNXQuotaUsage, t.config.GetMaxRetry(), t.config.GetRetryIn())
+		updateQuotaUsage = NewUpdateQuotaUsageMiddleware(NewXSetNXQuotaUsage(xSetNXQuotaUsage), updateQuotaUsage)
+	}
+	return updateQuotaUsage
+}
+
+// treeCall is the levels of the tree that are found for a request
+type treeCall struct {
+	script *usageScript
+	keys   []string
+	levels []int // index of the level of every key
+}
+
+// script adds the key of every level that is found to the script, the limits are added when withLimit is true,
+// ErrQuotaNotFound is returned when no level is found
+func (t *quotaTree) script(ctx context.Context, req *QuotaRequest, usage int64, withLimit bool) (*treeCall, error) {
+	call := &treeCall{script: new(usageScript).withArg("usage", usage)}
+	for i, level := range t.levels {
+		key, err := level.GetQuotaUsageKey.Do(ctx, req)
+		if errors.Is(err, ErrQuotaNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var limit int64
+		if withLimit {
+			if limit, err = level.GetQuotaLimit.Do(ctx, req); errors.Is(err, ErrQuotaNotFound) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		call.keys = append(call.keys, key)
+		call.levels = append(call.levels, i)
+		n := len(call.keys)
+		call.script.withKey(fmt.Sprintf("level%d", n), key)
+		if withLimit {
+			call.script.withArg(fmt.Sprintf("limit%d", n), limit)
+		}
+	}
+
+	if len(call.keys) == 0 {
+		return nil, ErrQuotaNotFound
+	}
+	call.script.withArg("levels", len(call.keys))
+	return call, nil
+}
+
+func (t *quotaTree) reverseUsage(ctx context.Context, keys []string, usage int64) error {
+	script := new(usageScript).withArg("usage", usage).withArg("levels", len(keys))
+	for i, key := range keys {
+		script.withKey(fmt.Sprintf("level%d", i+1), key)
+	}
+
+	if _, _, err := script.run(ctx, t.cache, reverseQuotaTreeUsageScript); err != nil {
+		return fmt.Errorf("%w: %v", ErrReduceQuotaUsage, err)
+	}
+	return nil
+}
+
+type addQuotaTreeUsage struct {
+	tree   *quotaTree
+	next   UpdateQuotaUsage
+	option AddUsageOption
+}
+
+func (q *addQuotaTreeUsage) Do(ctx context.Context, req *QuotaUsageRequest) (res interface{}, err error) {
+	var totalUsage int64
+	var isNextErr bool
+
+	defer func() {
+		if q.option.Listener != nil && !isNextErr {
+			if err == nil {
+				q.option.Listener.OnSuccess(ctx, req, totalUsage)
+			} else {
+				q.option.Listener.OnError(ctx, req, err)
+			}
+		}
+	}()
+
+	usage, err := getUsage(ctx, req, q.option.UsageCost, q.option.ModifiedUsage)
+	if err != nil {
+		return
+	}
+
+	quotaReq := &QuotaRequest{QuotaID: req.QuotaID, Data: req.Data}
+	call, err := q.tree.script(ctx, quotaReq, usage, true)
+	if errors.Is(err, ErrQuotaNotFound) {
+		return q.next.Do(ctx, req)
+	} else if err != nil {
+		return
+	}
+
+	code, values, err := call.script.run(ctx, q.tree.cache, addQuotaTreeUsageScript)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+		return
+	}
+
+	if code == usageScriptLimitExceeded {
+		i := values[0] - 1
+		err = newQuotaLimitExceededError(ctx, q.tree.levels[call.levels[i]].GetQuotaLimit, quotaReq, call.keys[i], values[2], values[1])
+		return
+	}
+
+	totalUsage = values[0]
+
+	res, _err := q.next.Do(ctx, req)
+	if _err != nil {
+		isNextErr = true
+
+		if !q.option.Irreversible {
+			if er := q.tree.reverseUsage(ctx, call.keys, -usage); er != nil {
+				err, _err = er, er
+				isNextErr = false
+			}
+		}
+	}
+
+	return res, _err
+}
+
+type reduceQuotaTreeUsage struct {
+	tree   *quotaTree
+	next   UpdateQuotaUsage
+	option ReduceUsageOption
+}
+
+func (q *reduceQuotaTreeUsage) Do(ctx context.Context, req *QuotaUsageRequest) (res interface{}, err error) {
+	var totalUsage int64
+	var isNextErr bool
+
+	defer func() {
+		if q.option.Listener != nil && !isNextErr {
+			if err == nil {
+				q.option.Listener.OnSuccess(ctx, req, totalUsage)
+			} else {
+				q.option.Listener.OnError(ctx, req, err)
+			}
+		}
+	}()
+
+	usage, err := getUsage(ctx, req, q.option.UsageCost, q.option.ModifiedUsage)
+	if err != nil {
+		return
+	}
+
+	quotaReq := &QuotaRequest{QuotaID: req.QuotaID, Data: req.Data}
+	call, err := q.tree.script(ctx, quotaReq, usage, false)
+	if errors.Is(err, ErrQuotaNotFound) {
+		return q.next.Do(ctx, req)
+	} else if err != nil {
+		return
+	}
+
+	code, values, err := call.script.run(ctx, q.tree.cache, reduceQuotaTreeUsageScript)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrReduceQuotaUsage, err)
+		return
+	}
+
+	if code == usageScriptInvalidMinUsage {
+		err = NewInvalidMinQuotaUsageError(call.keys[values[0]-1], values[1])
+		return
+	}
+
+	totalUsage = values[0]
+
+	res, _err := q.next.Do(ctx, req)
+	if _err != nil {
+		isNextErr = true
+
+		if !q.option.Irreversible {
+			if er := q.tree.reverseUsage(ctx, call.keys, usage); er != nil {
+				err, _err = er, er
+				isNextErr = false
+			}
+		}
+	}
+
+	return res, _err
+}
+
+// NewQuotaTree .
+func NewQuotaTree(conf QuotaTreeConfig) QuotaTree {
+	if conf.Cache == nil {
+		panic("Cache is required")
+	}
+	mustScriptCache(conf.Cache)
+	if len(conf.Levels) == 0 {
+		panic("Levels is required")
+	}
+	for _, level := range conf.Levels {
+		if level.GetQuotaUsageKey == nil {
+			panic("GetQuotaUsageKey is required")
+		}
+		if level.GetQuotaLimit == nil {
+			panic("GetQuotaLimit is required")
+		}
+		if level.GetQuotaUsage != nil && level.GetQuotaUsageExpiration == nil {
+			panic("GetQuotaUsageExpiration is required")
+		}
+	}
+
+	return &quotaTree{
+		cache:  conf.Cache,
+		levels: conf.Levels,
+		config: conf.GetQuotaUsageConfig,
+	}
+}
@@ -0,0 +1,160 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestQuotaTree(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	mockCtrl := gomock.NewController(t)
+	mockNext := mocks.NewMockUpdateQuotaUsage(mockCtrl)
+	getCache := func(key string) string {
+		val, _ := miniRedis.Get(key)
+		return val
+	}
+	quotaTree := andromeda.NewQuotaTree(andromeda.QuotaTreeConfig{
+		Cache: redisCache,
+		Levels: []andromeda.QuotaTreeLevel{
+			{
+				GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "tree-voucher-%s"},
+				GetQuotaLimit:    &mockGetQuota{value: 10},
+			},
+			{
+				GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "tree-campaign%.0s"},
+				GetQuotaLimit:           &mockGetQuota{value: 15},
+				GetQuotaUsage:           &mockGetQuota{value: 2},
+				GetQuotaUsageExpiration: &mockGetQuotaExp{},
+			},
+		},
+	})
+
+	t.Run("ChargeEveryLevel", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		addQuotaUsage := quotaTree.AddQuotaUsage(mockNext, andromeda.AddUsageOption{})
+		req := &andromeda.QuotaUsageRequest{QuotaID: "1", Usage: 8}
+		mockNext.EXPECT().Do(ctx, req).Return("result", nil)
+
+		res, err := addQuotaUsage.Do(ctx, req)
+
+		assert.Equal(t, "result", res)
+		assert.Nil(t, err)
+		assert.Equal(t, "8", getCache("tree-voucher-1"))
+		assert.Equal(t, "10", getCache("tree-campaign"))
+	})
+
+	t.Run("ErrorChildLimitExceeded", func(t *testing.T) {
+		addQuotaUsage := quotaTree.AddQuotaUsage(nil, andromeda.AddUsageOption{})
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "1", Usage: 3})
+
+		assert.EqualError(t, err, andromeda.NewQuotaLimitExceededError("tree-voucher-1", 10, 8).Error())
+		assert.Equal(t, "10", getCache("tree-campaign"))
+	})
+
+	t.Run("ErrorParentLimitExceeded", func(t *testing.T) {
+		addQuotaUsage := quotaTree.AddQuotaUsage(nil, andromeda.AddUsageOption{})
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "2", Usage: 6})
+
+		assert.EqualError(t, err, andromeda.NewQuotaLimitExceededError("tree-campaign", 15, 10).Error())
+		assert.Equal(t, "", getCache("tree-voucher-2"))
+	})
+
+	t.Run("ReverseEveryLevelWhenNextHasError", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		addQuotaUsage := quotaTree.AddQuotaUsage(mockNext, andromeda.AddUsageOption{})
+		req := &andromeda.QuotaUsageRequest{QuotaID: "2", Usage: 5}
+		mockErr := errors.New("unexpected")
+		mockNext.EXPECT().Do(ctx, req).Return(nil, mockErr)
+
+		_, err := addQuotaUsage.Do(ctx, req)
+
+		assert.EqualError(t, err, mockErr.Error())
+		assert.Equal(t, "0", getCache("tree-voucher-2"))
+		assert.Equal(t, "10", getCache("tree-campaign"))
+	})
+
+	t.Run("ReduceEveryLevel", func(t *testing.T) {
+		reduceQuotaUsage := quotaTree.ReduceQuotaUsage(nil, andromeda.ReduceUsageOption{})
+
+		_, err := reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "1", Usage: 3})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "5", getCache("tree-voucher-1"))
+		assert.Equal(t, "7", getCache("tree-campaign"))
+	})
+
+	t.Run("ErrorInvalidMinQuotaUsage", func(t *testing.T) {
+		reduceQuotaUsage := quotaTree.ReduceQuotaUsage(nil, andromeda.ReduceUsageOption{})
+
+		_, err := reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "2", Usage: 1})
+
+		assert.EqualError(t, err, andromeda.NewInvalidMinQuotaUsageError("tree-voucher-2", -1).Error())
+		assert.Equal(t, "7", getCache("tree-campaign"))
+	})
+
+	t.Run("GetStatus", func(t *testing.T) {
+		statuses, err := quotaTree.GetStatus(ctx, &andromeda.QuotaRequest{QuotaID: "1"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []*andromeda.QuotaStatus{
+			{Key: "tree-voucher-1", Limit: 10, Usage: 5, Remaining: 5},
+			{Key: "tree-campaign", Limit: 15, Usage: 7, Remaining: 8},
+		}, statuses)
+	})
+
+	t.Run("SkipMissingLevel", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		mockGetCampaignKey := mocks.NewMockGetQuotaKey(mockCtrl)
+		mockGetCampaignKey.EXPECT().Do(ctx, gomock.Any()).Return("", andromeda.ErrQuotaNotFound).Times(3)
+		quotaTree := andromeda.NewQuotaTree(andromeda.QuotaTreeConfig{
+			Cache: redisCache,
+			Levels: []andromeda.QuotaTreeLevel{
+				{GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "tree-solo-voucher-%s"}, GetQuotaLimit: &mockGetQuota{value: 2}},
+				{GetQuotaUsageKey: mockGetCampaignKey, GetQuotaLimit: &mockGetQuota{value: 15}},
+			},
+		})
+		addQuotaUsage := quotaTree.AddQuotaUsage(nil, andromeda.AddUsageOption{})
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "1", Usage: 2})
+		assert.Nil(t, err)
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "1", Usage: 1})
+		assert.EqualError(t, err, andromeda.NewQuotaLimitExceededError("tree-solo-voucher-1", 2, 2).Error())
+
+		statuses, err := quotaTree.GetStatus(ctx, &andromeda.QuotaRequest{QuotaID: "1"})
+		assert.Nil(t, err)
+		assert.Equal(t, []*andromeda.QuotaStatus{{Key: "tree-solo-voucher-1", Limit: 2, Usage: 2, Remaining: 0}}, statuses)
+	})
+}
+
+func TestNewQuotaTree(t *testing.T) {
+	assert.PanicsWithValue(t, "Cache is required", func() {
+		andromeda.NewQuotaTree(andromeda.QuotaTreeConfig{})
+	})
+	assert.PanicsWithValue(t, "Levels is required", func() {
+		andromeda.NewQuotaTree(andromeda.QuotaTreeConfig{Cache: mocks.NewMockScriptCache(gomock.NewController(t))})
+	})
+	assert.PanicsWithValue(t, "GetQuotaLimit is required", func() {
+		andromeda.NewQuotaTree(andromeda.QuotaTreeConfig{
+			Cache:  mocks.NewMockScriptCache(gomock.NewController(t)),
+			Levels: []andromeda.QuotaTreeLevel{{GetQuotaUsageKey: &mockGetQuotaKey{}}},
+		})
+	})
+}
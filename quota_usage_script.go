@@ -0,0 +1,434 @@
+package andromeda
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// usageScriptPrelude decodes the named arguments of the usage script,
+// every key is referenced by name so each rule can be enabled independently
+const usageScriptPrelude = `
+if redis.replicate_commands then
+	redis.replicate_commands()
+end
+
+local p = {}
+for i = 1, #ARGV, 2 do
+	p[ARGV[i]] = ARGV[i + 1]
+end
+
+local function key(name)
+	local i = p[name .. 'Key']
+	if i then
+		return KEYS[tonumber(i)]
+	end
+	return nil
+end
+
+local function now()
+	local t = redis.call('TIME')
+	return tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+end
+
+-- clearThresholds clears the crossed thresholds above the usage so they can be crossed again
+local function clearThresholds(usage)
+	local cleared = {}
+	if key('threshold') then
+		local levels = redis.call('HGETALL', key('threshold'))
+		for i = 1, #levels, 2 do
+			if usage < tonumber(levels[i + 1]) then
+				redis.call('HDEL', key('threshold'), levels[i])
+				cleared[#cleared + 1] = tonumber(levels[i])
+				cleared[#cleared + 1] = tonumber(levels[i + 1])
+			end
+		end
+	end
+	return cleared
+end
+
+local function state(direction)
+	if key('state') then
+		return redis.call('HGET', key('state'), direction)
+	end
+	return false
+end
+`
+
+const addQuotaUsageScript = usageScriptPrelude + `
+local state = state('add')
+if state == 'paused' then
+	return {3, 0, 0}
+elseif state == 'closed' then
+	return {5, 0, 0}
+end
+
+if p.startAt or p.endAt then
+	local now = now()
+	if p.startAt and now < tonumber(p.startAt) then
+		return {6, now, 0}
+	elseif p.endAt and now >= tonumber(p.endAt) then
+		return {7, now, 0}
+	end
+end
+
+local usage = tonumber(p.usage)
+local limit = tonumber(p.limit)
+if key('limit') then
+	limit = tonumber(redis.call('GET', key('limit')) or '')
+	if not limit then
+		return {2, 0, 0}
+	end
+end
+
+-- accepts the usage above the limit up to the overdraft
+local overdraft = tonumber(p.overdraft or '0')
+local current = tonumber(redis.call('GET', key('usage')) or '0')
+local subjectCurrent = 0
+if key('subject') then
+	subjectCurrent = tonumber(redis.call('GET', key('subject')) or '0')
+end
+
+-- the usage that is held for the subject by the waitlist is freed for its usage, the expired hold is released by the waitlist
+local held = 0
+if key('hold') then
+	local expireAt = redis.call('ZSCORE', key('holdExpiry'), p.subject)
+	if expireAt and tonumber(expireAt) > now() then
+		held = tonumber(redis.call('HGET', key('hold'), p.subject) or '0')
+		current = current - held
+	end
+end
+
+-- the class keeps the unused share of the other classes, the class without borrowing is capped by its share
+local classUsage = 0
+local classRemaining = limit + overdraft - current
+if key('class') then
+	local classCap
+	for i = 1, tonumber(p.classes) do
+		local reserved = math.floor(limit * tonumber(p['classShare' .. i]) / 100)
+		local used = tonumber(redis.call('HGET', key('class'), p['class' .. i]) or '0')
+		if p['class' .. i] == p.className then
+			classUsage = used
+			if not p.classBorrow then
+				classCap = reserved - used
+			end
+		else
+			classRemaining = classRemaining - math.max(reserved - used, 0)
+		end
+	end
+	if classCap then
+		classRemaining = math.min(classRemaining, classCap)
+	end
+end
+
+-- grants the remaining usage down to the minimum usage, the checks below reject the usage under the minimum
+if p.minUsage then
+	usage = math.min(usage, limit + overdraft - current)
+	if key('subject') then
+		usage = math.min(usage, tonumber(p.subjectLimit) - subjectCurrent)
+	end
+	if key('class') then
+		usage = math.min(usage, classRemaining)
+	end
+	usage = math.max(usage, tonumber(p.minUsage))
+end
+
+if current + usage > limit + overdraft then
+	return {1, current, limit, overdraft}
+end
+
+if key('class') and usage > classRemaining then
+	return {11, classUsage, classUsage + classRemaining}
+end
+
+if key('claim') and redis.call('SISMEMBER', key('claim'), p.subject) == 1 then
+	return {9, 0, 0}
+end
+
+if key('subject') and subjectCurrent + usage > tonumber(p.subjectLimit) then
+	return {8, subjectCurrent, tonumber(p.subjectLimit)}
+end
+
+local overdraftUsage = math.max(current + usage - limit, 0) - math.max(current - limit, 0)
+if p.dryRun then
+	return {0, current + usage, limit, usage, overdraftUsage, overdraft}
+end
+
+if held > 0 then
+	redis.call('HDEL', key('hold'), p.subject)
+	redis.call('ZREM', key('holdExpiry'), p.subject)
+	redis.call('DECRBY', key('usage'), held)
+end
+
+if key('subject') then
+	redis.call('INCRBY', key('subject'), usage)
+	if tonumber(p.subjectExpiration) > 0 and redis.call('PTTL', key('subject')) < 0 then
+		redis.call('PEXPIRE', key('subject'), p.subjectExpiration)
+	end
+end
+
+if key('claim') then
+	redis.call('SADD', key('claim'), p.subject)
+	if tonumber(p.claimExpiration) > 0 and redis.call('PTTL', key('claim')) < 0 then
+		redis.call('PEXPIRE', key('claim'), p.claimExpiration)
+	end
+end
+
+-- the records of every subject live for the expiration after the last write
+if key('owner') then
+	redis.call('HINCRBY', key('owner'), p.subject, usage)
+	local ownerExpiration = tonumber(p.ownerExpiration)
+	if ownerExpiration > 0 and redis.call('PTTL', key('owner')) < ownerExpiration then
+		redis.call('PEXPIRE', key('owner'), ownerExpiration)
+	end
+end
+
+if key('class') then
+	redis.call('HINCRBY', key('class'), p.className, usage)
+	local ttl = redis.call('PTTL', key('usage'))
+	if ttl > 0 and redis.call('PTTL', key('class')) < 0 then
+		redis.call('PEXPIRE', key('class'), ttl)
+	end
+end
+
+local total = redis.call('INCRBY', key('usage'), usage)
+local values = {0, total, limit, usage, overdraftUsage}
+if key('overdraft') and overdraftUsage > 0 then
+	redis.call('INCRBY', key('overdraft'), overdraftUsage)
+	local ttl = redis.call('PTTL', key('usage'))
+	if ttl > 0 and redis.call('PTTL', key('overdraft')) < 0 then
+		redis.call('PEXPIRE', key('overdraft'), ttl)
+	end
+end
+
+if key('threshold') then
+	for i = 1, tonumber(p.thresholds) do
+		local threshold = tonumber(p['threshold' .. i])
+		local level = math.ceil(limit * threshold / 100)
+		if total >= level and redis.call('HSETNX', key('threshold'), threshold, level) == 1 then
+			values[#values + 1] = threshold
+		end
+	end
+
+	local ttl = redis.call('PTTL', key('usage'))
+	if ttl > 0 and redis.call('PTTL', key('threshold')) < 0 then
+		redis.call('PEXPIRE', key('threshold'), ttl)
+	end
+end
+
+return values
+`
+
+// reverseAddQuotaUsageScript reverses the usage of the add script when the next update quota usage has an error
+const reverseAddQuotaUsageScript = usageScriptPrelude + `
+-- the partial usage reverses the granted usage instead of the requested usage
+local usage = tonumber(p.grantedUsage or p.usage)
+if key('subject') then
+	redis.call('DECRBY', key('subject'), usage)
+end
+if key('claim') then
+	redis.call('SREM', key('claim'), p.subject)
+end
+if key('owner') and redis.call('HINCRBY', key('owner'), p.subject, -usage) <= 0 then
+	redis.call('HDEL', key('owner'), p.subject)
+end
+if key('class') then
+	redis.call('HINCRBY', key('class'), p.className, -usage)
+end
+if key('overdraft') and tonumber(p.overdraftUsage or '0') > 0 then
+	redis.call('DECRBY', key('overdraft'), p.overdraftUsage)
+end
+
+local total = redis.call('DECRBY', key('usage'), usage)
+clearThresholds(total)
+
+return {0, total}
+`
+
+const reduceQuotaUsageScript = usageScriptPrelude + `
+local state = state('reduce')
+if state == 'paused' then
+	return {3, 0}
+elseif state == 'closed' then
+	return {5, 0}
+end
+
+if key('owner') then
+	local owned = tonumber(redis.call('HGET', key('owner'), p.subject) or '0')
+	if owned < tonumber(p.usage) then
+		return {10, owned}
+	end
+end
+
+local current = tonumber(redis.call('GET', key('usage')) or '0')
+if current - tonumber(p.usage) < 0 then
+	return {4, current - tonumber(p.usage)}
+end
+
+if p.dryRun then
+	return {0, current - tonumber(p.usage), 0, 0, 0, 0, 0}
+end
+
+-- the reverse restores the expiration when the last record is removed together with the hash
+local ownerTTL = 0
+if key('owner') then
+	ownerTTL = redis.call('PTTL', key('owner'))
+	if redis.call('HINCRBY', key('owner'), p.subject, -tonumber(p.usage)) == 0 then
+		redis.call('HDEL', key('owner'), p.subject)
+	end
+end
+
+local subjectUsage = 0
+if key('subject') then
+	subjectUsage = math.min(tonumber(redis.call('GET', key('subject')) or '0'), tonumber(p.usage))
+	if subjectUsage > 0 then
+		redis.call('DECRBY', key('subject'), subjectUsage)
+	end
+end
+
+local claimed = 0
+if key('claim') then
+	claimed = redis.call('SREM', key('claim'), p.subject)
+end
+
+local classUsage = 0
+if key('class') then
+	classUsage = math.min(tonumber(redis.call('HGET', key('class'), p.className) or '0'), tonumber(p.usage))
+	if classUsage > 0 then
+		redis.call('HINCRBY', key('class'), p.className, -classUsage)
+	end
+end
+
+-- the refund comes off the usage above the limit first
+local overdraftUsage = 0
+if key('overdraft') then
+	overdraftUsage = math.min(tonumber(redis.call('GET', key('overdraft')) or '0'), tonumber(p.usage))
+	if overdraftUsage > 0 then
+		redis.call('DECRBY', key('overdraft'), overdraftUsage)
+	end
+end
+
+local total = redis.call('DECRBY', key('usage'), p.usage)
+local values = {0, total, subjectUsage, claimed, classUsage, ownerTTL, overdraftUsage}
+for _, value in ipairs(clearThresholds(total)) do
+	values[#values + 1] = value
+end
+
+return values
+`
+
+// reverseReduceQuotaUsageScript reverses the usage of the reduce script when the next update quota usage has an error
+const reverseReduceQuotaUsageScript = usageScriptPrelude + `
+if key('subject') and tonumber(p.subjectUsage) > 0 then
+	redis.call('INCRBY', key('subject'), p.subjectUsage)
+end
+if key('claim') and tonumber(p.claimed) > 0 then
+	redis.call('SADD', key('claim'), p.subject)
+end
+if key('owner') then
+	redis.call('HINCRBY', key('owner'), p.subject, p.usage)
+	if tonumber(p.ownerTTL) > 0 and redis.call('PTTL', key('owner')) < 0 then
+		redis.call('PEXPIRE', key('owner'), p.ownerTTL)
+	end
+end
+if key('class') and tonumber(p.classUsage) > 0 then
+	redis.call('HINCRBY', key('class'), p.className, p.classUsage)
+end
+if key('overdraft') and tonumber(p.overdraftUsage) > 0 then
+	redis.call('INCRBY', key('overdraft'), p.overdraftUsage)
+end
+if key('threshold') then
+	for i = 1, tonumber(p.cleared or '0') do
+		redis.call('HSETNX', key('threshold'), p['clearedThreshold' .. i], p['clearedLevel' .. i])
+	end
+end
+
+return {0, redis.call('INCRBY', key('usage'), p.usage)}
+`
+
+const (
+	usageScriptOK = iota
+	usageScriptLimitExceeded
+	usageScriptNotFound
+	usageScriptPaused
+	usageScriptInvalidMinUsage
+	usageScriptClosed
+	usageScriptNotOpen
+	usageScriptEnded
+	usageScriptSubjectLimitExceeded
+	usageScriptAlreadyClaimed
+	usageScriptRefundExceedsUsage
+	usageScriptClassLimitExceeded
+)
+
+type usageScript struct {
+	keys     []string
+	args     []interface{}
+	readOnly bool
+}
+
+func newUsageScript(usageKey string, usage int64) *usageScript {
+	return new(usageScript).withKey("usage", usageKey).withArg("usage", usage)
+}
+
+func (s *usageScript) withKey(name, key string) *usageScript {
+	s.keys = append(s.keys, key)
+	return s.withArg(name+"Key", len(s.keys))
+}
+
+func (s *usageScript) withArg(name string, value interface{}) *usageScript {
+	s.args = append(s.args, name, value)
+	return s
+}
+
+// withReadOnly marks the script that does not change its keys, e.g. the dry run
+func (s *usageScript) withReadOnly() *usageScript {
+	s.readOnly = true
+	return s
+}
+
+// run executes the script and returns the result code followed by the values of the script
+func (s *usageScript) run(ctx context.Context, cache Cache, script string) (int64, []int64, error) {
+	items, err := s.eval(ctx, cache, script)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	values := make([]int64, len(items))
+	for i, item := range items {
+		if values[i], err = scriptInt(item); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return values[0], values[1:], nil
+}
+
+// eval executes the script and returns the items of the script result
+func (s *usageScript) eval(ctx context.Context, cache Cache, script string) ([]interface{}, error) {
+	eval := evalScript
+	if s.readOnly {
+		eval = evalReadOnlyScript
+	}
+
+	res, err := eval(ctx, cache, script, s.keys, s.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := res.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("unexpected script result %v", res)
+	}
+	return items, nil
+}
+
+func scriptInt(item interface{}) (int64, error) {
+	switch val := item.(type) {
+	case int64:
+		return val, nil
+	case string:
+		return strconv.ParseInt(val, 10, 64)
+	}
+	return 0, fmt.Errorf("unexpected script value %v", item)
+}
@@ -0,0 +1,345 @@
+package andromeda
+
+import (
+	"context"
+	"fmt"
+)
+
+// QuotaWaitlistConfig .
+type QuotaWaitlistConfig struct {
+	Cache                  Cache
+	GetQuotaLimit          GetQuota
+	GetQuotaUsageKey       GetQuotaKey
+	GetQuotaWaitlistKey    GetQuotaKey // keeps the waiting subjects in a sorted set by the join order and the holds of the offered subjects
+	GetQuotaSubject        GetQuotaSubject
+	GetQuotaHoldExpiration GetQuotaExpiration // how long the freed usage is held for the offered subject
+	UsageCost              UsageCost          // optional, computes the usage of the subject that joins the same as the add quota usage
+	Listener               QuotaWaitlistListener
+}
+
+// QuotaWaitlist is a contract to queue the rejected requests and offer the freed usage to them in order
+type QuotaWaitlist interface {
+	// Join queues the subject of the request with its usage and returns the position, zero when it is offered right away
+	Join(ctx context.Context, req *QuotaUsageRequest) (int64, error)
+	// Leave removes the subject from the waitlist and releases its hold
+	Leave(ctx context.Context, req *QuotaRequest) error
+	// Position returns the position of the subject in the waitlist, zero when it is not waiting
+	Position(ctx context.Context, req *QuotaRequest) (int64, error)
+	// AddQuotaUsage adds the usage by the add quota usage of the config that consumes the hold of the subject,
+	// so the claim of the hold goes through every rule of the config
+	AddQuotaUsage(conf AddQuotaUsageConfig) UpdateQuotaUsage
+	// ReduceQuotaUsage offers the freed usage to the waitlist after the next, it is the next of the reduce quota usage
+	ReduceQuotaUsage(next UpdateQuotaUsage) UpdateQuotaUsage
+}
+
+// quotaWaitlistPrelude holds the usage for the subjects at the head of the waitlist while the limit allows,
+// the expired holds are released first, the offers are returned as subject, usage and expiration triples
+const quotaWaitlistPrelude = usageScriptPrelude + `
+local function offer()
+	local now = now()
+	for _, subject in ipairs(redis.call('ZRANGEBYSCORE', key('holdExpiry'), '-inf', now)) do
+		local held = tonumber(redis.call('HGET', key('hold'), subject) or '0')
+		redis.call('HDEL', key('hold'), subject)
+		redis.call('ZREM', key('holdExpiry'), subject)
+		if held > 0 then
+			redis.call('DECRBY', key('usage'), held)
+		end
+	end
+
+	local offers = {}
+	local current = tonumber(redis.call('GET', key('usage')) or '0')
+	while true do
+		local subject = redis.call('ZRANGE', key('waitlist'), 0, 0)[1]
+		if not subject then
+			break
+		end
+
+		local usage = tonumber(redis.call('HGET', key('waiting'), subject) or '0')
+		if current + usage > tonumber(p.limit) then
+			break
+		end
+
+		local expireAt = now + tonumber(p.holdExpiration)
+		current = redis.call('INCRBY', key('usage'), usage)
+		redis.call('ZREM', key('waitlist'), subject)
+		redis.call('HDEL', key('waiting'), subject)
+		redis.call('HSET', key('hold'), subject, usage)
+		redis.call('ZADD', key('holdExpiry'), expireAt, subject)
+		offers[#offers + 1] = subject
+		offers[#offers + 1] = usage
+		offers[#offers + 1] = expireAt
+	end
+	return offers
+end
+
+local function position(subject)
+	local rank = redis.call('ZRANK', key('waitlist'), subject)
+	if not rank then
+		return 0
+	end
+	return rank + 1
+end
+
+local function result(value, offers)
+	local values = {0, value}
+	for _, item in ipairs(offers) do
+		values[#values + 1] = item
+	end
+	return values
+end
+`
+
+const joinQuotaWaitlistScript = quotaWaitlistPrelude + `
+if redis.call('HEXISTS', key('hold'), p.subject) == 0 and redis.call('HSETNX', key('waiting'), p.subject, p.usage) == 1 then
+	redis.call('ZADD', key('waitlist'), redis.call('INCR', key('sequence')), p.subject)
+end
+
+local offers = offer()
+return result(position(p.subject), offers)
+`
+
+const leaveQuotaWaitlistScript = quotaWaitlistPrelude + `
+redis.call('ZREM', key('waitlist'), p.subject)
+redis.call('HDEL', key('waiting'), p.subject)
+
+local held = tonumber(redis.call('HGET', key('hold'), p.subject) or '0')
+if held > 0 then
+	redis.call('HDEL', key('hold'), p.subject)
+	redis.call('ZREM', key('holdExpiry'), p.subject)
+	redis.call('DECRBY', key('usage'), held)
+end
+
+return result(0, offer())
+`
+
+const offerQuotaWaitlistScript = quotaWaitlistPrelude + `
+return result(0, offer())
+`
+
+const positionQuotaWaitlistScript = quotaWaitlistPrelude + `
+return {0, position(p.subject)}
+`
+
+type quotaWaitlist struct {
+	cache                  Cache
+	getQuotaLimit          GetQuota
+	getQuotaUsageKey       GetQuotaKey
+	getQuotaWaitlistKey    GetQuotaKey
+	getQuotaSubject        GetQuotaSubject
+	getQuotaHoldExpiration GetQuotaExpiration
+	usageCost              UsageCost
+	listener               QuotaWaitlistListener
+}
+
+func (w *quotaWaitlist) Join(ctx context.Context, req *QuotaUsageRequest) (int64, error) {
+	usage, err := getUsage(ctx, req, w.usageCost, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return w.run(ctx, &QuotaRequest{QuotaID: req.QuotaID, Data: req.Data}, usage, joinQuotaWaitlistScript)
+}
+
+func (w *quotaWaitlist) Leave(ctx context.Context, req *QuotaRequest) error {
+	_, err := w.run(ctx, req, 0, leaveQuotaWaitlistScript)
+	return err
+}
+
+func (w *quotaWaitlist) Position(ctx context.Context, req *QuotaRequest) (int64, error) {
+	script, _, err := w.script(ctx, req, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	_, values, err := script.withReadOnly().run(ctx, w.cache, positionQuotaWaitlistScript)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+	}
+	return values[0], nil
+}
+
+func (w *quotaWaitlist) AddQuotaUsage(conf AddQuotaUsageConfig) UpdateQuotaUsage {
+	conf.GetQuotaWaitlistKey = w.getQuotaWaitlistKey
+	if conf.GetQuotaSubject == nil {
+		conf.GetQuotaSubject = w.getQuotaSubject
+	}
+	if conf.Option.UsageCost == nil {
+		conf.Option.UsageCost = w.usageCost
+	}
+
+	return &claimQuotaWaitlist{waitlist: w, addQuotaUsage: AddQuotaUsage(conf)}
+}
+
+func (w *quotaWaitlist) ReduceQuotaUsage(next UpdateQuotaUsage) UpdateQuotaUsage {
+	if next == nil {
+		next = NopUpdateQuotaUsage()
+	}
+
+	return &offerQuotaWaitlist{waitlist: w, next: next}
+}
+
+// script resolves the keys of the waitlist and the subject of the request
+func (w *quotaWaitlist) script(ctx context.Context, req *QuotaRequest, usage int64) (*usageScript, string, error) {
+	key, err := w.getQuotaUsageKey.Do(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	limit, err := w.getQuotaLimit.Do(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	waitlistKey, err := w.getQuotaWaitlistKey.Do(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	holdExp, err := w.getQuotaHoldExpiration.Do(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	subject, err := getQuotaSubject(ctx, w.getQuotaSubject, req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	script := newUsageScript(key, usage).
+		withKey("waitlist", waitlistKey).
+		withKey("sequence", fmt.Sprintf("%s-sequence", waitlistKey)).
+		withKey("waiting", fmt.Sprintf("%s-waiting", waitlistKey))
+	withQuotaHoldKeys(script, waitlistKey).
+		withArg("limit", limit).
+		withArg("subject", subject).
+		withArg("holdExpiration", holdExp.Milliseconds())
+	return script, key, nil
+}
+
+// run executes the waitlist script, notifies the offers and returns the value of the script
+func (w *quotaWaitlist) run(ctx context.Context, req *QuotaRequest, usage int64, waitlistScript string) (int64, error) {
+	script, key, err := w.script(ctx, req, usage)
+	if err != nil {
+		return 0, err
+	}
+
+	items, err := script.eval(ctx, w.cache, waitlistScript)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+	}
+
+	value, err := scriptInt(items[1])
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 2; i+2 < len(items); i += 3 {
+		offer := &QuotaOffer{QuotaID: req.QuotaID, Key: key, Subject: fmt.Sprint(items[i])}
+		if offer.Usage, err = scriptInt(items[i+1]); err != nil {
+			return 0, err
+		}
+		expireAt, err := scriptInt(items[i+2])
+		if err != nil {
+			return 0, err
+		}
+		offer.ExpiresAt = fromUnixMilli(expireAt)
+		w.listener.OnOffered(ctx, offer)
+	}
+
+	return value, nil
+}
+
+// withQuotaHoldKeys adds the keys of the holds of the waitlist to the script
+func withQuotaHoldKeys(script *usageScript, waitlistKey string) *usageScript {
+	return script.
+		withKey("hold", fmt.Sprintf("%s-hold", waitlistKey)).
+		withKey("holdExpiry", fmt.Sprintf("%s-hold-expiry", waitlistKey))
+}
+
+type claimQuotaWaitlist struct {
+	waitlist      *quotaWaitlist
+	addQuotaUsage UpdateQuotaUsage
+}
+
+func (q *claimQuotaWaitlist) Do(ctx context.Context, req *QuotaUsageRequest) (interface{}, error) {
+	// the expired holds are released and offered to the waitlist before the usage consumes the hold of its subject
+	if _, err := q.waitlist.run(ctx, &QuotaRequest{QuotaID: req.QuotaID, Data: req.Data}, 0, offerQuotaWaitlistScript); err != nil {
+		return nil, err
+	}
+
+	return q.addQuotaUsage.Do(ctx, req)
+}
+
+type offerQuotaWaitlist struct {
+	waitlist *quotaWaitlist
+	next     UpdateQuotaUsage
+}
+
+func (q *offerQuotaWaitlist) Do(ctx context.Context, req *QuotaUsageRequest) (interface{}, error) {
+	res, err := q.next.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// the usage is already freed, so the offer error is reported instead of reversing it
+	if _, err = q.waitlist.run(ctx, &QuotaRequest{QuotaID: req.QuotaID, Data: req.Data}, 0, offerQuotaWaitlistScript); err != nil {
+		if listener, ok := q.waitlist.listener.(QuotaWaitlistErrorListener); ok {
+			listener.OnOfferError(ctx, req, err)
+		}
+	}
+	return res, nil
+}
+
+type quotaOfferChannel struct {
+	offers chan *QuotaOffer
+}
+
+func (l *quotaOfferChannel) OnOffered(ctx context.Context, offer *QuotaOffer) {
+	select {
+	case l.offers <- offer:
+	case <-ctx.Done():
+	}
+}
+
+// NewQuotaOfferChannel is a waitlist listener that sends the offers to the channel
+func NewQuotaOfferChannel(size int) (QuotaWaitlistListener, <-chan *QuotaOffer) {
+	offers := make(chan *QuotaOffer, size)
+	return &quotaOfferChannel{offers: offers}, offers
+}
+
+// NewQuotaWaitlist .
+func NewQuotaWaitlist(conf QuotaWaitlistConfig) QuotaWaitlist {
+	if conf.Cache == nil {
+		panic("Cache is required")
+	}
+	mustScriptCache(conf.Cache)
+	if conf.GetQuotaLimit == nil {
+		panic("GetQuotaLimit is required")
+	}
+	if conf.GetQuotaUsageKey == nil {
+		panic("GetQuotaUsageKey is required")
+	}
+	if conf.GetQuotaWaitlistKey == nil {
+		panic("GetQuotaWaitlistKey is required")
+	}
+	if conf.GetQuotaSubject == nil {
+		panic("GetQuotaSubject is required")
+	}
+	if conf.GetQuotaHoldExpiration == nil {
+		panic("GetQuotaHoldExpiration is required")
+	}
+	if conf.Listener == nil {
+		panic("Listener is required")
+	}
+
+	return &quotaWaitlist{
+		cache:                  conf.Cache,
+		getQuotaLimit:          conf.GetQuotaLimit,
+		getQuotaUsageKey:       conf.GetQuotaUsageKey,
+		getQuotaWaitlistKey:    conf.GetQuotaWaitlistKey,
+		getQuotaSubject:        conf.GetQuotaSubject,
+		getQuotaHoldExpiration: conf.GetQuotaHoldExpiration,
+		usageCost:              conf.UsageCost,
+		listener:               conf.Listener,
+	}
+}
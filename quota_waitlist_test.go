@@ -0,0 +1,185 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type mockWaitlistListener struct {
+	*mocks.MockQuotaWaitlistListener
+	*mocks.MockQuotaWaitlistErrorListener
+}
+
+func TestQuotaWaitlist(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	listener, offers := andromeda.NewQuotaOfferChannel(10)
+	waitlist := andromeda.NewQuotaWaitlist(andromeda.QuotaWaitlistConfig{
+		Cache:                  redisCache,
+		GetQuotaLimit:          &mockGetQuota{value: 2},
+		GetQuotaUsageKey:       &mockGetQuotaKey{keyFormat: "waitlist-usage-%s"},
+		GetQuotaWaitlistKey:    &mockGetQuotaKey{keyFormat: "waitlist-usage-%s-waitlist"},
+		GetQuotaSubject:        &mockGetQuotaSubject{},
+		GetQuotaHoldExpiration: &mockGetQuotaExp{},
+		Listener:               listener,
+	})
+	addQuotaUsage := waitlist.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+		Cache:                   redisCache,
+		GetQuotaLimit:           &mockGetQuota{value: 2},
+		GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "waitlist-usage-%s"},
+		GetQuotaClaimKey:        &mockGetQuotaKey{keyFormat: "waitlist-usage-%s-claim"},
+		GetQuotaClaimExpiration: &mockGetQuotaExp{},
+	})
+	reduceQuotaUsage := andromeda.ReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+		Cache:            redisCache,
+		GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "waitlist-usage-%s"},
+		Next:             waitlist.ReduceQuotaUsage(nil),
+	})
+	getCache := func(key string) string {
+		val, _ := miniRedis.Get(key)
+		return val
+	}
+
+	t.Run("JoinWhenLimitExceeded", func(t *testing.T) {
+		for _, subject := range []string{"user-1", "user-2", "user-3"} {
+			_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: subject, Usage: 1})
+		}
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+
+		position, err := waitlist.Join(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-3", Usage: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), position)
+
+		position, err = waitlist.Join(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-4", Usage: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), position)
+
+		position, err = waitlist.Join(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-3", Usage: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), position)
+	})
+
+	t.Run("OfferFreedUsageToHead", func(t *testing.T) {
+		_, err := reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 1})
+		assert.Nil(t, err)
+
+		offer := <-offers
+		assert.Equal(t, "user-3", offer.Subject)
+		assert.Equal(t, int64(1), offer.Usage)
+		assert.Equal(t, "waitlist-usage-123", offer.Key)
+		assert.Equal(t, "2", getCache("waitlist-usage-123"))
+
+		position, err := waitlist.Position(ctx, &andromeda.QuotaRequest{QuotaID: "123", Data: "user-4"})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), position)
+	})
+
+	t.Run("ClaimHold", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-5", Usage: 1})
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-3", Usage: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, "2", getCache("waitlist-usage-123"))
+		claimed, err := miniRedis.IsMember("waitlist-usage-123-claim", "user-3")
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+	})
+
+	t.Run("OfferNextWhenHoldExpires", func(t *testing.T) {
+		_, err := reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-2", Usage: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, "user-4", (<-offers).Subject)
+
+		miniRedis.SetTime(time.Now().Add(time.Minute))
+
+		position, err := waitlist.Join(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-5", Usage: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), position)
+		assert.Equal(t, "user-5", (<-offers).Subject)
+		assert.Equal(t, "2", getCache("waitlist-usage-123"))
+	})
+
+	t.Run("LeaveReleasesHold", func(t *testing.T) {
+		err := waitlist.Leave(ctx, &andromeda.QuotaRequest{QuotaID: "123", Data: "user-5"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1", getCache("waitlist-usage-123"))
+	})
+
+	t.Run("HoldUsageCost", func(t *testing.T) {
+		usageCost := andromeda.UsageCostFunc(func(_ context.Context, req *andromeda.QuotaUsageRequest) (int64, error) {
+			return req.Usage * 2, nil
+		})
+		costWaitlist := andromeda.NewQuotaWaitlist(andromeda.QuotaWaitlistConfig{
+			Cache:                  redisCache,
+			GetQuotaLimit:          &mockGetQuota{value: 2},
+			GetQuotaUsageKey:       &mockGetQuotaKey{keyFormat: "waitlist-cost-%s"},
+			GetQuotaWaitlistKey:    &mockGetQuotaKey{keyFormat: "waitlist-cost-%s-waitlist"},
+			GetQuotaSubject:        &mockGetQuotaSubject{},
+			GetQuotaHoldExpiration: &mockGetQuotaExp{},
+			UsageCost:              usageCost,
+			Listener:               listener,
+		})
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-1", Usage: 1}
+
+		position, err := costWaitlist.Join(ctx, req)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), position)
+		assert.Equal(t, int64(2), (<-offers).Usage)
+
+		_, err = costWaitlist.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 2},
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "waitlist-cost-%s"},
+		}).Do(ctx, req)
+		assert.Nil(t, err)
+		assert.Equal(t, "2", getCache("waitlist-cost-123"))
+		assert.False(t, miniRedis.Exists("waitlist-cost-123-waitlist-hold"))
+	})
+
+	t.Run("ReportOfferErrorWithoutReverse", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		mockGetQuotaLimit := mocks.NewMockGetQuota(mockCtrl)
+		mockListener := &mockWaitlistListener{
+			MockQuotaWaitlistListener:      mocks.NewMockQuotaWaitlistListener(mockCtrl),
+			MockQuotaWaitlistErrorListener: mocks.NewMockQuotaWaitlistErrorListener(mockCtrl),
+		}
+		failedWaitlist := andromeda.NewQuotaWaitlist(andromeda.QuotaWaitlistConfig{
+			Cache:                  redisCache,
+			GetQuotaLimit:          mockGetQuotaLimit,
+			GetQuotaUsageKey:       &mockGetQuotaKey{keyFormat: "waitlist-usage-%s"},
+			GetQuotaWaitlistKey:    &mockGetQuotaKey{keyFormat: "waitlist-usage-%s-waitlist"},
+			GetQuotaSubject:        &mockGetQuotaSubject{},
+			GetQuotaHoldExpiration: &mockGetQuotaExp{},
+			Listener:               mockListener,
+		})
+		reduceQuotaUsage := andromeda.ReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "waitlist-usage-%s"},
+			Next:             failedWaitlist.ReduceQuotaUsage(nil),
+		})
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Data: "user-4", Usage: 1}
+		mockGetQuotaLimit.EXPECT().Do(ctx, gomock.Any()).Return(int64(0), errors.New("error"))
+		mockListener.MockQuotaWaitlistErrorListener.EXPECT().OnOfferError(ctx, req, gomock.Any())
+
+		_, err := reduceQuotaUsage.Do(ctx, req)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "0", getCache("waitlist-usage-123"))
+	})
+}
@@ -8,8 +8,9 @@ import (
 
 // ReduceUsageOption .
 type ReduceUsageOption struct {
-	ModifiedUsage int64
-	Irreversible  bool // does not reverse when the next update quota usage has an error
+	ModifiedUsage int64     // Deprecated: use UsageCost
+	UsageCost     UsageCost // computes the usage of every request, overrides ModifiedUsage
+	Irreversible  bool      // does not reverse when the next update quota usage has an error
 	Listener      UpdateQuotaUsageListener
 }
 
@@ -41,9 +42,9 @@ func (q *reduceQuotaUsage) Do(ctx context.Context, req *QuotaUsageRequest) (res
 		return
 	}
 
-	usage := req.Usage
-	if q.option.ModifiedUsage > 0 {
-		usage = q.option.ModifiedUsage
+	usage, err := getUsage(ctx, req, q.option.UsageCost, q.option.ModifiedUsage)
+	if err != nil {
+		return
 	}
 
 	totalUsage, err = q.cache.DecrBy(ctx, key, usage)
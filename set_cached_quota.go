@@ -0,0 +1,33 @@
+package andromeda
+
+import "context"
+
+type setCachedQuota struct {
+	cache              Cache
+	getQuotaKey        GetQuotaKey
+	getQuotaExpiration GetQuotaExpiration
+}
+
+func (q *setCachedQuota) Do(ctx context.Context, req *QuotaRequest, value int64) error {
+	key, err := q.getQuotaKey.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	exp, err := q.getQuotaExpiration.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.cache.Set(ctx, key, value, exp)
+	return err
+}
+
+// NewSetCachedQuota .
+func NewSetCachedQuota(cache Cache, getQuotaKey GetQuotaKey, getQuotaExpiration GetQuotaExpiration) SetQuota {
+	return &setCachedQuota{
+		cache:              cache,
+		getQuotaKey:        getQuotaKey,
+		getQuotaExpiration: getQuotaExpiration,
+	}
+}
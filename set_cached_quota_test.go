@@ -0,0 +1,82 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSetCachedQuota(t *testing.T) {
+	ctx := context.TODO()
+	mockCtrl := gomock.NewController(t)
+	mockCache := mocks.NewMockCache(mockCtrl)
+	mockGetQuotaKey := mocks.NewMockGetQuotaKey(mockCtrl)
+	mockGetQuotaExp := mocks.NewMockGetQuotaExpiration(mockCtrl)
+	setCachedQuota := andromeda.NewSetCachedQuota(mockCache, mockGetQuotaKey, mockGetQuotaExp)
+
+	t.Run("ErrorGetQuotaKey", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "123"}
+		mockErr := errors.New("unexpected")
+
+		mockGetQuotaKey.EXPECT().Do(ctx, req).Return("", mockErr)
+
+		err := setCachedQuota.Do(ctx, req, 1000)
+
+		assert.EqualError(t, err, mockErr.Error())
+	})
+
+	t.Run("ErrorGetQuotaExpiration", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "123"}
+		key := "123-key"
+		mockErr := errors.New("unexpected")
+
+		mockGetQuotaKey.EXPECT().Do(ctx, req).Return(key, nil)
+		mockGetQuotaExp.EXPECT().Do(ctx, req).Return(time.Duration(0), mockErr)
+
+		err := setCachedQuota.Do(ctx, req, 1000)
+
+		assert.EqualError(t, err, mockErr.Error())
+	})
+
+	t.Run("ErrorSetCache", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "123"}
+		key := "123-key"
+		exp := time.Hour * 1
+		mockErr := errors.New("unexpected")
+
+		mockGetQuotaKey.EXPECT().Do(ctx, req).Return(key, nil)
+		mockGetQuotaExp.EXPECT().Do(ctx, req).Return(exp, nil)
+		mockCache.EXPECT().Set(ctx, key, int64(1000), exp).Return("", mockErr)
+
+		err := setCachedQuota.Do(ctx, req, 1000)
+
+		assert.EqualError(t, err, mockErr.Error())
+	})
+
+	t.Run("SucceedSetQuota", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		req := &andromeda.QuotaRequest{QuotaID: "123"}
+		key := "123-key"
+		exp := time.Hour * 1
+
+		mockGetQuotaKey.EXPECT().Do(ctx, req).Return(key, nil)
+		mockGetQuotaExp.EXPECT().Do(ctx, req).Return(exp, nil)
+		mockCache.EXPECT().Set(ctx, key, int64(1000), exp).Return("OK", nil)
+
+		err := setCachedQuota.Do(ctx, req, 1000)
+
+		assert.Nil(t, err)
+	})
+}
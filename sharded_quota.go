@@ -0,0 +1,475 @@
+package andromeda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ShardedQuotaConfig .
+type ShardedQuotaConfig struct {
+	Cache                   Cache
+	Shards                  int // number of the sub-keys of the usage, every shard gets a slice of the limit
+	GetQuotaLimit           GetQuota
+	GetQuotaUsageKey        GetQuotaKey
+	GetQuotaUsageExpiration GetQuotaExpiration
+}
+
+// ShardedQuota is a contract to spread the usage of a hot quota over the shards on the different slots of the cache cluster
+type ShardedQuota interface {
+	AddQuotaUsage(next UpdateQuotaUsage, option AddUsageOption) UpdateQuotaUsage
+	ReduceQuotaUsage(next UpdateQuotaUsage, option ReduceUsageOption) UpdateQuotaUsage
+	// Cache gets the usage key as the sum of the shards, e.g. for NewGetCachedQuota
+	Cache() Cache
+}
+
+// shardPrelude sets the slice of the limit of the shard when it is used for the first time in the window of the expiration.
+// The windows are counted from the epoch so every shard starts over at the same time, and the limit of the shard
+// moves with the change of its slice so the limit that is moved between the shards is kept when the quota limit changes
+const shardPrelude = usageScriptPrelude + `
+local function shardLimit()
+	local expiration = tonumber(p.expiration)
+	local window = 0
+	if expiration > 0 then
+		window = math.floor(now() / expiration)
+	end
+
+	local state = redis.call('HMGET', key('limit'), 'window', 'slice', 'limit')
+	local limit = tonumber(state[3] or '')
+	if not limit or tonumber(state[1]) ~= window then
+		if limit then
+			redis.call('DEL', key('usage'))
+		end
+		limit = tonumber(p.limit)
+		redis.call('HSET', key('limit'), 'window', window, 'slice', limit, 'limit', limit)
+		if expiration > 0 then
+			redis.call('SET', key('usage'), redis.call('GET', key('usage')) or '0')
+			redis.call('PEXPIREAT', key('limit'), (window + 1) * expiration)
+			redis.call('PEXPIREAT', key('usage'), (window + 1) * expiration)
+		end
+	elseif tonumber(state[2]) ~= tonumber(p.limit) then
+		limit = limit + tonumber(p.limit) - tonumber(state[2])
+		redis.call('HSET', key('limit'), 'slice', p.limit, 'limit', limit)
+	end
+	return limit
+end
+`
+
+const addShardUsageScript = shardPrelude + `
+local limit = shardLimit()
+local current = tonumber(redis.call('GET', key('usage')) or '0')
+if current + tonumber(p.usage) > limit then
+	return {1, current, limit}
+end
+return {0, redis.call('INCRBY', key('usage'), p.usage), limit}
+`
+
+// takeShardLimitScript takes the unused limit of the shard up to the usage for the shard that runs dry
+const takeShardLimitScript = shardPrelude + `
+local limit = shardLimit()
+local current = tonumber(redis.call('GET', key('usage')) or '0')
+local taken = math.max(math.min(limit - current, tonumber(p.usage)), 0)
+if taken > 0 then
+	redis.call('HINCRBY', key('limit'), 'limit', -taken)
+end
+return {0, taken}
+`
+
+const giveShardLimitScript = shardPrelude + `
+shardLimit()
+return {0, redis.call('HINCRBY', key('limit'), 'limit', p.usage)}
+`
+
+// reduceShardUsageScript reduces the usage of the shard down to zero
+const reduceShardUsageScript = usageScriptPrelude + `
+local reduced = math.min(tonumber(redis.call('GET', key('usage')) or '0'), tonumber(p.usage))
+if reduced > 0 then
+	redis.call('DECRBY', key('usage'), reduced)
+end
+return {0, reduced}
+`
+
+const reverseShardUsageScript = usageScriptPrelude + `
+return {0, redis.call('INCRBY', key('usage'), p.usage)}
+`
+
+type shardedQuota struct {
+	cache                   Cache
+	shards                  int
+	getQuotaLimit           GetQuota
+	getQuotaUsageKey        GetQuotaKey
+	getQuotaUsageExpiration GetQuotaExpiration
+	next                    uint32
+}
+
+func (s *shardedQuota) AddQuotaUsage(next UpdateQuotaUsage, option AddUsageOption) UpdateQuotaUsage {
+	if next == nil {
+		next = NopUpdateQuotaUsage()
+	}
+
+	return &addShardedQuotaUsage{quota: s, next: next, option: option}
+}
+
+func (s *shardedQuota) ReduceQuotaUsage(next UpdateQuotaUsage, option ReduceUsageOption) UpdateQuotaUsage {
+	if next == nil {
+		next = NopUpdateQuotaUsage()
+	}
+
+	return &reduceShardedQuotaUsage{quota: s, next: next, option: option}
+}
+
+func (s *shardedQuota) Cache() Cache {
+	return &shardedCache{Cache: s.cache, shards: s.shards}
+}
+
+// shardKey keeps the shards on the different slots by the hash tag of the shard, the limit of the shard is on the same slot.
+// The cluster hashes only the first hash tag of a key, so the shard goes into the hash tag that the key already has,
+// e.g. ns:{voucher-1}:usage is ns:{voucher-1-shard-0}:usage and voucher-1-usage is voucher-1-usage-{shard-0}
+func shardKey(key string, shard int) string {
+	if open := strings.Index(key, "{"); open >= 0 {
+		if end := strings.Index(key[open+1:], "}"); end > 0 {
+			end += open + 1
+			return fmt.Sprintf("%s-shard-%d%s", key[:end], shard, key[end:])
+		}
+	}
+	return fmt.Sprintf("%s-{shard-%d}", key, shard)
+}
+
+// shardCall is the shard scripts of a request
+type shardCall struct {
+	key        string
+	limit      int64
+	expiration time.Duration
+}
+
+func (s *shardedQuota) prepare(ctx context.Context, req *QuotaRequest, withLimit bool) (*shardCall, error) {
+	key, err := s.getQuotaUsageKey.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	call := &shardCall{key: key}
+	if !withLimit {
+		return call, nil
+	}
+
+	if call.limit, err = s.getQuotaLimit.Do(ctx, req); err != nil {
+		return nil, err
+	}
+
+	if call.expiration, err = s.getQuotaUsageExpiration.Do(ctx, req); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// script builds the script of the shard with its slice of the limit, the first shards get the remainder
+func (s *shardedQuota) script(call *shardCall, shard int, usage int64) *usageScript {
+	slice := call.limit / int64(s.shards)
+	if int64(shard) < call.limit%int64(s.shards) {
+		slice++
+	}
+
+	key := shardKey(call.key, shard)
+	return newUsageScript(key, usage).
+		withKey("limit", fmt.Sprintf("%s-limit", key)).
+		withArg("limit", slice).
+		withArg("expiration", call.expiration.Milliseconds())
+}
+
+// add picks the next shard and tries the others when it runs dry, then it moves the unused limit of the others to the shard
+func (s *shardedQuota) add(ctx context.Context, call *shardCall, usage int64) (int, error) {
+	start := int(atomic.AddUint32(&s.next, 1)-1) % s.shards
+	for i := 0; i < s.shards; i++ {
+		shard := (start + i) % s.shards
+		code, _, err := s.script(call, shard, usage).run(ctx, s.cache, addShardUsageScript)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+		} else if code == usageScriptOK {
+			return shard, nil
+		}
+	}
+
+	// the limit that is taken from every shard is given back to it when it cannot be moved to the shard
+	takes := make([]int64, s.shards)
+	var taken int64
+	for i := 1; i < s.shards && taken < usage; i++ {
+		shard := (start + i) % s.shards
+		_, values, err := s.script(call, shard, usage-taken).run(ctx, s.cache, takeShardLimitScript)
+		if err != nil {
+			s.giveBack(ctx, call, takes)
+			return 0, fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+		}
+		takes[shard] = values[0]
+		taken += values[0]
+	}
+
+	if taken > 0 {
+		if _, _, err := s.script(call, start, taken).run(ctx, s.cache, giveShardLimitScript); err != nil {
+			s.giveBack(ctx, call, takes)
+			return 0, fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+		}
+	}
+
+	code, values, err := s.script(call, start, usage).run(ctx, s.cache, addShardUsageScript)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrAddQuotaUsage, err)
+	} else if code == usageScriptLimitExceeded {
+		total, err := s.usage(ctx, call.key)
+		if err != nil {
+			total = values[0]
+		}
+		return 0, NewQuotaLimitExceededError(call.key, call.limit, total)
+	}
+
+	return start, nil
+}
+
+// giveBack gives the taken limit back to every shard, the error of the add is returned instead of its error
+func (s *shardedQuota) giveBack(ctx context.Context, call *shardCall, takes []int64) {
+	for shard, taken := range takes {
+		if taken > 0 {
+			_, _, _ = s.script(call, shard, taken).run(ctx, s.cache, giveShardLimitScript)
+		}
+	}
+}
+
+// reduce reduces the usage from the shards in order, the reduced usage of every shard is returned for the reverse
+func (s *shardedQuota) reduce(ctx context.Context, call *shardCall, usage int64) ([]int64, error) {
+	reduced := make([]int64, s.shards)
+	remaining := usage
+	for shard := 0; shard < s.shards && remaining > 0; shard++ {
+		_, values, err := newUsageScript(shardKey(call.key, shard), remaining).run(ctx, s.cache, reduceShardUsageScript)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrReduceQuotaUsage, err)
+		}
+		reduced[shard] = values[0]
+		remaining -= values[0]
+	}
+
+	if remaining > 0 {
+		if err := s.reverse(ctx, call, reduced); err != nil {
+			return nil, err
+		}
+		return nil, NewInvalidMinQuotaUsageError(call.key, -remaining)
+	}
+
+	return reduced, nil
+}
+
+// reverse gives the usage back to every shard
+func (s *shardedQuota) reverse(ctx context.Context, call *shardCall, usages []int64) error {
+	for shard, usage := range usages {
+		if usage == 0 {
+			continue
+		}
+		if _, _, err := newUsageScript(shardKey(call.key, shard), usage).run(ctx, s.cache, reverseShardUsageScript); err != nil {
+			return fmt.Errorf("%w: %v", ErrReduceQuotaUsage, err)
+		}
+	}
+	return nil
+}
+
+// usage sums the usage of the shards, ErrCacheNotFound is returned when none of them is found
+func (s *shardedQuota) usage(ctx context.Context, key string) (int64, error) {
+	return sumShards(ctx, s.cache, key, s.shards)
+}
+
+func sumShards(ctx context.Context, cache Cache, key string, shards int) (int64, error) {
+	var total int64
+	found := false
+	for shard := 0; shard < shards; shard++ {
+		val, err := cache.Get(ctx, shardKey(key, shard))
+		if errors.Is(err, ErrCacheNotFound) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		usage, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		total += usage
+		found = true
+	}
+
+	if !found {
+		return 0, ErrCacheNotFound
+	}
+	return total, nil
+}
+
+type addShardedQuotaUsage struct {
+	quota  *shardedQuota
+	next   UpdateQuotaUsage
+	option AddUsageOption
+}
+
+func (q *addShardedQuotaUsage) Do(ctx context.Context, req *QuotaUsageRequest) (res interface{}, err error) {
+	var totalUsage int64
+	var isNextErr bool
+
+	defer func() {
+		if q.option.Listener != nil && !isNextErr {
+			if err == nil {
+				q.option.Listener.OnSuccess(ctx, req, totalUsage)
+			} else {
+				q.option.Listener.OnError(ctx, req, err)
+			}
+		}
+	}()
+
+	usage, err := getUsage(ctx, req, q.option.UsageCost, q.option.ModifiedUsage)
+	if err != nil {
+		return
+	}
+
+	call, err := q.quota.prepare(ctx, &QuotaRequest{QuotaID: req.QuotaID, Data: req.Data}, true)
+	if errors.Is(err, ErrQuotaNotFound) {
+		return q.next.Do(ctx, req)
+	} else if err != nil {
+		return
+	}
+
+	shard, err := q.quota.add(ctx, call, usage)
+	if err != nil {
+		return
+	}
+
+	if q.option.Listener != nil {
+		if totalUsage, err = q.quota.usage(ctx, call.key); err != nil {
+			return
+		}
+	}
+
+	res, _err := q.next.Do(ctx, req)
+	if _err != nil {
+		isNextErr = true
+
+		if !q.option.Irreversible {
+			usages := make([]int64, q.quota.shards)
+			usages[shard] = -usage
+			if er := q.quota.reverse(ctx, call, usages); er != nil {
+				err, _err = er, er
+				isNextErr = false
+			}
+		}
+	}
+
+	return res, _err
+}
+
+type reduceShardedQuotaUsage struct {
+	quota  *shardedQuota
+	next   UpdateQuotaUsage
+	option ReduceUsageOption
+}
+
+func (q *reduceShardedQuotaUsage) Do(ctx context.Context, req *QuotaUsageRequest) (res interface{}, err error) {
+	var totalUsage int64
+	var isNextErr bool
+
+	defer func() {
+		if q.option.Listener != nil && !isNextErr {
+			if err == nil {
+				q.option.Listener.OnSuccess(ctx, req, totalUsage)
+			} else {
+				q.option.Listener.OnError(ctx, req, err)
+			}
+		}
+	}()
+
+	usage, err := getUsage(ctx, req, q.option.UsageCost, q.option.ModifiedUsage)
+	if err != nil {
+		return
+	}
+
+	call, err := q.quota.prepare(ctx, &QuotaRequest{QuotaID: req.QuotaID, Data: req.Data}, false)
+	if errors.Is(err, ErrQuotaNotFound) {
+		return q.next.Do(ctx, req)
+	} else if err != nil {
+		return
+	}
+
+	reduced, err := q.quota.reduce(ctx, call, usage)
+	if err != nil {
+		return
+	}
+
+	if q.option.Listener != nil {
+		if totalUsage, err = q.quota.usage(ctx, call.key); err != nil {
+			return
+		}
+	}
+
+	res, _err := q.next.Do(ctx, req)
+	if _err != nil {
+		isNextErr = true
+
+		if !q.option.Irreversible {
+			if er := q.quota.reverse(ctx, call, reduced); er != nil {
+				err, _err = er, er
+				isNextErr = false
+			}
+		}
+	}
+
+	return res, _err
+}
+
+// shardedCache gets the usage key as the sum of its shards and passes the other calls to the cache
+type shardedCache struct {
+	Cache
+	shards int
+}
+
+func (c *shardedCache) Get(ctx context.Context, key string) (string, error) {
+	total, err := sumShards(ctx, c.Cache, key, c.shards)
+	if errors.Is(err, ErrCacheNotFound) {
+		return c.Cache.Get(ctx, key)
+	} else if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(total, 10), nil
+}
+
+func (c *shardedCache) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return evalScript(ctx, c.Cache, script, keys, args...)
+}
+
+func (c *shardedCache) EvalReadOnly(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return evalReadOnlyScript(ctx, c.Cache, script, keys, args...)
+}
+
+// NewShardedQuota .
+func NewShardedQuota(conf ShardedQuotaConfig) ShardedQuota {
+	if conf.Cache == nil {
+		panic("Cache is required")
+	}
+	mustScriptCache(conf.Cache)
+	if conf.Shards <= 0 {
+		panic("Shards is required")
+	}
+	if conf.GetQuotaLimit == nil {
+		panic("GetQuotaLimit is required")
+	}
+	if conf.GetQuotaUsageKey == nil {
+		panic("GetQuotaUsageKey is required")
+	}
+	if conf.GetQuotaUsageExpiration == nil {
+		panic("GetQuotaUsageExpiration is required")
+	}
+
+	return &shardedQuota{
+		cache:                   conf.Cache,
+		shards:                  conf.Shards,
+		getQuotaLimit:           conf.GetQuotaLimit,
+		getQuotaUsageKey:        conf.GetQuotaUsageKey,
+		getQuotaUsageExpiration: conf.GetQuotaUsageExpiration,
+	}
+}
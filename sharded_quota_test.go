@@ -0,0 +1,255 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// failEvalCache fails the eval of the given call
+type failEvalCache struct {
+	andromeda.ScriptCache
+	evals  int
+	failAt int
+}
+
+func (c *failEvalCache) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	if c.evals++; c.evals == c.failAt {
+		return nil, errors.New("error")
+	}
+	return c.ScriptCache.Eval(ctx, script, keys, args...)
+}
+
+func TestShardedQuota(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	mockNext := mocks.NewMockUpdateQuotaUsage(mockCtrl)
+	sharded := andromeda.NewShardedQuota(andromeda.ShardedQuotaConfig{
+		Cache:                   redisCache,
+		Shards:                  3,
+		GetQuotaLimit:           &mockGetQuota{value: 10},
+		GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "viral-voucher-%s"},
+		GetQuotaUsageExpiration: &mockGetQuotaExp{},
+	})
+	addQuotaUsage := sharded.AddQuotaUsage(mockNext, andromeda.AddUsageOption{})
+	reduceQuotaUsage := sharded.ReduceQuotaUsage(mockNext, andromeda.ReduceUsageOption{})
+	getCachedQuota := andromeda.NewGetCachedQuota(sharded.Cache(), &mockGetQuotaKey{keyFormat: "viral-voucher-%s"})
+	req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1}
+	quotaReq := &andromeda.QuotaRequest{QuotaID: "123"}
+
+	t.Run("SpreadOverShards", func(t *testing.T) {
+		mockNext.EXPECT().Do(ctx, req).Return(nil, nil).Times(3)
+
+		for i := 0; i < 3; i++ {
+			_, err := addQuotaUsage.Do(ctx, req)
+			assert.Nil(t, err)
+		}
+
+		for i := 0; i < 3; i++ {
+			usage, err := miniRedis.Get(fmt.Sprintf("viral-voucher-123-{shard-%d}", i))
+			assert.Nil(t, err)
+			assert.Equal(t, "1", usage)
+		}
+
+		usage, err := getCachedQuota.Do(ctx, quotaReq)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(3), usage)
+	})
+
+	t.Run("RebalanceWhenShardRunsDry", func(t *testing.T) {
+		bigReq := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 6}
+		mockNext.EXPECT().Do(ctx, bigReq).Return(nil, nil)
+
+		_, err := addQuotaUsage.Do(ctx, bigReq)
+		assert.Nil(t, err)
+
+		usage, err := getCachedQuota.Do(ctx, quotaReq)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(9), usage)
+	})
+
+	t.Run("ErrorLimitExceeded", func(t *testing.T) {
+		bigReq := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 2}
+
+		_, err := addQuotaUsage.Do(ctx, bigReq)
+		assert.EqualError(t, err, andromeda.NewQuotaLimitExceededError("viral-voucher-123", 10, 9).Error())
+
+		usage, err := getCachedQuota.Do(ctx, quotaReq)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(9), usage)
+	})
+
+	t.Run("ReverseWhenNextHasError", func(t *testing.T) {
+		mockNext.EXPECT().Do(ctx, req).Return(nil, errors.New("error"))
+
+		_, err := addQuotaUsage.Do(ctx, req)
+		assert.EqualError(t, err, "error")
+
+		usage, err := getCachedQuota.Do(ctx, quotaReq)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(9), usage)
+	})
+
+	t.Run("ReduceAcrossShards", func(t *testing.T) {
+		bigReq := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 8}
+		mockNext.EXPECT().Do(ctx, bigReq).Return(nil, nil)
+
+		_, err := reduceQuotaUsage.Do(ctx, bigReq)
+		assert.Nil(t, err)
+
+		usage, err := getCachedQuota.Do(ctx, quotaReq)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), usage)
+	})
+
+	t.Run("ErrorReduceBelowZero", func(t *testing.T) {
+		bigReq := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 3}
+
+		_, err := reduceQuotaUsage.Do(ctx, bigReq)
+		assert.EqualError(t, err, andromeda.NewInvalidMinQuotaUsageError("viral-voucher-123", -2).Error())
+
+		usage, err := getCachedQuota.Do(ctx, quotaReq)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), usage)
+	})
+
+	t.Run("FallThroughWithoutShards", func(t *testing.T) {
+		assert.Nil(t, miniRedis.Set("viral-voucher-456", "5"))
+
+		usage, err := getCachedQuota.Do(ctx, &andromeda.QuotaRequest{QuotaID: "456"})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(5), usage)
+
+		_, err = getCachedQuota.Do(ctx, &andromeda.QuotaRequest{QuotaID: "789"})
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaNotFound))
+	})
+
+	t.Run("ShardInsideHashTag", func(t *testing.T) {
+		taggedQuotaUsage := andromeda.NewShardedQuota(andromeda.ShardedQuotaConfig{
+			Cache:                   redisCache,
+			Shards:                  3,
+			GetQuotaLimit:           &mockGetQuota{value: 10},
+			GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "ns:{voucher-%s}:usage"},
+			GetQuotaUsageExpiration: &mockGetQuotaExp{},
+		}).AddQuotaUsage(nil, andromeda.AddUsageOption{})
+
+		_, err := taggedQuotaUsage.Do(ctx, req)
+		assert.Nil(t, err)
+
+		usage, err := miniRedis.Get("ns:{voucher-123-shard-0}:usage")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", usage)
+		assert.True(t, miniRedis.Exists("ns:{voucher-123-shard-0}:usage-limit"))
+	})
+
+	t.Run("GiveLimitBackWhenMoveFails", func(t *testing.T) {
+		failCache := &failEvalCache{ScriptCache: redisCache, failAt: 6}
+		movedQuotaUsage := andromeda.NewShardedQuota(andromeda.ShardedQuotaConfig{
+			Cache:                   failCache,
+			Shards:                  2,
+			GetQuotaLimit:           &mockGetQuota{value: 4},
+			GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "moved-voucher-%s"},
+			GetQuotaUsageExpiration: &mockGetQuotaExp{},
+		}).AddQuotaUsage(nil, andromeda.AddUsageOption{})
+
+		_, err := movedQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 2})
+		assert.Nil(t, err)
+
+		_, err = movedQuotaUsage.Do(ctx, req)
+		assert.Nil(t, err)
+
+		// the shard runs dry and the limit that is taken from the other shard fails to be moved to it
+		_, err = movedQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 2})
+		assert.True(t, errors.Is(err, andromeda.ErrAddQuotaUsage))
+
+		limit := miniRedis.HGet("moved-voucher-123-{shard-1}-limit", "limit")
+		assert.Equal(t, "2", limit)
+	})
+
+	t.Run("ShardsWithoutExpiration", func(t *testing.T) {
+		persistentQuotaUsage := andromeda.NewShardedQuota(andromeda.ShardedQuotaConfig{
+			Cache:                   redisCache,
+			Shards:                  2,
+			GetQuotaLimit:           &mockGetQuota{value: 4},
+			GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "persistent-voucher-%s"},
+			GetQuotaUsageExpiration: &mockGetQuotaNoExp{},
+		}).AddQuotaUsage(nil, andromeda.AddUsageOption{})
+
+		_, err := persistentQuotaUsage.Do(ctx, req)
+		assert.Nil(t, err)
+
+		usage, err := miniRedis.Get("persistent-voucher-123-{shard-0}")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", usage)
+		assert.Equal(t, time.Duration(0), miniRedis.TTL("persistent-voucher-123-{shard-0}-limit"))
+	})
+
+	t.Run("ShardsExpireTogether", func(t *testing.T) {
+		miniRedis.SetTime(time.Now())
+		windowQuotaUsage := andromeda.NewShardedQuota(andromeda.ShardedQuotaConfig{
+			Cache:                   redisCache,
+			Shards:                  2,
+			GetQuotaLimit:           &mockGetQuota{value: 4},
+			GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "window-voucher-%s"},
+			GetQuotaUsageExpiration: &mockGetQuotaExp{},
+		}).AddQuotaUsage(nil, andromeda.AddUsageOption{})
+
+		for i := 0; i < 2; i++ {
+			_, err := windowQuotaUsage.Do(ctx, req)
+			assert.Nil(t, err)
+		}
+
+		ttl := miniRedis.TTL("window-voucher-123-{shard-0}")
+		assert.True(t, ttl > 0)
+		for _, key := range []string{"window-voucher-123-{shard-0}-limit", "window-voucher-123-{shard-1}", "window-voucher-123-{shard-1}-limit"} {
+			assert.Equal(t, ttl, miniRedis.TTL(key), key)
+		}
+	})
+
+	t.Run("SlicesFollowLimit", func(t *testing.T) {
+		getQuotaLimit := &mockGetQuota{value: 4}
+		changedQuota := andromeda.NewShardedQuota(andromeda.ShardedQuotaConfig{
+			Cache:                   redisCache,
+			Shards:                  2,
+			GetQuotaLimit:           getQuotaLimit,
+			GetQuotaUsageKey:        &mockGetQuotaKey{keyFormat: "changed-voucher-%s"},
+			GetQuotaUsageExpiration: &mockGetQuotaExp{},
+		})
+		changedQuotaUsage := changedQuota.AddQuotaUsage(nil, andromeda.AddUsageOption{})
+
+		_, err := changedQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 3})
+		assert.Nil(t, err)
+
+		// the limit is lowered after the shard took the limit of the other shard
+		getQuotaLimit.value = 2
+		_, err = changedQuotaUsage.Do(ctx, req)
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+
+		getQuotaLimit.value = 6
+		for i := 0; i < 3; i++ {
+			_, err = changedQuotaUsage.Do(ctx, req)
+			assert.Nil(t, err)
+		}
+		_, err = changedQuotaUsage.Do(ctx, req)
+		assert.True(t, errors.Is(err, andromeda.ErrQuotaLimitExceeded))
+
+		usage, err := andromeda.NewGetCachedQuota(changedQuota.Cache(), &mockGetQuotaKey{keyFormat: "changed-voucher-%s"}).Do(ctx, &andromeda.QuotaRequest{QuotaID: "123"})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(6), usage)
+	})
+}
@@ -0,0 +1,50 @@
+package andromeda
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// maxUsageCost is the max usage that is compared exactly by the usage scripts
+const maxUsageCost = 1<<53 - 1
+
+// UsageCostFunc is an adapter to use a function as UsageCost
+type UsageCostFunc func(ctx context.Context, req *QuotaUsageRequest) (int64, error)
+
+// Do .
+func (f UsageCostFunc) Do(ctx context.Context, req *QuotaUsageRequest) (int64, error) {
+	return f(ctx, req)
+}
+
+// MultiplyUsageCost multiplies the quantity by the weight and returns ErrInvalidUsageCost when it overflows
+func MultiplyUsageCost(quantity, weight int64) (int64, error) {
+	if quantity < 0 || weight < 0 || (weight != 0 && quantity > math.MaxInt64/weight) {
+		return 0, fmt.Errorf("%w: %d multiplied by %d", ErrInvalidUsageCost, quantity, weight)
+	}
+	return quantity * weight, nil
+}
+
+// getUsage returns the cost of the usage when the usage cost is set, otherwise the modified usage or the usage of the request
+func getUsage(ctx context.Context, req *QuotaUsageRequest, usageCost UsageCost, modifiedUsage int64) (int64, error) {
+	if usageCost == nil {
+		if modifiedUsage > 0 {
+			return modifiedUsage, nil
+		}
+		return req.Usage, nil
+	}
+
+	cost, err := usageCost.Do(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	if cost <= 0 || cost > maxUsageCost {
+		return 0, NewInvalidUsageCostError(req.QuotaID, cost)
+	}
+	return cost, nil
+}
+
+// NewInvalidUsageCostError is a error helper for invalid usage cost
+func NewInvalidUsageCostError(quotaID string, cost int64) error {
+	return fmt.Errorf("%w: cost %d for quota %s", ErrInvalidUsageCost, cost, quotaID)
+}
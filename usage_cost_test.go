@@ -0,0 +1,110 @@
+package andromeda_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestMultiplyUsageCost(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity int64
+		weight   int64
+		cost     int64
+		err      error
+	}{
+		{name: "Succeed", quantity: 3, weight: 1500, cost: 4500},
+		{name: "ErrorOverflow", quantity: math.MaxInt64 / 2, weight: 3, err: andromeda.ErrInvalidUsageCost},
+		{name: "ErrorNegative", quantity: -1, weight: 3, err: andromeda.ErrInvalidUsageCost},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cost, err := andromeda.MultiplyUsageCost(test.quantity, test.weight)
+
+			assert.Equal(t, test.cost, cost)
+			assert.True(t, errors.Is(err, test.err))
+		})
+	}
+}
+
+func TestUsageCost(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	mockCtrl := gomock.NewController(t)
+	mockUsageCost := mocks.NewMockUsageCost(mockCtrl)
+	getCache := func(key string) string {
+		val, _ := miniRedis.Get(key)
+		return val
+	}
+
+	t.Run("AddWithUsageCost", func(t *testing.T) {
+		defer mockCtrl.Finish()
+
+		addQuotaUsage := andromeda.AddQuotaUsage(andromeda.AddQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaLimit:    &mockGetQuota{value: 10},
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "cost-usage-%s"},
+			GetQuotaStateKey: &mockGetQuotaKey{keyFormat: "cost-state-%s"},
+			Option:           andromeda.AddUsageOption{ModifiedUsage: 1, UsageCost: mockUsageCost},
+		})
+		req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1, Data: []string{"a", "b", "c"}}
+		mockUsageCost.EXPECT().Do(ctx, req).Return(int64(3), nil)
+
+		_, err := addQuotaUsage.Do(ctx, req)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "3", getCache("cost-usage-123"))
+	})
+
+	t.Run("ReduceWithUsageCostFunc", func(t *testing.T) {
+		reduceQuotaUsage := andromeda.ReduceQuotaUsage(andromeda.ReduceQuotaUsageConfig{
+			Cache:            redisCache,
+			GetQuotaUsageKey: &mockGetQuotaKey{keyFormat: "cost-usage-%s"},
+			Option: andromeda.ReduceUsageOption{UsageCost: andromeda.UsageCostFunc(func(_ context.Context, req *andromeda.QuotaUsageRequest) (int64, error) {
+				return int64(len(req.Data.([]string))), nil
+			})},
+		})
+
+		_, err := reduceQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1, Data: []string{"a", "b"}})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1", getCache("cost-usage-123"))
+	})
+
+	invalidCosts := []int64{0, -1, math.MaxInt64}
+	for _, cost := range invalidCosts {
+		t.Run("ErrorInvalidUsageCost", func(t *testing.T) {
+			mockCache := mocks.NewMockCache(mockCtrl)
+			mockGetQuotaLimit := mocks.NewMockGetQuota(mockCtrl)
+
+			defer mockCtrl.Finish()
+
+			addQuotaUsage := andromeda.NewAddQuotaUsage(
+				mockCache,
+				&mockGetQuotaKey{keyFormat: "cost-usage-%s"},
+				mockGetQuotaLimit,
+				andromeda.NopUpdateQuotaUsage(),
+				andromeda.AddUsageOption{UsageCost: mockUsageCost},
+			)
+			req := &andromeda.QuotaUsageRequest{QuotaID: "123", Usage: 1}
+			mockUsageCost.EXPECT().Do(ctx, req).Return(cost, nil)
+
+			_, err := addQuotaUsage.Do(ctx, req)
+
+			assert.EqualError(t, err, andromeda.NewInvalidUsageCostError("123", cost).Error())
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package andromeda
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WaitingRoomConfig .
+type WaitingRoomConfig struct {
+	Cache                         Cache
+	GetQuotaWaitingRoomKey        GetQuotaKey
+	GetQuotaWaitingRoomExpiration GetQuotaExpiration // expiration of the waiting room state, it starts with the first ticket
+	GetQuotaAdmissionRate         GetQuota           // admitted tickets per admission period
+	AdmissionPeriod               time.Duration      // default is 1 second
+	GetQuotaTicket                GetQuotaTicket
+	GetQuotaTicketExpiration      GetQuotaExpiration // how long the ticket is valid after it is admitted
+	GetQuotaSubject               GetQuotaSubject    // optional, binds the ticket to the subject so it cannot be handed to the others
+	Secret                        []byte             // signs the tickets
+}
+
+// WaitingRoom is a contract to admit the requests of a burst into the add path at a rate by the order of their tickets
+type WaitingRoom interface {
+	// Issue issues the next ticket of the quota
+	Issue(ctx context.Context, req *QuotaRequest) (*QuotaTicket, error)
+	// Status returns the position and the estimated wait of the ticket of the request
+	Status(ctx context.Context, req *QuotaRequest) (*QuotaTicketStatus, error)
+	// AddQuotaUsage calls the next when the ticket of the request is admitted, every ticket is used once
+	AddQuotaUsage(next UpdateQuotaUsage) UpdateQuotaUsage
+}
+
+// issueTicketScript starts the waiting room with the first ticket, numbers the tickets by the cache server
+// and schedules them into the slots of the rate every period, the slot after an idle period opens at the issued time
+const issueTicketScript = usageScriptPrelude + `
+local now = now()
+redis.call('SET', key('start'), now, 'NX', 'PX', p.expiration)
+local number = redis.call('INCR', key('issued'))
+if redis.call('PTTL', key('issued')) < 0 then
+	redis.call('PEXPIRE', key('issued'), p.expiration)
+end
+
+local slot = redis.call('HMGET', key('slot'), 'at', 'count')
+local at, count = tonumber(slot[1] or '0'), tonumber(slot[2] or '0')
+if at + tonumber(p.period) <= now then
+	at, count = now, 0
+elseif count >= tonumber(p.rate) then
+	at, count = at + tonumber(p.period), 0
+end
+redis.call('HSET', key('slot'), 'at', at, 'count', count + 1)
+if redis.call('PTTL', key('slot')) < 0 then
+	redis.call('PEXPIRE', key('slot'), p.expiration)
+end
+
+return {0, number, tonumber(redis.call('GET', key('start'))), now, math.max(at, now)}
+`
+
+const ticketStatusScript = usageScriptPrelude + `
+local slot = redis.call('HMGET', key('slot'), 'at', 'count')
+return {0, tonumber(redis.call('GET', key('start')) or '0'), now(), tonumber(redis.call('GET', key('issued')) or '0'),
+	tonumber(slot[1] or '0'), tonumber(slot[2] or '0')}
+`
+
+type waitingRoom struct {
+	cache                         Cache
+	getQuotaWaitingRoomKey        GetQuotaKey
+	getQuotaWaitingRoomExpiration GetQuotaExpiration
+	getQuotaAdmissionRate         GetQuota
+	admissionPeriod               time.Duration
+	getQuotaTicket                GetQuotaTicket
+	getQuotaTicketExpiration      GetQuotaExpiration
+	getQuotaSubject               GetQuotaSubject
+	secret                        []byte
+}
+
+func (r *waitingRoom) Issue(ctx context.Context, req *QuotaRequest) (*QuotaTicket, error) {
+	key, err := r.getQuotaWaitingRoomKey.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	exp, err := r.getQuotaWaitingRoomExpiration.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, err := r.admissionRate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	script := r.script(key).
+		withArg("expiration", exp.Milliseconds()).
+		withArg("rate", rate).
+		withArg("period", r.admissionPeriod.Milliseconds())
+	_, values, err := script.run(ctx, r.cache, issueTicketScript)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := r.subject(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket := &QuotaTicket{QuotaID: req.QuotaID, Number: values[0], IssuedAt: fromUnixMilli(values[2]), AdmitAt: fromUnixMilli(values[3])}
+	ticket.Token = r.sign(ticket, subject)
+
+	return ticket, nil
+}
+
+// script builds the script with the keys of the state of the waiting room
+func (r *waitingRoom) script(key string) *usageScript {
+	return new(usageScript).
+		withKey("start", fmt.Sprintf("%s-start", key)).
+		withKey("issued", fmt.Sprintf("%s-issued", key)).
+		withKey("slot", fmt.Sprintf("%s-slot", key))
+}
+
+func (r *waitingRoom) admissionRate(ctx context.Context, req *QuotaRequest) (int64, error) {
+	rate, err := r.getQuotaAdmissionRate.Do(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	if rate <= 0 {
+		return 0, fmt.Errorf("invalid admission rate %d of quota %s", rate, req.QuotaID)
+	}
+	return rate, nil
+}
+
+func (r *waitingRoom) Status(ctx context.Context, req *QuotaRequest) (*QuotaTicketStatus, error) {
+	_, _, status, err := r.check(ctx, req)
+	return status, err
+}
+
+// check verifies the ticket of the request and returns it with the key of the waiting room and its status
+func (r *waitingRoom) check(ctx context.Context, req *QuotaRequest) (*QuotaTicket, string, *QuotaTicketStatus, error) {
+	token, err := r.getQuotaTicket.Do(ctx, req)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	subject, err := r.subject(ctx, req)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	ticket, err := r.verify(req, token, subject)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	key, err := r.getQuotaWaitingRoomKey.Do(ctx, req)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	_, values, err := r.script(key).withReadOnly().run(ctx, r.cache, ticketStatusScript)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// the ticket that is issued before the start belongs to a waiting room that has expired
+	start := fromUnixMilli(values[0])
+	if values[0] == 0 || ticket.IssuedAt.Before(start) {
+		return nil, "", nil, fmt.Errorf("%w: ticket %d of quota %s", ErrTicketExpired, ticket.Number, ticket.QuotaID)
+	}
+
+	now := fromUnixMilli(values[1])
+	status, err := r.status(ctx, req, ticket, now, values[2], fromUnixMilli(values[3]), values[4])
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	if status.Admitted {
+		exp, err := r.getQuotaTicketExpiration.Do(ctx, req)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if now.After(status.AdmitAt.Add(exp)) {
+			return nil, "", nil, fmt.Errorf("%w: ticket %d of quota %s", ErrTicketExpired, ticket.Number, ticket.QuotaID)
+		}
+	}
+
+	return ticket, key, status, nil
+}
+
+// subject gets the subject that the ticket is bound to, it is empty when the tickets are not bound
+func (r *waitingRoom) subject(ctx context.Context, req *QuotaRequest) (string, error) {
+	if r.getQuotaSubject == nil {
+		return "", nil
+	}
+	return getQuotaSubject(ctx, r.getQuotaSubject, req)
+}
+
+// status admits the ticket at the slot that it is scheduled to, the tickets that wait are in the full slots
+// of the admission rate every admission period before the last slot of the issued tickets
+func (r *waitingRoom) status(ctx context.Context, req *QuotaRequest, ticket *QuotaTicket, now time.Time, issued int64, slotAt time.Time, slotCount int64) (*QuotaTicketStatus, error) {
+	status := &QuotaTicketStatus{Number: ticket.Number, AdmitAt: ticket.AdmitAt}
+	if !now.Before(ticket.AdmitAt) {
+		status.Admitted = true
+		return status, nil
+	}
+
+	rate, err := r.admissionRate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	waiting := int64(0)
+	if slotAt.After(now) {
+		waiting = slotCount + rate*int64((slotAt.Sub(now)-1)/r.admissionPeriod)
+	}
+
+	if status.Position = ticket.Number - (issued - waiting) - 1; status.Position < 0 {
+		status.Position = 0
+	}
+	status.EstimatedWait = ticket.AdmitAt.Sub(now)
+	return status, nil
+}
+
+func (r *waitingRoom) AddQuotaUsage(next UpdateQuotaUsage) UpdateQuotaUsage {
+	if next == nil {
+		next = NopUpdateQuotaUsage()
+	}
+
+	return &admitQuotaUsage{room: r, next: next}
+}
+
+// sign encodes the ticket with the signature of the number, the issued and admission time, the subject and the quota
+func (r *waitingRoom) sign(ticket *QuotaTicket, subject string) string {
+	payload := fmt.Sprintf("%d:%d:%d:%s:%s", ticket.Number, unixMilli(ticket.IssuedAt), unixMilli(ticket.AdmitAt),
+		base64.RawURLEncoding.EncodeToString([]byte(subject)), ticket.QuotaID)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(r.signature(payload))
+}
+
+func (r *waitingRoom) signature(payload string) []byte {
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// verify decodes the ticket and rejects the forged ticket and the ticket of the other quota or subject
+func (r *waitingRoom) verify(req *QuotaRequest, token, subject string) (*QuotaTicket, error) {
+	invalid := fmt.Errorf("%w: quota %s", ErrInvalidTicket, req.QuotaID)
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, invalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, invalid
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, r.signature(string(payload))) {
+		return nil, invalid
+	}
+
+	fields := strings.SplitN(string(payload), ":", 5)
+	if len(fields) != 5 || fields[4] != req.QuotaID || fields[3] != base64.RawURLEncoding.EncodeToString([]byte(subject)) {
+		return nil, invalid
+	}
+
+	number, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, invalid
+	}
+
+	issuedAt, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, invalid
+	}
+
+	admitAt, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, invalid
+	}
+
+	return &QuotaTicket{QuotaID: fields[4], Number: number, IssuedAt: fromUnixMilli(issuedAt), AdmitAt: fromUnixMilli(admitAt), Token: token}, nil
+}
+
+type admitQuotaUsage struct {
+	room *waitingRoom
+	next UpdateQuotaUsage
+}
+
+func (q *admitQuotaUsage) Do(ctx context.Context, req *QuotaUsageRequest) (interface{}, error) {
+	quotaReq := &QuotaRequest{QuotaID: req.QuotaID, Data: req.Data}
+	ticket, key, status, err := q.room.check(ctx, quotaReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if !status.Admitted {
+		return nil, NewTicketNotAdmittedError(req.QuotaID, status)
+	}
+
+	// marks the ticket as used until the waiting room expires, the ticket of the next waiting room has the other issued time
+	exp, err := q.room.getQuotaWaitingRoomExpiration.Do(ctx, quotaReq)
+	if err != nil {
+		return nil, err
+	}
+
+	usedKey := fmt.Sprintf("%s-used-%d-%d", key, ticket.Number, unixMilli(ticket.IssuedAt))
+	ok, err := q.room.cache.SetNX(ctx, usedKey, 1, exp)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("%w: ticket %d of quota %s", ErrTicketUsed, ticket.Number, ticket.QuotaID)
+	}
+
+	res, err := q.next.Do(ctx, req)
+	if err != nil {
+		// the ticket can be used again when the next fails
+		if _, er := q.room.cache.Del(ctx, usedKey); er != nil {
+			return nil, er
+		}
+	}
+
+	return res, err
+}
+
+// TicketNotAdmittedError is error for the ticket that waits to be admitted
+type TicketNotAdmittedError struct {
+	QuotaID       string
+	Position      int64
+	EstimatedWait time.Duration
+}
+
+func (e *TicketNotAdmittedError) Error() string {
+	return fmt.Sprintf("%v: position %d and estimated wait %s for quota %s", ErrTicketNotAdmitted, e.Position, e.EstimatedWait, e.QuotaID)
+}
+
+func (e *TicketNotAdmittedError) Unwrap() error {
+	return ErrTicketNotAdmitted
+}
+
+// NewTicketNotAdmittedError is a error helper for the ticket that waits to be admitted
+func NewTicketNotAdmittedError(quotaID string, status *QuotaTicketStatus) error {
+	return &TicketNotAdmittedError{QuotaID: quotaID, Position: status.Position, EstimatedWait: status.EstimatedWait}
+}
+
+// NewWaitingRoom .
+func NewWaitingRoom(conf WaitingRoomConfig) WaitingRoom {
+	if conf.Cache == nil {
+		panic("Cache is required")
+	}
+	mustScriptCache(conf.Cache)
+	if conf.GetQuotaWaitingRoomKey == nil {
+		panic("GetQuotaWaitingRoomKey is required")
+	}
+	if conf.GetQuotaWaitingRoomExpiration == nil {
+		panic("GetQuotaWaitingRoomExpiration is required")
+	}
+	if conf.GetQuotaAdmissionRate == nil {
+		panic("GetQuotaAdmissionRate is required")
+	}
+	if conf.GetQuotaTicket == nil {
+		panic("GetQuotaTicket is required")
+	}
+	if conf.GetQuotaTicketExpiration == nil {
+		panic("GetQuotaTicketExpiration is required")
+	}
+	if len(conf.Secret) == 0 {
+		panic("Secret is required")
+	}
+	if conf.AdmissionPeriod <= 0 {
+		conf.AdmissionPeriod = time.Second
+	}
+
+	return &waitingRoom{
+		cache:                         conf.Cache,
+		getQuotaWaitingRoomKey:        conf.GetQuotaWaitingRoomKey,
+		getQuotaWaitingRoomExpiration: conf.GetQuotaWaitingRoomExpiration,
+		getQuotaAdmissionRate:         conf.GetQuotaAdmissionRate,
+		admissionPeriod:               conf.AdmissionPeriod,
+		getQuotaTicket:                conf.GetQuotaTicket,
+		getQuotaTicketExpiration:      conf.GetQuotaTicketExpiration,
+		getQuotaSubject:               conf.GetQuotaSubject,
+		secret:                        conf.Secret,
+	}
+}
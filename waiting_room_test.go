@@ -0,0 +1,196 @@
+package andromeda_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/ramadani/andromeda"
+	"github.com/ramadani/andromeda/cache"
+	"github.com/ramadani/andromeda/mocks"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitingRoom(t *testing.T) {
+	ctx := context.TODO()
+	miniRedis, err := miniredis.Run()
+	assert.Nil(t, err)
+
+	now := time.Now().Truncate(time.Second)
+	miniRedis.SetTime(now)
+
+	redisCache := cache.NewCacheRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}))
+	conf := andromeda.WaitingRoomConfig{
+		Cache:                         redisCache,
+		GetQuotaWaitingRoomKey:        &mockGetQuotaKey{keyFormat: "waiting-room-%s"},
+		GetQuotaWaitingRoomExpiration: &mockGetQuotaExp{},
+		GetQuotaAdmissionRate:         &mockGetQuota{value: 2},
+		AdmissionPeriod:               time.Second,
+		GetQuotaTicket:                &mockGetQuotaTicket{},
+		GetQuotaTicketExpiration:      &mockGetQuotaExp{},
+		Secret:                        []byte("secret"),
+	}
+	waitingRoom := andromeda.NewWaitingRoom(conf)
+	addQuotaUsage := waitingRoom.AddQuotaUsage(nil)
+
+	tickets := make([]*andromeda.QuotaTicket, 5)
+	for i := range tickets {
+		tickets[i], err = waitingRoom.Issue(ctx, &andromeda.QuotaRequest{QuotaID: "123"})
+		assert.Nil(t, err)
+	}
+
+	t.Run("IssueTicketsInOrder", func(t *testing.T) {
+		assert.Equal(t, int64(1), tickets[0].Number)
+		assert.Equal(t, int64(5), tickets[4].Number)
+		assert.Equal(t, now, tickets[1].AdmitAt)
+		assert.Equal(t, now.Add(time.Second*2), tickets[4].AdmitAt)
+	})
+
+	t.Run("AdmitByRate", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: tickets[1].Token, Usage: 1})
+		assert.Nil(t, err)
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: tickets[4].Token, Usage: 1})
+		assert.True(t, errors.Is(err, andromeda.ErrTicketNotAdmitted))
+		assert.EqualError(t, err, "ticket not admitted: position 2 and estimated wait 2s for quota 123")
+	})
+
+	t.Run("ErrorUsedTicket", func(t *testing.T) {
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: tickets[1].Token, Usage: 1})
+
+		assert.True(t, errors.Is(err, andromeda.ErrTicketUsed))
+	})
+
+	t.Run("StatusPositionAndEstimatedWait", func(t *testing.T) {
+		miniRedis.SetTime(now.Add(time.Second))
+
+		status, err := waitingRoom.Status(ctx, &andromeda.QuotaRequest{QuotaID: "123", Data: tickets[4].Token})
+		assert.Nil(t, err)
+		assert.Equal(t, &andromeda.QuotaTicketStatus{
+			Number:        5,
+			Position:      0,
+			EstimatedWait: time.Second,
+			AdmitAt:       now.Add(time.Second * 2),
+		}, status)
+
+		status, err = waitingRoom.Status(ctx, &andromeda.QuotaRequest{QuotaID: "123", Data: tickets[2].Token})
+		assert.Nil(t, err)
+		assert.True(t, status.Admitted)
+	})
+
+	t.Run("ErrorForgedTicket", func(t *testing.T) {
+		// moves the last ticket to the front with its signature
+		parts := strings.Split(tickets[4].Token, ".")
+		payload := fmt.Sprintf("1:%d:%d::123", tickets[4].IssuedAt.UnixNano()/int64(time.Millisecond), now.UnixNano()/int64(time.Millisecond))
+		forged := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + parts[1]
+		other := andromeda.NewWaitingRoom(andromeda.WaitingRoomConfig{
+			Cache:                         redisCache,
+			GetQuotaWaitingRoomKey:        conf.GetQuotaWaitingRoomKey,
+			GetQuotaWaitingRoomExpiration: conf.GetQuotaWaitingRoomExpiration,
+			GetQuotaAdmissionRate:         conf.GetQuotaAdmissionRate,
+			GetQuotaTicket:                conf.GetQuotaTicket,
+			GetQuotaTicketExpiration:      conf.GetQuotaTicketExpiration,
+			Secret:                        []byte("other"),
+		})
+		otherTicket, _ := other.Issue(ctx, &andromeda.QuotaRequest{QuotaID: "123"})
+
+		for _, token := range []string{forged, parts[0] + ".", "ticket", otherTicket.Token} {
+			_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: token, Usage: 1})
+			assert.True(t, errors.Is(err, andromeda.ErrInvalidTicket), token)
+		}
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "456", Data: tickets[0].Token, Usage: 1})
+		assert.True(t, errors.Is(err, andromeda.ErrInvalidTicket))
+	})
+
+	t.Run("AdmitAtIssueAfterIdle", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		mockGetQuotaExp := mocks.NewMockGetQuotaExpiration(mockCtrl)
+		mockGetQuotaExp.EXPECT().Do(ctx, gomock.Any()).Return(time.Minute, nil).AnyTimes()
+		idleRoom := andromeda.NewWaitingRoom(andromeda.WaitingRoomConfig{
+			Cache:                         redisCache,
+			GetQuotaWaitingRoomKey:        &mockGetQuotaKey{keyFormat: "idle-waiting-room-%s"},
+			GetQuotaWaitingRoomExpiration: mockGetQuotaExp,
+			GetQuotaAdmissionRate:         conf.GetQuotaAdmissionRate,
+			GetQuotaTicket:                conf.GetQuotaTicket,
+			GetQuotaTicketExpiration:      conf.GetQuotaTicketExpiration,
+			Secret:                        conf.Secret,
+		})
+		idleAddQuotaUsage := idleRoom.AddQuotaUsage(nil)
+
+		miniRedis.SetTime(now.Add(time.Second * 2))
+		_, err := idleRoom.Issue(ctx, &andromeda.QuotaRequest{QuotaID: "123"})
+		assert.Nil(t, err)
+
+		// the room is idle for a while, the allowance of the idle slots is not accumulated
+		issuedAt := now.Add(time.Second * 20)
+		miniRedis.SetTime(issuedAt)
+		idleTickets := make([]*andromeda.QuotaTicket, 3)
+		for i := range idleTickets {
+			idleTickets[i], err = idleRoom.Issue(ctx, &andromeda.QuotaRequest{QuotaID: "123"})
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, issuedAt, idleTickets[1].AdmitAt)
+		assert.Equal(t, issuedAt.Add(time.Second), idleTickets[2].AdmitAt)
+
+		_, err = idleAddQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: idleTickets[2].Token, Usage: 1})
+		assert.EqualError(t, err, "ticket not admitted: position 0 and estimated wait 1s for quota 123")
+
+		// the expiration starts from the admission of the ticket
+		miniRedis.SetTime(issuedAt.Add(time.Second * 25))
+		_, err = idleAddQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: idleTickets[0].Token, Usage: 1})
+		assert.Nil(t, err)
+	})
+
+	t.Run("ErrorExpiredTicket", func(t *testing.T) {
+		miniRedis.SetTime(now.Add(time.Second * 31))
+
+		_, err := addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: tickets[0].Token, Usage: 1})
+		assert.True(t, errors.Is(err, andromeda.ErrTicketExpired))
+
+		_, err = addQuotaUsage.Do(ctx, &andromeda.QuotaUsageRequest{QuotaID: "123", Data: tickets[4].Token, Usage: 1})
+		assert.Nil(t, err)
+	})
+
+	t.Run("ErrorTicketOfOtherSubject", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		mockGetQuotaTicket := mocks.NewMockGetQuotaTicket(mockCtrl)
+		mockGetQuotaSubject := mocks.NewMockGetQuotaSubject(mockCtrl)
+		boundRoom := andromeda.NewWaitingRoom(andromeda.WaitingRoomConfig{
+			Cache:                         redisCache,
+			GetQuotaWaitingRoomKey:        &mockGetQuotaKey{keyFormat: "bound-waiting-room-%s"},
+			GetQuotaWaitingRoomExpiration: conf.GetQuotaWaitingRoomExpiration,
+			GetQuotaAdmissionRate:         conf.GetQuotaAdmissionRate,
+			GetQuotaTicket:                mockGetQuotaTicket,
+			GetQuotaTicketExpiration:      conf.GetQuotaTicketExpiration,
+			GetQuotaSubject:               mockGetQuotaSubject,
+			Secret:                        conf.Secret,
+		})
+		req := &andromeda.QuotaRequest{QuotaID: "123"}
+		mockGetQuotaSubject.EXPECT().Do(ctx, req).Return("user-1", nil)
+
+		ticket, err := boundRoom.Issue(ctx, req)
+		assert.Nil(t, err)
+
+		mockGetQuotaTicket.EXPECT().Do(ctx, req).Return(ticket.Token, nil).Times(2)
+		mockGetQuotaSubject.EXPECT().Do(ctx, req).Return("user-2", nil)
+		mockGetQuotaSubject.EXPECT().Do(ctx, req).Return("user-1", nil)
+
+		_, err = boundRoom.Status(ctx, req)
+		assert.True(t, errors.Is(err, andromeda.ErrInvalidTicket))
+
+		status, err := boundRoom.Status(ctx, req)
+		assert.Nil(t, err)
+		assert.True(t, status.Admitted)
+	})
+}
@@ -28,13 +28,9 @@ func (q *xSetNXQuota) Do(ctx context.Context, req *QuotaRequest) (err error) {
 		return
 	}
 
-	lockKey := fmt.Sprintf("%s-lock", key)
-	succeedLock, err := q.cache.SetNX(ctx, lockKey, 1, q.lockIn)
+	lockKey, err := lockQuotaKey(ctx, q.cache, key, q.lockIn)
 	if err != nil {
 		return
-	} else if !succeedLock {
-		err = fmt.Errorf("%w: %s", ErrLockedKey, lockKey)
-		return
 	}
 
 	defer func() {
@@ -57,6 +53,19 @@ func (q *xSetNXQuota) Do(ctx context.Context, req *QuotaRequest) (err error) {
 	return
 }
 
+// lockQuotaKey locks the key of a quota and returns the lock key that must be deleted to unlock
+func lockQuotaKey(ctx context.Context, cache Cache, key string, lockIn time.Duration) (string, error) {
+	lockKey := fmt.Sprintf("%s-lock", key)
+	succeedLock, err := cache.SetNX(ctx, lockKey, 1, lockIn)
+	if err != nil {
+		return "", err
+	} else if !succeedLock {
+		return "", fmt.Errorf("%w: %s", ErrLockedKey, lockKey)
+	}
+
+	return lockKey, nil
+}
+
 // NewXSetNXQuota .
 func NewXSetNXQuota(
 	cache Cache,